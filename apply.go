@@ -0,0 +1,189 @@
+package cmdline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SetEnv names the environment variable from which ApplyEnv takes the value of the command
+// variable with the input argument 'name'
+func (cp *CmdParser) SetEnv(name string, env_var string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetEnv given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].env = env_var
+}
+
+// envVar returns the name of the environment variable from which the command variable with the input
+// argument 'name' may take its value, the one given by SetEnv or else the one EnvPrefix names, or "" for none
+func (cp *CmdParser) envVar(name string) string {
+	if env_var := cp.info[name].env; env_var != "" {
+		return env_var
+	}
+	if cp.EnvPrefix == "" {
+		return ""
+	}
+	return strings.TrimSuffix(cp.EnvPrefix, "_") + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvPrefix sets each command variable that the parse left without a value from its environment
+// variable under EnvPrefix, if that is set
+func (pr *pairer) applyEnvPrefix() {
+	cp := pr.cp
+	if cp.EnvPrefix == "" {
+		return
+	}
+	for _, name := range cp.order {
+		if _, given := pr.given[name]; given || cp.vars[name].Loaded() || pr.failed[name] {
+			continue
+		}
+		env_var := cp.envVar(name)
+		value, present := os.LookupEnv(env_var)
+		if !present {
+			continue
+		}
+		cp.infof("flag -%s set from $%s\n", name, env_var)
+		if err := cp.setVar(name, value, origin{source: SourceEnv, file: env_var}); err != nil {
+			pr.note(fmt.Errorf("$%s: %w", env_var, err))
+			pr.failed[name] = true
+		}
+	}
+}
+
+// applied ends the parse of a single source.  Unlike finish, it does not check for required variables,
+// since other sources may yet supply them, and flags that were not declared are an error
+func (pr *pairer) applied() error {
+	cp := pr.cp
+	pr.note(pr.flush())
+	pr.selectModes()
+	if len(pr.unknown) > 0 && cp.UnknownAsPositional {
+		pr.positional()
+	} else if len(pr.unknown) > 0 {
+		pr.note(pr.unknownError())
+	}
+	pr.note(cp.loadCompanions(pr.given))
+	pr.note(cp.writeFlagSets())
+	cp.writeBindings()
+	return combineErrors(pr.problems)
+}
+
+// ApplyDefaults sets every command variable that has a declared default but no value to that default.
+// Together with ApplyFile, ApplyEnv, and ApplyArgs it lets a program take values from each source
+// in an order of its own choosing, looking at the values between steps.  Whether a later source
+// overrides an earlier one follows the precedence of the sources, see SetPrecedence.  It returns every default
+// that could not be set, see MultiError
+func (cp *CmdParser) ApplyDefaults() error {
+	cp.started = true
+	problems := []error{}
+	for _, name := range cp.order {
+		info := cp.info[name]
+		if !info.hasDef || cp.vars[name].Loaded() {
+			continue
+		}
+		value := formatValue(cp.vars[name].ArgType(), info.def)
+		if err := cp.setVar(name, value, origin{source: SourceDefault}); err != nil {
+			problems = append(problems, fmt.Errorf("default: %w", err))
+		}
+	}
+	cp.writeBindings()
+	return combineErrors(problems)
+}
+
+// LoadDefaultsFromFile reads a file of flags in the format read by ParseFromFile, e.g., defaults shipped
+// by the operators of a program, and makes each value the default of its command variable rather than
+// its value, so that a value from any source overrides it.  A default read this way also satisfies a
+// required variable.  A flag that is not declared, or a value the variable cannot hold, is an error
+func (cp *CmdParser) LoadDefaultsFromFile(filename string) error {
+	pr := cp.newPairer()
+	pr.defaults = true
+	if err := cp.feedFile(filename, pr); err != nil {
+		return err
+	}
+	pr.note(pr.flush())
+	if len(pr.unknown) > 0 {
+		pr.note(pr.unknownError())
+	}
+	for _, name := range cp.order {
+		fv, present := pr.given[name]
+		if !present {
+			continue
+		}
+		def, err := defaultValue(cp.vars[name], fv.value)
+		if err != nil {
+			pr.note(cp.valueError(name, fv.value, err, fv.at))
+			continue
+		}
+		cp.info[name].def = def
+		cp.info[name].hasDef = true
+		cp.info[name].defFile = filename
+	}
+	return combineErrors(pr.problems)
+}
+
+// defaultValue converts a value for a command variable to the form in which its default is kept
+func defaultValue(v arg, value string) (any, error) {
+	if err := checkValue(v, value); err != nil {
+		return nil, err
+	}
+	switch vs := v.(type) {
+	case *unionVar:
+		for _, arg_type := range vs.v_types {
+			if def, err := convertValue(arg_type, value); err == nil {
+				return def, nil
+			}
+		}
+		break
+	case *bytesVar:
+		return decodeBase64(value, vs.v_url)
+	case *logLevelVar:
+		level, err := parseLogLevel(value)
+		return level.String(), err
+	case *typedMapVar:
+		return vs.parseEntries(value)
+	}
+	return convertValue(v.ArgType(), value)
+}
+
+// ApplyEnv sets every command variable given an environment variable by SetEnv, or named under
+// EnvPrefix, to that environment variable's value, if it is set.  It returns every value that could not be set,
+// see MultiError
+func (cp *CmdParser) ApplyEnv() error {
+	cp.started = true
+	problems := []error{}
+	for _, name := range cp.order {
+		env_var := cp.envVar(name)
+		if env_var == "" {
+			continue
+		}
+		value, present := os.LookupEnv(env_var)
+		if !present {
+			continue
+		}
+		cp.infof("flag -%s set from $%s\n", name, env_var)
+		if err := cp.setVar(name, value, origin{source: SourceEnv, file: env_var}); err != nil {
+			problems = append(problems, fmt.Errorf("$%s: %w", env_var, err))
+		}
+	}
+	cp.writeBindings()
+	return combineErrors(problems)
+}
+
+// ApplyFile sets the command variables whose flags appear in a file in the format read by ParseFromFile
+func (cp *CmdParser) ApplyFile(filename string) error {
+	pr := cp.newPairer()
+	if err := cp.feedFile(filename, pr); err != nil {
+		return err
+	}
+	return pr.applied()
+}
+
+// ApplyArgs sets the command variables whose flags appear in a list of arguments, e.g., os.Args[1:],
+// taking each argument as one piece of the command line as ParseFromArgs does
+func (cp *CmdParser) ApplyArgs(args []string) error {
+	pr := cp.newPairer()
+	for idx, arg := range args {
+		pr.note(pr.add(token{text: arg, at: origin{source: SourceCmdLine}, argAt: idx + 1}))
+	}
+	return pr.applied()
+}
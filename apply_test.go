@@ -0,0 +1,186 @@
+package cmdline
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestApplyOverride checks that values applied from the command line override the defaults applied
+// before them, that defaults applied after them do not, and that flags not given keep their defaults
+func TestApplyOverride(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(IntFlag, "count", false, 1)
+	cp.AddFlagWithDefault(StringFlag, "name", false, "default-run")
+	if err := cp.ApplyDefaults(); err != nil {
+		t.Fatalf("ApplyDefaults failed: %v", err)
+	}
+	if cp.GetVar("count") != 1 || cp.GetVar("name") != "default-run" {
+		t.Fatalf("after ApplyDefaults -count is %v and -name is %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+	if err := cp.ApplyArgs([]string{"-count", "5"}); err != nil {
+		t.Fatalf("ApplyArgs failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 5 {
+		t.Errorf("-count is %v after ApplyArgs, want 5 from the command line", got)
+	}
+	if got := cp.GetVar("name"); got != "default-run" {
+		t.Errorf("-name is %q after ApplyArgs, want its default", got)
+	}
+
+	// the defaults rank below the command line, so applying them again changes nothing
+	if err := cp.ApplyDefaults(); err != nil {
+		t.Fatalf("ApplyDefaults failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 5 {
+		t.Errorf("-count is %v after applying the defaults again, want 5 from the command line", got)
+	}
+}
+
+// TestApplyPrecedence checks that a source applied later does not override one of higher precedence,
+// while one of lower precedence does, whatever the order they are applied in
+func TestApplyPrecedence(t *testing.T) {
+	t.Setenv("APPLY_COUNT", "7")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.SetEnv("count", "APPLY_COUNT")
+	if err := cp.ApplyArgs([]string{"-count", "5"}); err != nil {
+		t.Fatalf("ApplyArgs failed: %v", err)
+	}
+	if err := cp.ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 5 {
+		t.Errorf("-count is %v, want 5 from the command line, which outranks the environment", got)
+	}
+
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.SetEnv("count", "APPLY_COUNT")
+	if err := cp.SetPrecedence([]Source{SourceDefault, SourceFile, SourceCmdLine, SourceEnv}); err != nil {
+		t.Fatalf("SetPrecedence failed: %v", err)
+	}
+	if err := cp.ApplyArgs([]string{"-count", "5"}); err != nil {
+		t.Fatalf("ApplyArgs failed: %v", err)
+	}
+	if err := cp.ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 7 {
+		t.Errorf("-count is %v, want 7 from the environment, put above the command line by SetPrecedence", got)
+	}
+}
+
+// TestLoadDefaultsFromFile checks that a value read from a defaults file is the default of its flag,
+// overridden by a flag on the command line, and that it satisfies a required flag
+func TestLoadDefaultsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "defaults.cfg")
+	if err := os.WriteFile(path, []byte("-workers 4\n-name shipped\n"), 0o644); err != nil {
+		t.Fatalf("cannot write defaults file: %v", err)
+	}
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "workers", true)
+	cp.AddFlag(StringFlag, "name", false)
+	if err := cp.LoadDefaultsFromFile(path); err != nil {
+		t.Fatalf("LoadDefaultsFromFile failed: %v", err)
+	}
+	if err := cp.ParseFromArgs([]string{"-name", "mine"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("name"); got != "mine" {
+		t.Errorf("-name is %q, want %q from the command line", got, "mine")
+	}
+	if got := cp.GetVar("workers"); got != 4 {
+		t.Errorf("-workers is %v, want 4 from the defaults file", got)
+	}
+	if def, ok := cp.GetDefault("workers"); !ok || def != 4 {
+		t.Errorf("GetDefault(workers) is %v, %v, want 4, true", def, ok)
+	}
+
+	// a flag that is not declared is an error
+	if err := os.WriteFile(path, []byte("-wrokers 4\n"), 0o644); err != nil {
+		t.Fatalf("cannot write defaults file: %v", err)
+	}
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "workers", false)
+	if err := cp.LoadDefaultsFromFile(path); err == nil {
+		t.Errorf("LoadDefaultsFromFile of an undeclared flag returned no error")
+	}
+}
+
+// TestEnvPrefix checks that with EnvPrefix "MYAPP" the environment variable MYAPP_COUNT populates -count
+// and MYAPP_LOG_LEVEL populates -log-level, and that a flag on the command line overrides the environment
+func TestEnvPrefix(t *testing.T) {
+	t.Setenv("MYAPP_COUNT", "12")
+	t.Setenv("MYAPP_LOG_LEVEL", "warn")
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.EnvPrefix = "MYAPP"
+		cp.AddFlag(IntFlag, "count", true)
+		cp.AddFlag(StringFlag, "log-level", false)
+		cp.AddFlag(StringFlag, "name", false)
+		return cp
+	}
+
+	cp := declare()
+	if err := cp.ParseFromArgs([]string{}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 12 {
+		t.Errorf("-count is %v, want 12 from $MYAPP_COUNT", got)
+	}
+	if got := cp.GetVar("log-level"); got != "warn" {
+		t.Errorf("-log-level is %q, want %q from $MYAPP_LOG_LEVEL", got, "warn")
+	}
+	if cp.IsLoaded("name") {
+		t.Errorf("-name is loaded without $MYAPP_NAME")
+	}
+
+	cp = declare()
+	if err := cp.ParseFromArgs([]string{"-count", "3"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 3 {
+		t.Errorf("-count is %v, want 3 from the command line over $MYAPP_COUNT", got)
+	}
+}
+
+// TestApplyEnvErrors checks that ApplyEnv returns every value that cannot be set, naming its environment
+// variable and wrapping the *BadValueError, with the value of a secret variable redacted
+func TestApplyEnvErrors(t *testing.T) {
+	t.Setenv("ZZ_TOKEN", "hunter2")
+	t.Setenv("ZZ_COUNT", "many")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "token", false)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.SetSecret("token")
+	cp.SetEnv("token", "ZZ_TOKEN")
+	cp.SetEnv("count", "ZZ_COUNT")
+	err := cp.ApplyEnv()
+	multi, ok := err.(*MultiError)
+	if !ok || len(multi.Errors()) != 2 {
+		t.Fatalf("ApplyEnv gave %v, want a *MultiError of two problems", err)
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("ApplyEnv error %q shows the secret value", err)
+	}
+	for idx, env_var := range []string{"$ZZ_TOKEN: ", "$ZZ_COUNT: "} {
+		if !strings.HasPrefix(multi.Errors()[idx].Error(), env_var) {
+			t.Errorf("problem %d is %q, want it to begin %q", idx+1, multi.Errors()[idx], env_var)
+		}
+	}
+	var bad *BadValueError
+	if !errors.As(multi.Errors()[1], &bad) || bad.Flag != "count" {
+		t.Errorf("ApplyEnv error %v does not wrap a *BadValueError for -count", multi.Errors()[1])
+	}
+}
@@ -0,0 +1,61 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// An AuditEntry records one assignment of a value to a command variable, made while auditing is enabled
+type AuditEntry struct {
+	Seq      int    // position of the assignment among all those recorded, starting at 1
+	Flag     string // name of the command variable
+	Value    string // raw value assigned, or "****" for a secret variable
+	Source   Source // where the value came from
+	Location string // file and line the value came from, if it came from a file
+	Ignored  bool   // the variable kept a value from a source of higher precedence instead
+}
+
+// EnableAudit turns on or off the recording of every assignment of a value to a command variable,
+// for later retrieval by AuditLog or WriteAudit
+func (cp *CmdParser) EnableAudit(on bool) {
+	cp.auditing = on
+}
+
+// record appends an assignment to the audit log, if auditing is enabled
+func (cp *CmdParser) record(name string, value string, at origin, ignored bool) {
+	if !cp.auditing {
+		return
+	}
+	entry := AuditEntry{Seq: len(cp.auditLog) + 1, Flag: name, Value: cp.display(name, value),
+		Source: at.source, Ignored: ignored}
+	if at.source == SourceFile {
+		entry.Location = at.position()
+	}
+	cp.auditLog = append(cp.auditLog, entry)
+}
+
+// AuditLog returns the assignments recorded while auditing was enabled, in the order they were made
+func (cp *CmdParser) AuditLog() []AuditEntry {
+	return append([]AuditEntry{}, cp.auditLog...)
+}
+
+// WriteAudit writes the assignments recorded while auditing was enabled, one per line in the
+// order they were made, giving the sequence number, flag, value, and where the value came from
+func (cp *CmdParser) WriteAudit(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, entry := range cp.auditLog {
+		from := entry.Source.String()
+		if entry.Location != "" {
+			from = "from " + entry.Location
+		}
+		if entry.Ignored {
+			from += ", ignored for higher precedence value"
+		}
+		_, err := fmt.Fprintf(tw, "%d\t-%s %s\t# %s\n", entry.Seq, entry.Flag, entry.Value, from)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,63 @@
+package cmdline
+
+import (
+	"time"
+)
+
+// binding writes the value of a command variable into a variable of the program after each parse
+type binding struct {
+	name  string
+	write func(value any)
+}
+
+// bind declares a command variable whose value a parse writes through write, as flag.IntVar and the like
+// do in the standard library flag package.  A variable that is not required takes the program's variable's
+// value at the time of binding as its default, which a parse also writes
+func (cp *CmdParser) bind(arg_type FlagArgType, name string, req bool, def any, write func(value any)) {
+	if req {
+		cp.AddFlag(arg_type, name, req)
+	} else {
+		cp.AddFlagWithDefault(arg_type, name, req, def)
+	}
+	cp.bindings = append(cp.bindings, binding{name: name, write: write})
+}
+
+// writeBindings writes the value, or else the default, of each bound command variable into the program's variable
+func (cp *CmdParser) writeBindings() {
+	for _, b := range cp.bindings {
+		if value := cp.value(b.name); value != nil {
+			b.write(value)
+		}
+	}
+}
+
+// BindInt declares an IntFlag whose value each parse writes into *p, so that the program reads it there
+// rather than calling GetVar.  Unless the flag is required, the value of *p when bound is its default
+func (cp *CmdParser) BindInt(p *int, name string, req bool) {
+	cp.bind(IntFlag, name, req, *p, func(value any) { *p = value.(int) })
+}
+
+// BindInt64 declares an Int64Flag whose value each parse writes into *p, as BindInt does for an IntFlag
+func (cp *CmdParser) BindInt64(p *int64, name string, req bool) {
+	cp.bind(Int64Flag, name, req, *p, func(value any) { *p = value.(int64) })
+}
+
+// BindFloat declares a FloatFlag whose value each parse writes into *p, as BindInt does for an IntFlag
+func (cp *CmdParser) BindFloat(p *float64, name string, req bool) {
+	cp.bind(FloatFlag, name, req, *p, func(value any) { *p = value.(float64) })
+}
+
+// BindString declares a StringFlag whose value each parse writes into *p, as BindInt does for an IntFlag
+func (cp *CmdParser) BindString(p *string, name string, req bool) {
+	cp.bind(StringFlag, name, req, *p, func(value any) { *p = value.(string) })
+}
+
+// BindBool declares a BoolFlag whose value each parse writes into *p, as BindInt does for an IntFlag
+func (cp *CmdParser) BindBool(p *bool, name string, req bool) {
+	cp.bind(BoolFlag, name, req, *p, func(value any) { *p = value.(bool) })
+}
+
+// BindDuration declares a FlexDurationFlag whose value each parse writes into *p, as BindInt does for an IntFlag
+func (cp *CmdParser) BindDuration(p *time.Duration, name string, req bool) {
+	cp.bind(FlexDurationFlag, name, req, *p, func(value any) { *p = value.(time.Duration) })
+}
@@ -0,0 +1,40 @@
+package cmdline
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBind checks that bound variables hold the parsed values after a parse, that one not given keeps the
+// value it had when bound, and that a value read by a prompt is written too
+func TestBind(t *testing.T) {
+	count, name, timeout := 0, "default-name", 5*time.Second
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.BindInt(&count, "count", true)
+	cp.BindString(&name, "name", false)
+	cp.BindDuration(&timeout, "timeout", false)
+	if err := cp.ParseFromArgs([]string{"-count", "7", "-timeout", "30"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if count != 7 || name != "default-name" || timeout != 30*time.Second {
+		t.Errorf("after the parse count is %d, name %q, and timeout %v", count, name, timeout)
+	}
+
+	// the value read for a missing flag is bound as well
+	count, name = 0, ""
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.PromptOnMissing = true
+	cp.SetPromptInput(strings.NewReader("12\n"))
+	cp.BindInt(&count, "count", true)
+	cp.BindString(&name, "name", false)
+	if err := cp.ParseFromArgs([]string{"-name", "x"}); err != nil {
+		t.Fatalf("ParseFromArgs with a prompt failed: %v", err)
+	}
+	if count != 12 || name != "x" {
+		t.Errorf("after the prompt count is %d and name %q, want 12 and %q", count, name, "x")
+	}
+}
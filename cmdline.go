@@ -5,23 +5,49 @@ package cmdline
 
 import (
 	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"unicode"
 )
 
 // FlagArgType is the type basis for an enumerated type of command-line flags
 type FlagArgType int
 
 // IntFlag, Int64Flag, FloatFlag, StringFlag, and BoolFlag are the enumerated
-// types of scalar types of arguments declared on the command line
+// types of scalar types of arguments declared on the command line.  UnionFlag is
+// an argument whose value may be any of several of the scalar types, JSONFlag
+// is an argument whose value is JSON text, decoded into the structure it describes,
+// IntRangeListFlag is an argument whose value is a list of integers and ranges, e.g., "1-3,5",
+// BytesBase64Flag is an argument whose value is binary data encoded in base64, IntSliceFlag and
+// FloatSliceFlag are arguments whose values are lists of comma-separated ints or floats, e.g., "1,2,3",
+// FlexDurationFlag is an argument whose value is a time.Duration, given as "30s" or as seconds, "30",
+// and TypedMapFlag is an argument whose value is a map of key=value entries, e.g., "cpu=2,mem=512", see AddTypedMapFlag
 const (
 	IntFlag FlagArgType = iota
 	Int64Flag
 	FloatFlag
 	StringFlag
 	BoolFlag
+	UnionFlag
+	JSONFlag
+	IntRangeListFlag
+	BytesBase64Flag
+	IntSliceFlag
+	FloatSliceFlag
+	FlexDurationFlag
+	TypedMapFlag
 	None
 )
 
@@ -39,17 +65,101 @@ func FlagTypeString(type_name FlagArgType) string {
 		return "StringFlag"
 	case BoolFlag:
 		return "BoolFlag"
+	case UnionFlag:
+		return "UnionFlag"
+	case JSONFlag:
+		return "JSONFlag"
+	case IntRangeListFlag:
+		return "IntRangeListFlag"
+	case BytesBase64Flag:
+		return "BytesBase64Flag"
+	case IntSliceFlag:
+		return "IntSliceFlag"
+	case FloatSliceFlag:
+		return "FloatSliceFlag"
+	case FlexDurationFlag:
+		return "FlexDurationFlag"
+	case TypedMapFlag:
+		return "TypedMapFlag"
 	default:
 		return "None"
 	}
 }
 
+// Source is the type basis for an enumerated type of places a command variable's value can come from
+type Source int
+
+// SourceDefault, SourceFile, SourceEnv, and SourceCmdLine are the enumerated sources of a command variable's value.
+// A variable that was never set reports SourceDefault
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceEnv
+	SourceCmdLine
+)
+
+// defaultPrecedence orders the sources from lowest to highest precedence unless SetPrecedence says otherwise
+var defaultPrecedence = []Source{SourceDefault, SourceFile, SourceEnv, SourceCmdLine}
+
+// String converts a Source into a string representation
+func (src Source) String() string {
+	switch src {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "environment"
+	case SourceCmdLine:
+		return "command line"
+	default:
+		return "default"
+	}
+}
+
+// origin records where a command variable's value came from, including the
+// file name and line number when the value was read from a file, or the name of
+// the environment variable it was read from
+type origin struct {
+	source Source
+	file   string
+	line   int
+}
+
+// String renders the origin the way it is reported in WriteEffectiveConfig,
+// e.g., "from experiment.cfg:12", "environment $RUNS", "command line", or "default"
+func (at origin) String() string {
+	if at.source == SourceFile {
+		return "from " + at.position()
+	}
+	if at.source == SourceEnv && at.file != "" {
+		return "environment $" + at.file
+	}
+	return at.source.String()
+}
+
+// prefix gives what starts a message about a value from the origin, e.g., "experiment.cfg:12: " for a
+// value from a file, so that the message says where the value is, or "" for a value from elsewhere
+func (at origin) prefix() string {
+	if at.source != SourceFile {
+		return ""
+	}
+	return at.position() + ": "
+}
+
+// position gives the file and line of an origin in a file, e.g., "experiment.cfg:12", or just
+// the file when the whole file is the value
+func (at origin) position() string {
+	if at.line == 0 {
+		return at.file
+	}
+	return fmt.Sprintf("%s:%d", at.file, at.line)
+}
+
 // The arg interface defines what is needed for a type to
 // be used as a command line argument
 type arg interface {
 	ArgType() FlagArgType // what kind of argument is represented
 	Name() string         // name of the argument
-	Set(string)           // save the argument in the type's structure, extracted as a string from the command line
+	Set(string) error     // save the argument in the type's structure, extracted as a string from the command line
 	Get() any             // return the argument in its native form, which means the return type for the interface is 'any'
 	Loaded() bool         // has a flag with the specified name been set
 	Required() bool       // is this argument required
@@ -89,14 +199,29 @@ func (vs *intVar) Name() string {
 }
 
 // Set saves the type-specific represention of the command variable's string extracted from the command line
-func (vs *intVar) Set(value string) {
-	sv, err := strconv.ParseInt(value, 10, 64)
+func (vs *intVar) Set(value string) error {
+	sv, err := parseIntSized(value, strconv.IntSize)
 	if err != nil {
-		fmt.Println("Error setting integer flag variable")
-		return
+		return setError(vs.v_name, value, err)
 	}
 	vs.v_value = int(sv)
 	vs.v_loaded = true
+	return nil
+}
+
+// parseIntSized converts a string to an integer that fits in the number of bits given, strconv.IntSize for an int,
+// so that a value too big for an int on a 32-bit platform, e.g., 3000000000, is an *intRangeError rather than wrapping around
+func parseIntSized(value string, bits int) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, bits)
+	if errors.Is(err, strconv.ErrRange) {
+		return n, &intRangeError{value: value, bits: bits, err: err}
+	}
+	return n, err
+}
+
+// setError wraps what went wrong setting a command variable with the variable's name and the value it was given
+func setError(name string, value string, err error) error {
+	return fmt.Errorf("flag -%s cannot be set to %q: %w", name, value, err)
 }
 
 // Get returns the command variable's value with unspecified type
@@ -142,14 +267,14 @@ func (vs *int64Var) Name() string {
 }
 
 // Set saves the type-specific represention of the command value's string extracted from the command line
-func (vs *int64Var) Set(value string) {
-	sv, err := strconv.ParseInt(value, 10, 64)
+func (vs *int64Var) Set(value string) error {
+	sv, err := parseIntSized(value, 64)
 	if err != nil {
-		fmt.Println("Error seting integer flag variable")
-		return
+		return setError(vs.v_name, value, err)
 	}
 	vs.v_value = int64(sv)
 	vs.v_loaded = true
+	return nil
 }
 
 // Get returns the command variable's value with unspecified type
@@ -195,14 +320,14 @@ func (vs *floatVar) Name() string {
 }
 
 // Set saves the type-specific represention of the command value's string extracted from the command line
-func (vs *floatVar) Set(value string) {
+func (vs *floatVar) Set(value string) error {
 	v, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		fmt.Println("Error setting float in cmdline")
-		return
+		return setError(vs.v_name, value, err)
 	}
 	vs.v_value = v
 	vs.v_loaded = true
+	return nil
 }
 
 // Get returns the command variable's value with unspecified type
@@ -248,9 +373,10 @@ func (vs *stringVar) Name() string {
 }
 
 // Set saves the type-specific represention of the command value's string extracted from the command line
-func (vs *stringVar) Set(value string) {
+func (vs *stringVar) Set(value string) error {
 	vs.v_value = value
 	vs.v_loaded = true
+	return nil
 }
 
 // Get returns the command variable's value with unspecified type
@@ -295,14 +421,17 @@ func (vs *boolVar) Name() string {
 	return vs.v_name
 }
 
-// Set saves the type-specific represention of the command value's string extracted from the command line
-func (vs *boolVar) Set(value string) {
-	v := false
-	if value == "T" || value == "t" || value == "True" || value == "true" {
-		v = true
+// Set saves the type-specific represention of the command value's string extracted from the command line,
+// which must be one of the spellings strconv.ParseBool accepts: 1, t, T, TRUE, true, True for true, and
+// 0, f, F, FALSE, false, False for false.  A flag given without a value is true, so false must be spelled out
+func (vs *boolVar) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
 	}
 	vs.v_value = v
 	vs.v_loaded = true
+	return nil
 }
 
 // Get returns the command variable's value with unspecified type
@@ -321,21 +450,308 @@ func (vs *boolVar) Required() bool {
 
 }
 
+// flagInfo holds what the CmdParser knows about a command variable apart from its value
+type flagInfo struct {
+	origin  origin   // where the variable's current value came from
+	raw     string   // the text the variable's current value was converted from
+	literal string   // that text as it was written, with any quotes around it, see GetRaw
+	history []string // every raw value given to the variable, oldest first, when the CmdParser tracks history
+	secret  bool     // the value is never shown in output produced by the package
+
+	companion string // name of the flag whose value is a file holding this variable's value, if enabled
+
+	def     any                  // value GetVar returns when the variable is not loaded, if hasDef
+	hasDef  bool                 // a default was declared
+	defFile string               // file the default was read from by LoadDefaultsFromFile, which satisfies a required variable
+	defFunc func(*CmdParser) any // computes the default when there is no other, see AddFlagWithConditionalDefault
+
+	usage   string   // description of the variable shown in help
+	hidden  bool     // the variable is left out of help
+	aliases []string // other names by which the variable's flag may be given
+	env     string   // environment variable that ApplyEnv takes the value from
+	group   string   // group under which the variable is listed in help, if any
+
+	deprecated string // message telling users of a deprecated variable what to do instead, if deprecated
+	orDefault  bool   // the variable must be set unless it has a default, see RequiredOrDefault
+
+	consumed bool // the program has asked for the value, see Unconsumed
+	exempt   bool // the variable is left out of Unconsumed, see ExemptUnconsumed
+}
+
+// redacted is shown by the package in place of the value of a secret command variable
+const redacted = "****"
+
+// convertValue converts a string extracted from the command line into the native form of a type,
+// reporting an error if the string does not represent a value of that type
+func convertValue(arg_type FlagArgType, value string) (any, error) {
+	switch arg_type {
+	case IntFlag:
+		v, err := parseIntSized(value, strconv.IntSize)
+		return int(v), err
+	case Int64Flag:
+		return parseIntSized(value, 64)
+	case FloatFlag:
+		return strconv.ParseFloat(value, 64)
+	case StringFlag:
+		return value, nil
+	case BoolFlag:
+		return strconv.ParseBool(value)
+	case JSONFlag:
+		var v any
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err
+	case IntRangeListFlag:
+		return parseIntRanges(value)
+	case BytesBase64Flag:
+		return decodeBase64(value, false)
+	case IntSliceFlag:
+		return parseIntSlice(value)
+	case FloatSliceFlag:
+		return parseFloatSlice(value)
+	case FlexDurationFlag:
+		return parseFlexDuration(value)
+	default:
+		return nil, fmt.Errorf("no conversion to %s", FlagTypeString(arg_type))
+	}
+}
+
+// checkValue reports an error if a string extracted from the command line does not represent a value
+// the command variable can hold
+func checkValue(v arg, value string) error {
+	if lv, ok := v.(*lengthStringVar); ok {
+		return lv.checkLength(value)
+	}
+	if _, ok := v.(*logLevelVar); ok {
+		_, err := parseLogLevel(value)
+		return err
+	}
+	if bv, ok := v.(*bytesVar); ok {
+		_, err := decodeBase64(value, bv.v_url)
+		return err
+	}
+	if mv, ok := v.(*typedMapVar); ok {
+		_, err := mv.parseEntries(value)
+		return err
+	}
+	uv, ok := v.(*unionVar)
+	if !ok {
+		_, err := convertValue(v.ArgType(), value)
+		return err
+	}
+	for _, arg_type := range uv.v_types {
+		if _, err := convertValue(arg_type, value); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a value of any of the types of union flag -%s", value, uv.v_name)
+}
+
+// unionVar represents a command variable whose value may be of any of a list of scalar types
+type unionVar struct {
+	v_name   string
+	v_types  []FlagArgType
+	v_value  any
+	v_match  FlagArgType
+	v_req    bool
+	v_loaded bool
+}
+
+// createUnionVar is a constructor whose arguments give the argument a name, indicate whether it is required,
+// and list the types its value may take, in the order they are tried.
+func createUnionVar(name string, req bool, types []FlagArgType) *unionVar {
+	vs := &unionVar{v_name: name,
+		v_types:  types,
+		v_match:  None,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type UnionFlag
+func (vs *unionVar) ArgType() FlagArgType {
+	return UnionFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *unionVar) Name() string {
+	return vs.v_name
+}
+
+// Set saves the represention of the command value's string under the first of the variable's types that accepts it
+func (vs *unionVar) Set(value string) error {
+	for _, arg_type := range vs.v_types {
+		v, err := convertValue(arg_type, value)
+		if err == nil {
+			vs.v_value = v
+			vs.v_match = arg_type
+			vs.v_loaded = true
+			return nil
+		}
+	}
+	names := []string{}
+	for _, arg_type := range vs.v_types {
+		names = append(names, FlagTypeString(arg_type))
+	}
+	return setError(vs.v_name, value, fmt.Errorf("not a value of any of %s", strings.Join(names, ", ")))
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *unionVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line
+func (vs *unionVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *unionVar) Required() bool {
+	return vs.v_req
+
+}
+
 // A CmdParser struct maps the flag names of command variables to their type specific representations
 type CmdParser struct {
-	vars map[string]arg
+	vars        map[string]arg
+	info        map[string]*flagInfo
+	order       []string             // names of the variables in the order they were declared
+	aliases     map[string]string    // maps each alias to the name of the variable it stands for
+	implied     map[string]flagValue // maps each implied flag to the variable it sets and the value, see AddImpliedFlag
+	wildcards   []wildcard           // families of flags routed to handlers, see AddWildcardFlag
+	constraints []constraint         // restrictions on which variables are given together, see ValidateGroups
+
+	commands     map[string]*CmdParser  // CmdParsers of the commands of the program, see AddCommand
+	commandOrder []string               // names of the commands in the order they were declared
+	selected     string                 // name of the command selected by the last parse, if any
+	parent       *CmdParser             // CmdParser of which this is that of a command, if it is
+	name         string                 // name of the command whose CmdParser this is, if it is
+	summary      string                 // one line description of that command, shown in the list of commands
+	cmdAliases   map[string]string      // maps each alias of a command to the command's name, see AddCommandAlias
+	cmdDefault   string                 // command taken when none is named, see SetDefaultCommand
+	handler      func(*CmdParser) error // what Execute runs when this CmdParser's command is selected
+	runnable     bool                   // a command line need not name one of the commands, see SetRunnable
+
+	description string    // summary of the program shown in help
+	version     []string  // lines written when asked for the version, if SetVersion was called
+	examples    []example // examples of using the program shown in help, in the order added
+
+	modes []*mode // flags whose values select further flags, see WhenFlag
+
+	args []string // positional arguments left by the parses so far, see Args
+
+	// positionals is set by SetPositionalArity, after which a value that follows no flag is a positional argument,
+	// rather than an error, and "--" ends the flags.  A parse must leave between minPositional and maxPositional
+	// of them, with a maxPositional below 0 meaning no bound
+	positionals   bool
+	minPositional int
+	maxPositional int
+	passthrough   []string // the arguments after "--", see Passthrough
+
+	trace io.Writer // where the steps of a parse are traced, if anywhere, see SetTrace
+	echo  io.Writer // where the values are written after a parse succeeds, if anywhere, see SetEchoOnParse
+
+	groups     []string          // names of the groups of variables, in the order their sections appear in help
+	groupDescs map[string]string // text shown under a group's header in help
+
+	usageTemplate *template.Template // layout of help, if not the default
+	wrapWidth     int                // width to which help is wrapped, if not that of the terminal
+
+	output io.Writer // where messages are written, os.Stderr if nil
+	quiet  bool      // write no messages but the help and version asked for
+	logger Logger    // where warnings go instead of the output writer, if not nil
+
+	promptInput io.Reader // where PromptOnMissing reads values, if not os.Stdin
+
+	handling ErrorHandling // what Parse does when parsing fails, see NewCmdParserWithHandling
+
+	// AllowCommandSubstitution, when true, permits the value of a flag to be written as $(command), in which
+	// case the command is run by the shell and its standard output, trimmed of surrounding white space,
+	// becomes the value.  As in the shell, a $(command) in single quotes is left as it is.  It is off by
+	// default because it runs whatever the input names
+	AllowCommandSubstitution bool
+
+	// TrackHistory, when true, has the CmdParser remember every raw value given to each command variable,
+	// not just the last one, for retrieval by History
+	TrackHistory bool
+
+	// ConfigEnvVar, when not empty, names an environment variable that may hold the path of a file of flags.
+	// ParseFromCmdLine reads that file, if it exists, before the command line, which overrides it
+	ConfigEnvVar string
+
+	// EnvPrefix, when not empty, has a parse take the value of each command variable left without one from the
+	// environment variable named by the prefix, an underscore, and the variable's name in upper case with dashes
+	// made underscores, e.g., $MYAPP_MAX_COUNT for -max-count with prefix "MYAPP".  The command line overrides it
+	EnvPrefix string
+
+	// ColorUsage, when true, has Usage color flag names and required markers when it writes to a terminal
+	ColorUsage bool
+
+	// UsageOrder is the order in which help lists flags, Declaration by default or Alphabetical
+	UsageOrder FlagOrder
+
+	// PromptOnMissing, when true, has a parse ask for the values of required flags left without them, if
+	// standard input is a terminal, reading a line for each, see SetPromptInput
+	PromptOnMissing bool
+
+	// UnknownAsPositional, when true, has a parse take flags that are not declared, with the values that follow
+	// them, as positional arguments, returned by Args, rather than warn about them or fail
+	UnknownAsPositional bool
+
+	// SkipMalformedLines, when true, has a parse of a file of flags skip each line that cannot be parsed, e.g.,
+	// one with an unterminated quote or a value that follows no flag, with a warning naming the line, rather than
+	// fail, so that the lines that are well formed still apply.  The warnings are kept for SkippedLines
+	SkipMalformedLines bool
+	skipped            []string
+
+	// AllowReservedNames, when true, lets flags be declared with the names the package gives meanings of
+	// its own, "is", "h", "help", and "version", taking the place of those meanings, see CheckName
+	AllowReservedNames bool
+
+	// Trace, when true, has the CmdParser pass a note to its logger for each step of a parse, as SetTrace
+	// writes them, e.g., each token taken, the flag it matched or that it was not declared, and the value set
+	Trace bool
+
+	// precedence orders the sources of values from lowest to highest; nil means defaultPrecedence.
+	// It may not be changed once parsing has started
+	precedence []Source
+	started    bool
+
+	// frozen is set by Freeze, after which no more flags may be declared
+	frozen bool
+
+	// maxLine is the longest line, in bytes, read from a file of flags; 0 means DefaultMaxLineLength
+	maxLine int
+
+	// flagSets are the standard library FlagSets whose flags were imported, which are given the values parsed for them
+	flagSets []*flag.FlagSet
+
+	// bindings write the values parsed into the program's variables, see BindInt
+	bindings []binding
+
+	// auditLog records every assignment of a value when auditing is on
+	auditing bool
+	auditLog []AuditEntry
+
+	// once guards ParseOnce, which remembers the result of the one parse it performs
+	once    sync.Once
+	onceOK  bool
+	onceErr error
 }
 
 // NewCmdParser is a constructor, initializes an empty CmdParser data structure
 func NewCmdParser() *CmdParser {
 	empty_vars := make(map[string]arg)
-	cp := &CmdParser{vars: empty_vars}
+	cp := &CmdParser{vars: empty_vars, info: make(map[string]*flagInfo), aliases: make(map[string]string),
+		implied: make(map[string]flagValue), groupDescs: make(map[string]string), handling: ExitOnError}
 	return cp
 }
 
 // AddFlag includes a new command flag to the parser.  The arguments give
 // the type of the flag in enumerated type form, the name of the flag, and whether the flag is required
 func (cp *CmdParser) AddFlag(arg_type FlagArgType, arg_name string, arg_req bool) {
+	cp.checkFrozen("AddFlag", arg_name)
+	cp.checkName("AddFlag", arg_name)
 
 	// for each type of command argument call the constructor for that type and save the
 	// result (indexed by command argument name) in the CmdParser's 'vars' map
@@ -364,219 +780,2077 @@ func (cp *CmdParser) AddFlag(arg_type FlagArgType, arg_name string, arg_req bool
 		v := createBoolVar(arg_name, arg_req)
 		cp.vars[arg_name] = v
 		break
+
+	case JSONFlag:
+		v := createJSONVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	case IntRangeListFlag:
+		v := createIntRangeListVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	case BytesBase64Flag:
+		v := createBytesVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	case IntSliceFlag:
+		v := createIntSliceVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	case FloatSliceFlag:
+		v := createFloatSliceVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	case FlexDurationFlag:
+		v := createFlexDurationVar(arg_name, arg_req)
+		cp.vars[arg_name] = v
+		break
+
+	default:
+		return
 	}
+	cp.declared(arg_name)
 }
 
-// SetVar calls an arg interface function with a command variable name and string-encoded value
-// from the command line to set the value in the type-specific struct.
-func (cp *CmdParser) SetVar(name string, value string) {
-	cp.vars[name].Set(value)
+// Freeze ends the declarations of the CmdParser, so that declaring a flag, alias, command, or mode
+// afterwards panics, catching a declaration made too late, e.g., by code run after the flags are parsed.
+// Parsing and getting values are unaffected, and the configure function of a mode, see WhenFlag, may still declare flags
+func (cp *CmdParser) Freeze() {
+	cp.frozen = true
 }
 
-// GetVar returns the type-unspecified value of a command variable that was created in the CmdParser,
-// and given a value from the command line.  It is assumed that the application calls IsLoaded
-// before GetVar to ascertain that a value is indeed present
-func (cp *CmdParser) GetVar(name string) any {
-	gv, present := cp.vars[name]
-	if present {
-		return gv.Get()
-	}
-	msg := fmt.Sprintf("CmdParser.GetVar given unrecognized variable name %s\n", name)
-	panic(msg)
+// IsFrozen returns whether Freeze has been called on the CmdParser
+func (cp *CmdParser) IsFrozen() bool {
+	return cp.frozen
 }
 
-// IsFlag returns a bool indicating whether the input argument string 'name'
-// has been used to create a command variable in the CmdParser
-func (cp *CmdParser) IsFlag(name string) bool {
-	_, present := cp.vars[name]
-	return present
+// checkFrozen panics if the CmdParser is frozen, naming the method that was given the name to declare
+func (cp *CmdParser) checkFrozen(method string, name string) {
+	if cp.frozen {
+		panic(fmt.Sprintf("CmdParser.%s given %s after Freeze\n", method, name))
+	}
 }
 
-// IsLoaded returns a bool indicating whether a command variable with the input argument
-// string 'name' was recognized on the command line and so had a value stored
-func (cp *CmdParser) IsLoaded(name string) bool {
-	if !cp.IsFlag(name) {
-		return false
+// declared sets up what the CmdParser knows about a command variable just put in its 'vars' map,
+// and keeps the order in which variables were first declared
+func (cp *CmdParser) declared(arg_name string) {
+	if _, present := cp.info[arg_name]; !present {
+		cp.order = append(cp.order, arg_name)
 	}
-	return cp.vars[name].Loaded()
+	cp.info[arg_name] = &flagInfo{}
 }
 
-// IsRequired returns a bool indicating whether a command variable with the input argument
-// 'name' was declared to be required
-func (cp *CmdParser) IsRequired(name string) bool {
-	if !cp.IsFlag(name) {
-		return false
+// AddFlagWithDefault includes a new command flag to the parser, as AddFlag does, along with a default value
+// that GetVar returns when the flag is not loaded.  The default is given in the flag's native form, e.g., an int
+// for an IntFlag, or as any value whose printed form converts to that, e.g., 5 for an Int64Flag or "8" for an IntFlag.
+// A BoolFlag defaulting to true is turned off with "-no-name", which works for every BoolFlag.
+// A required flag may not have a default, which could never be used
+func (cp *CmdParser) AddFlagWithDefault(arg_type FlagArgType, arg_name string, arg_req bool, def any) {
+	if arg_req {
+		panic(fmt.Sprintf("CmdParser.AddFlagWithDefault given a default for required variable %s\n", arg_name))
 	}
-	return cp.vars[name].Required()
+	cp.AddFlag(arg_type, arg_name, arg_req)
+	if !cp.IsFlag(arg_name) {
+		return
+	}
+	v, err := nativeDefault(arg_type, def)
+	if err != nil {
+		panic(fmt.Sprintf("CmdParser.AddFlagWithDefault given default %v not of type %s for variable %s\n",
+			def, FlagTypeString(arg_type), arg_name))
+	}
+	cp.info[arg_name].def = v
+	cp.info[arg_name].hasDef = true
 }
 
-type flagValue struct {
-	flag  string
-	value string
+// nativeDefault converts a default given in a flag's native form, or as any value whose printed form
+// converts to that, to the native form
+func nativeDefault(arg_type FlagArgType, def any) (any, error) {
+	text, ok := def.(string)
+	if !ok {
+		text = formatValue(arg_type, def)
+	}
+	return convertValue(arg_type, text)
 }
 
-func argIsNumber(arg string) bool {
-	_, err := strconv.ParseFloat(arg, 64) 
-	return err == nil
+// AddFlagWithConditionalDefault includes a new command flag to the parser, as AddFlag does, whose default
+// depends on other flags, e.g., -port defaulting to 443 when -tls is given and to 80 otherwise:
+//
+//	cp.AddFlagWithConditionalDefault(IntFlag, "port", false, func(cp *CmdParser) any {
+//		if cp.GetVar("tls").(bool) {
+//			return 443
+//		}
+//		return 80
+//	})
+//
+// The function is called each time GetVar is asked for the value of the flag when it has none, so it sees
+// the values parsed by then.  It returns the default as AddFlagWithDefault takes it.  A default read by
+// LoadDefaultsFromFile takes the place of the function.  A required flag may not have a default
+func (cp *CmdParser) AddFlagWithConditionalDefault(arg_type FlagArgType, arg_name string, arg_req bool, def func(cp *CmdParser) any) {
+	if arg_req {
+		panic(fmt.Sprintf("CmdParser.AddFlagWithConditionalDefault given a default for required variable %s\n", arg_name))
+	}
+	cp.AddFlag(arg_type, arg_name, arg_req)
+	if !cp.IsFlag(arg_name) {
+		return
+	}
+	if def == nil {
+		panic(fmt.Sprintf("CmdParser.AddFlagWithConditionalDefault given no default function for variable %s\n", arg_name))
+	}
+	cp.info[arg_name].defFunc = def
 }
 
-// ParseFromString separates the command line string into individual command statements
-// and stores them in the CmdParser
-func (cp *CmdParser) ParseFromString(cmd_string string) bool {
-
-	// break up the input string by white space
-	pieces := strings.Fields(cmd_string)
-
-	// some of the arguments may be only flags (indicating value true), so
-	// scan the list first to create flag-value pairs
-	cmdVar := make([]flagValue, 0)
+// conditionalDefault calls the function that computes the default of a command variable and converts what it returns
+func (cp *CmdParser) conditionalDefault(name string) any {
+	arg_type := cp.vars[name].ArgType()
+	def := cp.info[name].defFunc(cp)
+	v, err := nativeDefault(arg_type, def)
+	if err != nil {
+		panic(fmt.Sprintf("CmdParser.GetVar given default %v not of type %s for variable %s by its default function\n",
+			def, FlagTypeString(arg_type), name))
+	}
+	return v
+}
 
-	idx := 0
-	for idx < len(pieces) {
-		// piece[idx] needs to have a flag
-		if !strings.HasPrefix(pieces[idx], "-") {	
-			panic(fmt.Errorf("Command line parsing error from %s\n", pieces[idx:]))
+// CheckConsistency reports declarations that contradict each other, so that some parse or default could
+// never succeed: a flag that is required but also has a default, which could then never be used, e.g., as
+// restored by LoadState; a flag that is both required and deprecated; a default, e.g., one read by LoadState,
+// that a string flag with bounds on its length, or a log-level flag, cannot hold; two required flags declared
+// mutually exclusive; and a mode, see WhenFlag, selected by a value its flag can never hold.  It is meant to
+// be run as a sanity check at startup, once all flags are declared, and Parse runs it before parsing
+func (cp *CmdParser) CheckConsistency() error {
+	problems := []string{}
+	for _, name := range cp.sortedNames() {
+		v, info := cp.vars[name], cp.info[name]
+		if v.Required() && (info.hasDef || info.defFunc != nil) && info.defFile == "" && !info.orDefault {
+			problems = append(problems, fmt.Sprintf("flag -%s is required but has a default", name))
+		}
+		if v.Required() && info.deprecated != "" {
+			problems = append(problems, fmt.Sprintf("flag -%s is required but deprecated", name))
 		}
 
-		// whether the argument is a solo flag or has a value depends on the next piece
-		if (idx==len(pieces)-1) || strings.HasPrefix(pieces[idx+1],"-") && !argIsNumber(pieces[idx+1]) {
-			fv := flagValue{flag: strings.Replace(pieces[idx], "-", "", 1), value: "true"}
-			cmdVar = append(cmdVar, fv)
-			idx += 1
+		// the bounds of a string's length, and the names of the log levels, are checked only as a value is set
+		_, bounded := v.(*lengthStringVar)
+		_, level := v.(*logLevelVar)
+		if def, ok := info.def.(string); ok && info.hasDef && (bounded || level) {
+			if err := checkValue(v, def); err != nil {
+				problems = append(problems, fmt.Sprintf("flag -%s has default %q, which it cannot hold: %v", name, def, err))
+			}
+		}
+	}
+	for _, c := range cp.constraints {
+		if c.kind != exclusiveGroup {
 			continue
 		}
-		fv := flagValue{flag: strings.Replace(pieces[idx], "-", "", 1), value: pieces[idx+1]}
-		cmdVar = append(cmdVar, fv)
-		idx += 2
+		required := []string{}
+		for _, name := range c.names {
+			if cp.vars[name].Required() {
+				required = append(required, name)
+			}
+		}
+		if len(required) > 1 {
+			problems = append(problems, fmt.Sprintf("flags %s are required but mutually exclusive", flagList(required)))
+		}
 	}
-
-	// check that all the flags obtained have been declared for the CmdParser
-	errMsg := []string{}
-	for _, fv := range cmdVar {
-		_, present := cp.vars[fv.flag]
-		if !present {
-			errMsg = append(errMsg, "-"+fv.flag)
+	for _, m := range cp.modes {
+		v := cp.vars[m.flag]
+		if err := checkValue(v, m.value); err != nil {
+			problems = append(problems, fmt.Sprintf("mode -%s %s can never be selected: %v", m.flag, m.value, err))
+		} else if value, err := convertValue(v.ArgType(), m.value); err == nil && formatValue(v.ArgType(), value) != m.value {
+			problems = append(problems, fmt.Sprintf("mode -%s %s can never be selected, as the value is written %s",
+				m.flag, m.value, formatValue(v.ArgType(), value)))
 		}
 	}
-
-	if len(errMsg) > 0 {
-		msg := fmt.Sprintf("Flags not declared in CmdParser: %s, ignored", strings.Join(errMsg, ","))
-		fmt.Println(msg)
-		// return false
+	if len(problems) > 0 {
+		return fmt.Errorf("inconsistent flag declarations: %s", strings.Join(problems, "; "))
 	}
+	return nil
+}
 
-	// now set the variables
-	for _, fv := range cmdVar {
-		_, present := cp.vars[fv.flag]
-		if present {
-			cp.SetVar(fv.flag, fv.value)
-		}
-	}
+// reservedNames are the flag names to which the package gives meanings of its own, each with that meaning
+var reservedNames = map[string]string{
+	"is":      "reading a file of flags",
+	"h":       "help",
+	"help":    "help",
+	"version": "the version",
+}
 
-	// and finally, ensure that every variable that is required is present
-	errMsg = []string{}
-	for name, value := range cp.vars {
-		if value.Required() && !value.Loaded() {
-			errMsg = append(errMsg, "-"+name)
-		}
+// CheckName returns an error saying which rule a flag name breaks, or nil if it may be declared.  A name may
+// not be empty, begin with '-', which the flag is given with rather than declared with, or hold white space,
+// '=', which separates a flag from its value, or '#', which begins a comment.  Nor may it be one of the
+// reserved names "is", "h", "help", and "version", unless AllowReservedNames is set
+func (cp *CmdParser) CheckName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("flag name is empty")
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("flag name %q begins with '-', which the flag is given with, not declared with", name)
+	case strings.IndexFunc(name, unicode.IsSpace) >= 0:
+		return fmt.Errorf("flag name %q holds white space", name)
+	case strings.Contains(name, "="):
+		return fmt.Errorf("flag name %q holds '=', which separates a flag from its value", name)
+	case strings.Contains(name, "#"):
+		return fmt.Errorf("flag name %q holds '#', which begins a comment", name)
 	}
+	if meaning, reserved := reservedNames[name]; reserved && !cp.AllowReservedNames {
+		return fmt.Errorf("flag name %q is reserved for %s; set AllowReservedNames to declare it", name, meaning)
+	}
+	return nil
+}
 
-	if len(errMsg) > 0 {
-		msg := fmt.Sprintf("Flags required but missing: %s", strings.Join(errMsg, ","))
-		fmt.Println(msg)
-		return false
+// CheckNames returns, for each declared variable name, alias, and implied flag, the error CheckName returns for it,
+// nil for a name that may be declared, to audit the names of a program, e.g., one with AllowReservedNames set
+func (cp *CmdParser) CheckNames() map[string]error {
+	checked := make(map[string]error)
+	for _, name := range cp.order {
+		checked[name] = cp.CheckName(name)
 	}
-	return true
+	for alias := range cp.aliases {
+		checked[alias] = cp.CheckName(alias)
+	}
+	for short := range cp.implied {
+		checked[short] = cp.CheckName(short)
+	}
+	return checked
 }
 
-// ParseFromCmdLine gets the command line string from os.Args, i.e., the run-time command line
-func (cp *CmdParser) ParseFromCmdLine() bool {
+// checkName panics if a name given to the method to declare is not a valid flag name, saying which rule it breaks
+func (cp *CmdParser) checkName(method string, name string) {
+	if err := cp.CheckName(name); err != nil {
+		panic(fmt.Sprintf("CmdParser.%s given invalid name: %v\n", method, err))
+	}
+}
 
-	// join the already parsed command line pieces with white space to create a single string
-	cmd_str := strings.Join(os.Args[1:], " ")
+// AddUnionFlag includes a new command flag to the parser whose value may be of any of the listed scalar
+// types.  A value from the command line is stored as the first of the types, in the order listed, that accepts it,
+// e.g., with types IntFlag and StringFlag "-id 5" stores the int 5 while "-id abc" stores the string "abc"
+func (cp *CmdParser) AddUnionFlag(arg_name string, arg_req bool, types ...FlagArgType) {
+	cp.checkFrozen("AddUnionFlag", arg_name)
+	cp.checkName("AddUnionFlag", arg_name)
+	if len(types) == 0 {
+		panic(fmt.Sprintf("CmdParser.AddUnionFlag given no types for variable %s\n", arg_name))
+	}
+	for _, arg_type := range types {
+		if arg_type < IntFlag || arg_type > BoolFlag {
+			panic(fmt.Sprintf("CmdParser.AddUnionFlag given non-scalar type %s for variable %s\n", FlagTypeString(arg_type), arg_name))
+		}
+	}
+	cp.vars[arg_name] = createUnionVar(arg_name, arg_req, append([]FlagArgType{}, types...))
+	cp.declared(arg_name)
+}
 
-	// parse that string
-	return cp.ParseFromString(cmd_str)
+// MatchedType returns the type under which the value of the union command variable with the
+// input argument 'name' was stored, or None if it has no value or is not a union
+func (cp *CmdParser) MatchedType(name string) FlagArgType {
+	uv, ok := cp.vars[name].(*unionVar)
+	if !ok || !uv.Loaded() {
+		return None
+	}
+	return uv.v_match
 }
 
-// ParseFromFile gets the command line flags from a file. This enables separation across lines
-// and comments
-func (cp *CmdParser) ParseFromFile(filename string) bool {
+// SetVar calls an arg interface function with a command variable name and string-encoded value
+// from the command line to set the value in the type-specific struct.  It returns a *BadValueError
+// for a value the variable cannot hold, which leaves the variable as it was, and an error wrapping
+// ErrUnknownName if no variable has that name
+func (cp *CmdParser) SetVar(name string, value string) error {
+	if !cp.IsFlag(name) {
+		return cp.unknownName(name)
+	}
+	return cp.setVar(name, value, origin{source: SourceCmdLine})
+}
 
-	// open the file
-	inFile, err := os.Open(filename)
-	if err != nil {
-		fmt.Println("Cannot open command line file")
+// Unset clears the value of the command variable with the input argument 'name', so that it is no longer
+// loaded and GetVar returns its default, if it has one, e.g., to ask for the value again.  It reports
+// whether there is such a variable
+func (cp *CmdParser) Unset(name string) bool {
+	v, present := cp.vars[name]
+	if !present {
 		return false
 	}
-	defer inFile.Close()
-
-	// read the file line by line, skipping empty lines and commented lines
-	cmd_string := ""
-	scanner := bufio.NewScanner(inFile)
-	for scanner.Scan() {
-
-		// line by line
-		nxt_line := scanner.Text()
+	switch vs := v.(type) {
+	case *intVar:
+		vs.v_value, vs.v_loaded = 0, false
+		break
+	case *int64Var:
+		vs.v_value, vs.v_loaded = 0, false
+		break
+	case *floatVar:
+		vs.v_value, vs.v_loaded = 0, false
+		break
+	case *stringVar:
+		vs.v_value, vs.v_loaded = "", false
+		break
+	case *lengthStringVar:
+		vs.v_value, vs.v_loaded = "", false
+		break
+	case *logLevelVar:
+		vs.v_value, vs.v_loaded = "", false
+		break
+	case *boolVar:
+		vs.v_value, vs.v_loaded = false, false
+		break
+	case *unionVar:
+		vs.v_value, vs.v_match, vs.v_loaded = nil, None, false
+		break
+	case *jsonVar:
+		vs.v_value, vs.v_loaded = nil, false
+		break
+	case *intRangeListVar:
+		vs.v_value, vs.v_loaded = nil, false
+		break
+	case *bytesVar:
+		vs.v_value, vs.v_loaded = nil, false
+		break
+	case *intSliceVar:
+		vs.v_value, vs.v_loaded = nil, false
+		break
+	case *floatSliceVar:
+		vs.v_value, vs.v_loaded = nil, false
+		break
+	case *flexDurationVar:
+		vs.v_value, vs.v_loaded = 0, false
+		break
+	case *typedMapVar:
+		vs.v_value, vs.v_loaded = make(map[string]any), false
+		break
+	}
+	cp.info[name].origin = origin{}
+	return true
+}
 
-		// skip empty lines
-		if string(nxt_line) == "" {
-			continue
+// SetPrecedence orders the sources of values from lowest to highest precedence, so that when two sources
+// supply a value for the same command variable the value from the higher one is kept, regardless of which
+// was read first.  The order must name each Source exactly once.  The default order is
+// SourceDefault, SourceFile, SourceEnv, SourceCmdLine.  The order cannot be changed after parsing has begun
+func (cp *CmdParser) SetPrecedence(order []Source) error {
+	if cp.started {
+		return fmt.Errorf("SetPrecedence called after parsing has begun")
+	}
+	if len(order) != len(defaultPrecedence) {
+		return fmt.Errorf("SetPrecedence given %d sources, needs each of the %d sources once", len(order), len(defaultPrecedence))
+	}
+	seen := make(map[Source]bool)
+	for _, src := range order {
+		if src < SourceDefault || src > SourceCmdLine {
+			return fmt.Errorf("SetPrecedence given unknown source %d", int(src))
 		}
-
-		// remove anything after a '#'
-		whitespace := 0
-		for idx := 0; idx < len(nxt_line); idx++ {
-
-			// move the whitespace marker up
-			if string(nxt_line[idx]) == "" || string(nxt_line[idx]) == "\t" {
-				whitespace += 1
-			} else if string(nxt_line[idx]) == "#" {
-				// hit a comment character.  Is there nothing but white space to the left?
-				if whitespace == idx {
-
-					// yes, so this line is empty
-					nxt_line = ""
-					break
-				} else {
-					// there is stuff to parse before the comment character
-					nxt_line = nxt_line[whitespace:idx]
-					break
-				}
-			}
+		if seen[src] {
+			return fmt.Errorf("SetPrecedence given source %s more than once", src)
 		}
+		seen[src] = true
+	}
+	cp.precedence = append([]Source{}, order...)
+	return nil
+}
 
-		if nxt_line != "" {
-			// get rid of "\n" if present
-			nxt_line = strings.Replace(nxt_line, "\n", "", 1)
-			cmd_string = cmd_string + " " + nxt_line
+// rank gives the position of a source in the CmdParser's order of precedence, higher ranks winning
+func (cp *CmdParser) rank(src Source) int {
+	order := cp.precedence
+	if order == nil {
+		order = defaultPrecedence
+	}
+	for idx, ordered := range order {
+		if ordered == src {
+			return idx
 		}
 	}
-	return cp.ParseFromString(cmd_string)
+	return -1
 }
 
-// Parse looks for a leading "-is" on the command line to determine whether to
-// parse from a file (e.g., "-is" is present), or get the arguments from the command line itself
-func (cp *CmdParser) Parse() bool {
+// setVar sets the value of a command variable and, if the value was accepted, remembers where it came from.
+// A value is ignored if the variable already holds one from a source of higher precedence.  It returns
+// a *BadValueError for a value the variable cannot hold, leaving the variable as it was
+func (cp *CmdParser) setVar(name string, value string, at origin) error {
+	return cp.setVarAs(name, value, value, at)
+}
 
-	// see if the command line is empty and if so flag the error
-	if len(os.Args) == 1 {
-		fmt.Println("call requires command line arguments")
-		os.Exit(1)
+// setVarAs sets the value of a command variable as setVar does, given also the value as it was written, e.g., with quotes
+func (cp *CmdParser) setVarAs(name string, value string, literal string, at origin) error {
+	cp.started = true
+	v := cp.vars[name]
+	if v.Loaded() && cp.rank(at.source) < cp.rank(cp.info[name].origin.source) {
+		cp.record(name, value, at, true)
+		cp.tracef("-%s value %s (%s) ignored, keeping value %s (%s)\n", name, cp.traceValue(name, value), at,
+			cp.display(name, v.Get()), cp.info[name].origin)
+		return nil
 	}
 
-	// see if the command line points to a file
-	parsedOK := true
-	if len(os.Args) > 1 && os.Args[1] == "-is" {
-		// parse from the file
-		cmdfile := os.Args[2]
-		parsedOK = cp.ParseFromFile(cmdfile)
-	} else {
-		parsedOK = cp.ParseFromCmdLine()
+	// check the value before setting it, so that what was wrong can be returned
+	if err := checkValue(v, value); err != nil {
+		bad := cp.valueError(name, value, err, at)
+		cp.tracef("-%s value %s (%s) rejected: %v\n", name, cp.traceValue(name, value), at, bad)
+		return bad
+	}
+	replaced := ""
+	if v.Loaded() {
+		replaced = fmt.Sprintf(", replacing value %s (%s)", cp.display(name, v.Get()), cp.info[name].origin)
 	}
 
-	if !parsedOK {
-		panic("Command line parsing error")
+	// a list or map adds each value given it from one source, but a value from another, which has higher precedence,
+	// replaces what the last source gave
+	if v.Loaded() && accumulates(v) && at.source != cp.info[name].origin.source {
+		cp.Unset(name)
 	}
-	return true
+	if err := v.Set(value); err != nil {
+		bad := cp.valueError(name, value, err, at)
+		cp.tracef("-%s value %s (%s) rejected: %v\n", name, cp.traceValue(name, value), at, bad)
+		return bad
+	}
+	cp.record(name, value, at, false)
+	if cp.TrackHistory {
+		cp.info[name].history = append(cp.info[name].history, value)
+	}
+	if !v.Loaded() {
+		cp.tracef("-%s value %s (%s) left no value, e.g., an empty list\n", name, cp.traceValue(name, value), at)
+		return nil
+	}
+	cp.info[name].origin = at
+	cp.info[name].raw = value
+	cp.info[name].literal = literal
+	cp.tracef("-%s value %s (%s) converted to %s%s\n", name, cp.traceValue(name, value), at,
+		cp.display(name, v.Get()), replaced)
+	return nil
+}
+
+// GetVar returns the type-unspecified value of a command variable that was created in the CmdParser,
+// and given a value from the command line.  It is assumed that the application calls IsLoaded
+// before GetVar to ascertain that a value is indeed present, unless the variable has a default,
+// which GetVar returns when no value was given
+func (cp *CmdParser) GetVar(name string) any {
+	_, present := cp.vars[name]
+	if present {
+		cp.info[name].consumed = true
+		return cp.value(name)
+	}
+	msg := fmt.Sprintf("CmdParser.GetVar given unrecognized variable name %s%s\n", name, cp.nameHint(name))
+	panic(msg)
+}
+
+// GetVarE returns the value of a command variable as GetVar does, or an error wrapping ErrUnknownName,
+// rather than a panic, if no variable has that name
+func (cp *CmdParser) GetVarE(name string) (any, error) {
+	if !cp.IsFlag(name) {
+		return nil, cp.unknownName(name)
+	}
+	cp.info[name].consumed = true
+	return cp.value(name), nil
+}
+
+// value returns the value of a declared command variable, or its default if it is not loaded and has one
+func (cp *CmdParser) value(name string) any {
+	v := cp.vars[name]
+	if !v.Loaded() && cp.info[name].hasDef {
+		return cp.info[name].def
+	}
+	if !v.Loaded() && cp.info[name].defFunc != nil {
+		return cp.conditionalDefault(name)
+	}
+	return v.Get()
+}
+
+// GetDefault returns the default declared for a command variable by AddFlagWithDefault, and whether
+// one was declared.  Unlike GetVar, it does not depend on whether a value was given.  A default
+// declared by AddFlagWithConditionalDefault is computed from the values held now
+func (cp *CmdParser) GetDefault(name string) (any, bool) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.GetDefault given unrecognized variable name %s\n", name))
+	}
+	info := cp.info[name]
+	if !info.hasDef && info.defFunc != nil {
+		return cp.conditionalDefault(name), true
+	}
+	return info.def, info.hasDef
+}
+
+// SetUsage gives the command variable with the input argument 'name' a description shown in help
+func (cp *CmdParser) SetUsage(name string, usage string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetUsage given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].usage = usage
+}
+
+// SetDescription gives the summary of the program shown in help, after the usage line
+func (cp *CmdParser) SetDescription(description string) {
+	cp.description = description
+}
+
+// SetHidden leaves the command variable with the input argument 'name' out of help, though its
+// flag is still accepted
+func (cp *CmdParser) SetHidden(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetHidden given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].hidden = true
+}
+
+// AddAlias lets the flag of the command variable with the input argument 'name' also be given as
+// "-alias", e.g., a short form.  The alias may not be the name of a variable or of another alias
+func (cp *CmdParser) AddAlias(name string, alias string) {
+	cp.checkFrozen("AddAlias", alias)
+	cp.checkName("AddAlias", alias)
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.AddAlias given unrecognized variable name %s\n", name))
+	}
+	if cp.IsFlag(alias) {
+		panic(fmt.Sprintf("CmdParser.AddAlias given alias %s, which is the name of a variable\n", alias))
+	}
+	if _, present := cp.aliases[alias]; present {
+		panic(fmt.Sprintf("CmdParser.AddAlias given alias %s, which is already an alias\n", alias))
+	}
+	if _, present := cp.implied[alias]; present {
+		panic(fmt.Sprintf("CmdParser.AddAlias given alias %s, which is an implied flag\n", alias))
+	}
+	cp.aliases[alias] = name
+	cp.info[name].aliases = append(cp.info[name].aliases, alias)
+}
+
+// RequiredOrDefault makes the command variable with the input argument 'name' required unless it has a default,
+// declared by AddFlagWithDefault or AddFlagWithConditionalDefault or read by LoadDefaultsFromFile, which then
+// satisfies the requirement.  Without a default, a parse that leaves the variable unset fails as for any required
+// variable.  The variable is declared as not required, since a required variable may not be declared with a default
+func (cp *CmdParser) RequiredOrDefault(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.RequiredOrDefault given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].orDefault = true
+}
+
+// SetDeprecated marks the command variable with the input argument 'name' as deprecated.  Its flag still
+// works, but giving it prints the message, which should say what to use instead, and help marks it
+func (cp *CmdParser) SetDeprecated(name string, message string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetDeprecated given unrecognized variable name %s\n", name))
+	}
+	if message == "" {
+		message = "it will be removed"
+	}
+	cp.info[name].deprecated = message
+}
+
+// AddImpliedFlag declares a flag "-short" that holds no value of its own but, when given, sets the
+// command variable with the name 'target' to the value, e.g., "-q" standing for "-loglevel quiet".
+// The short flag may not be the name of a variable or an alias
+func (cp *CmdParser) AddImpliedFlag(short string, target string, value string) {
+	cp.checkFrozen("AddImpliedFlag", short)
+	cp.checkName("AddImpliedFlag", short)
+	if !cp.IsFlag(target) {
+		panic(fmt.Sprintf("CmdParser.AddImpliedFlag given unrecognized variable name %s\n", target))
+	}
+	if _, present := cp.aliases[short]; present || cp.IsFlag(short) {
+		panic(fmt.Sprintf("CmdParser.AddImpliedFlag given flag %s, which is already declared\n", short))
+	}
+	if err := checkValue(cp.vars[target], value); err != nil {
+		panic(fmt.Sprintf("CmdParser.AddImpliedFlag given value %s, which variable %s cannot hold\n", value, target))
+	}
+	cp.implied[short] = flagValue{flag: target, value: value}
+}
+
+// wildcard is a family of flags sharing a prefix whose values go to a handler, see AddWildcardFlag
+type wildcard struct {
+	prefix  string
+	handler func(suffix, value string) error
+}
+
+// AddWildcardFlag routes every flag whose name begins with the prefix, e.g., "-Xmx 512" for prefix "X",
+// to the handler, given the rest of the name, "mx", and the value, rather than reporting the flag as
+// not declared.  The flag of a variable or alias is never routed, and where prefixes overlap the
+// longest that matches wins.  An error from the handler is reported as a value that cannot be parsed is,
+// by a *BadValueError among the errors the parse returns
+func (cp *CmdParser) AddWildcardFlag(prefix string, handler func(suffix, value string) error) {
+	cp.checkFrozen("AddWildcardFlag", prefix)
+	if prefix == "" {
+		panic("CmdParser.AddWildcardFlag given an empty prefix\n")
+	}
+	cp.wildcards = append(cp.wildcards, wildcard{prefix: prefix, handler: handler})
+}
+
+// wildcardFor returns the wildcard whose prefix is the longest to begin a flag, or nil if none does
+func (cp *CmdParser) wildcardFor(flag string) *wildcard {
+	var found *wildcard
+	for idx := range cp.wildcards {
+		wc := &cp.wildcards[idx]
+		if strings.HasPrefix(flag, wc.prefix) && (found == nil || len(wc.prefix) > len(found.prefix)) {
+			found = wc
+		}
+	}
+	return found
+}
+
+// negated reports whether a flag is "-no-name" for a boolean variable 'name', which it sets to false,
+// returning the name.  A variable declared as "no-name" is itself and not a negation
+func (cp *CmdParser) negated(flag string) (string, bool) {
+	if !strings.HasPrefix(flag, "no-") || cp.IsFlag(cp.resolve(flag)) {
+		return "", false
+	}
+	name := cp.resolve(strings.TrimPrefix(flag, "no-"))
+	if !cp.IsFlag(name) || cp.vars[name].ArgType() != BoolFlag {
+		return "", false
+	}
+	return name, true
+}
+
+// takesNoValue reports whether a flag never takes the piece after it as its value, as is so of
+// implied flags and negated boolean flags
+func (cp *CmdParser) takesNoValue(flag string) bool {
+	if _, implied := cp.implied[flag]; implied {
+		return true
+	}
+	_, negated := cp.negated(flag)
+	return negated
+}
+
+// boolBeforePositional reports whether a flag is that of a BoolFlag and the piece after it is not a boolean
+// value, which, with positional arguments declared, makes the piece a positional argument rather than the flag's value
+func (cp *CmdParser) boolBeforePositional(flag string, piece token) bool {
+	name := cp.resolve(flag)
+	if !cp.positionals || !cp.IsFlag(name) || cp.vars[name].ArgType() != BoolFlag {
+		return false
+	}
+	_, err := strconv.ParseBool(piece.text)
+	return err != nil
+}
+
+// resolve returns the name of the variable for which a flag name stands, which is the flag name
+// itself unless it is an alias
+func (cp *CmdParser) resolve(flag string) string {
+	if name, present := cp.aliases[flag]; present {
+		return name
+	}
+	return flag
+}
+
+// sortedNames returns the names of all declared command variables in alphabetical order
+func (cp *CmdParser) sortedNames() []string {
+	names := make([]string, 0, len(cp.vars))
+	for name := range cp.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetSecret marks the command variable with the input argument 'name' as holding a secret, e.g., a password,
+// so that its value is shown as "****" everywhere the package renders values.  GetVar still returns the real value
+func (cp *CmdParser) SetSecret(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetSecret given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].secret = true
+}
+
+// MarkSensitive marks the command variable with the input argument 'name' as sensitive, which is the
+// same as marking it secret with SetSecret: its value is shown as "****" in every output of the package,
+// e.g., WriteEffectiveConfig and the audit log, while GetVar still returns it
+func (cp *CmdParser) MarkSensitive(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.MarkSensitive given unrecognized variable name %s\n", name))
+	}
+	cp.SetSecret(name)
+}
+
+// IsSecret returns a bool indicating whether the command variable with the input argument 'name'
+// was marked by SetSecret
+func (cp *CmdParser) IsSecret(name string) bool {
+	if !cp.IsFlag(name) {
+		return false
+	}
+	return cp.info[name].secret
+}
+
+// display renders a value of the command variable with the input argument 'name' as the package shows it
+// in output, which for a secret variable is the redaction mask
+func (cp *CmdParser) display(name string, value any) string {
+	if cp.IsSecret(name) {
+		return redacted
+	}
+	return formatValue(cp.vars[name].ArgType(), value)
+}
+
+// formatValue renders a value of a command variable of the given type as text that sets the variable to it
+func formatValue(arg_type FlagArgType, value any) string {
+	if arg_type == JSONFlag {
+		text, err := json.Marshal(value)
+		if err == nil {
+			return string(text)
+		}
+	}
+	if list, ok := value.([]int); ok && arg_type == IntRangeListFlag {
+		return formatIntRanges(list)
+	}
+	if data, ok := value.([]byte); ok && arg_type == BytesBase64Flag {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	if arg_type == IntSliceFlag || arg_type == FloatSliceFlag {
+		return formatSlice(value)
+	}
+	if entries, ok := value.(map[string]any); ok && arg_type == TypedMapFlag {
+		return formatTypedMap(entries)
+	}
+	return fmt.Sprint(value)
+}
+
+// EnableFileCompanion declares, for the command variable with the input argument 'name', a companion
+// string flag "-name-file" whose value is the path of a file holding the variable's value, e.g., a
+// mounted secret.  The file's contents, less a trailing newline, become the variable's value.
+// The variable and its companion may not both be given
+func (cp *CmdParser) EnableFileCompanion(name string) {
+	cp.checkFrozen("EnableFileCompanion", name)
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.EnableFileCompanion given unrecognized variable name %s\n", name))
+	}
+	companion := name + "-file"
+	if cp.IsFlag(companion) {
+		panic(fmt.Sprintf("CmdParser.EnableFileCompanion cannot declare %s, which is already declared\n", companion))
+	}
+	cp.AddFlag(StringFlag, companion, false)
+	cp.info[name].companion = companion
+}
+
+// loadCompanions reads the files named by companion flags among the flag-value pairs given, and sets the
+// variables they accompany.  It is an error for a variable and its companion to both be given
+func (cp *CmdParser) loadCompanions(given map[string]flagValue) error {
+	for _, name := range cp.order {
+		info := cp.info[name]
+		if info.companion == "" {
+			continue
+		}
+		fv, present := given[info.companion]
+		if !present {
+			continue
+		}
+		if _, both := given[name]; both {
+			return fmt.Errorf("flags -%s and -%s are mutually exclusive", name, info.companion)
+		}
+
+		contents, err := os.ReadFile(fv.value)
+		if err != nil {
+			return fmt.Errorf("flag -%s cannot read file %s: %w", info.companion, fv.value, err)
+		}
+		value := strings.TrimSuffix(strings.TrimSuffix(string(contents), "\n"), "\r")
+		if err := cp.setVar(name, value, origin{source: SourceFile, file: fv.value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History returns, in the order they were given, every raw value assigned to the command variable
+// with the input argument 'name' while TrackHistory was on.  GetVar still returns only the last.  A value
+// ignored for one of higher precedence, or rejected as one the variable cannot hold, was not assigned
+func (cp *CmdParser) History(name string) []string {
+	if !cp.IsFlag(name) {
+		return nil
+	}
+	history := make([]string, len(cp.info[name].history))
+	copy(history, cp.info[name].history)
+	return history
+}
+
+// IsFlag returns a bool indicating whether the input argument string 'name'
+// has been used to create a command variable in the CmdParser
+func (cp *CmdParser) IsFlag(name string) bool {
+	_, present := cp.vars[name]
+	return present
+}
+
+// FlagType returns the enumerated type of the command variable with the input argument 'name',
+// and whether there is such a variable
+func (cp *CmdParser) FlagType(name string) (FlagArgType, bool) {
+	v, present := cp.vars[name]
+	if !present {
+		return None, false
+	}
+	return v.ArgType(), true
+}
+
+// ValuesOfType returns the values of all loaded command variables of the type, e.g., StringFlag, indexed by name,
+// for processing a category of flags together.  Variables holding only their defaults are left out, as are union
+// variables unless the type is UnionFlag, whatever type their values matched
+func (cp *CmdParser) ValuesOfType(arg_type FlagArgType) map[string]any {
+	values := make(map[string]any)
+	for name, v := range cp.vars {
+		if v.ArgType() == arg_type && v.Loaded() {
+			values[name] = cp.value(name)
+		}
+	}
+	return values
+}
+
+// FlagTypeName returns the name of the type of the command variable with the input argument 'name',
+// as FlagTypeString gives it, e.g., "IntFlag", and whether there is such a variable
+func (cp *CmdParser) FlagTypeName(name string) (string, bool) {
+	arg_type, present := cp.FlagType(name)
+	if !present {
+		return "", false
+	}
+	return FlagTypeString(arg_type), true
+}
+
+// IsLoaded returns a bool indicating whether a command variable with the input argument
+// string 'name' was recognized on the command line and so had a value stored
+func (cp *CmdParser) IsLoaded(name string) bool {
+	if !cp.IsFlag(name) {
+		return false
+	}
+	return cp.vars[name].Loaded()
+}
+
+// WasSet returns a bool indicating whether the command variable with the input argument string 'name' was
+// given a value by the user, from the command line, a file, the environment, or a prompt, rather than holding
+// a default, whether applied by ApplyDefaults or returned by GetVar in place of a value
+func (cp *CmdParser) WasSet(name string) bool {
+	if !cp.IsLoaded(name) {
+		return false
+	}
+	return cp.info[name].origin.source != SourceDefault
+}
+
+// HasValue returns a bool indicating whether the command variable with the input argument string 'name'
+// has a value for GetVar to return, either one the user set, see WasSet, or a default
+func (cp *CmdParser) HasValue(name string) bool {
+	if !cp.IsFlag(name) {
+		return false
+	}
+	info := cp.info[name]
+	return cp.vars[name].Loaded() || info.hasDef || info.defFunc != nil
+}
+
+// GetRaw returns the text from which the value of the command variable with the input argument 'name' was
+// converted, as it was given before conversion, with any quotes around it, e.g., "1-3,5" for an
+// IntRangeListFlag holding [1 2 3 5] or "'/data/run 1'" for -path '/data/run 1', and whether the variable is loaded
+func (cp *CmdParser) GetRaw(name string) (string, bool) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.GetRaw given unrecognized variable name %s\n", name))
+	}
+	if !cp.vars[name].Loaded() {
+		return "", false
+	}
+	return cp.info[name].literal, true
+}
+
+// IsEmpty returns a bool indicating whether a command variable with the input argument string 'name'
+// was loaded from an explicitly empty value, e.g., -name "", as opposed to one not given at all, for which
+// IsLoaded is false too
+func (cp *CmdParser) IsEmpty(name string) bool {
+	if !cp.IsLoaded(name) {
+		return false
+	}
+	return cp.info[name].raw == ""
+}
+
+// IsRequired returns a bool indicating whether a command variable with the input argument
+// 'name' was declared to be required
+func (cp *CmdParser) IsRequired(name string) bool {
+	if !cp.IsFlag(name) {
+		return false
+	}
+	return cp.vars[name].Required()
+}
+
+// AmbiguousPrefixes maps every prefix that could abbreviate more than one declared flag name to the
+// (sorted) names it matches, e.g., flags "verbose" and "version" share the prefixes "v", "ve", and "ver".
+// A prefix that is itself a declared name is not ambiguous, as an exact match is always preferred
+func (cp *CmdParser) AmbiguousPrefixes() map[string][]string {
+	matches := make(map[string][]string)
+	for name := range cp.vars {
+		for end := 1; end <= len(name); end++ {
+			prefix := name[:end]
+			matches[prefix] = append(matches[prefix], name)
+		}
+	}
+
+	ambiguous := make(map[string][]string)
+	for prefix, names := range matches {
+		if len(names) < 2 || cp.IsFlag(prefix) {
+			continue
+		}
+		sort.Strings(names)
+		ambiguous[prefix] = names
+	}
+	return ambiguous
+}
+
+// token is one white-space separated piece of a command line, together with where it was found.
+// A quoted piece is always a value, even if it begins with a '-'
+type token struct {
+	text    string
+	at      origin
+	quoted  bool
+	argAt   int    // one more than the index of the piece among the arguments parsed, or 0 if it is not one of them
+	literal string // the piece as it was written, quotes and all, if that differs from text
+}
+
+// written returns the piece as it was written, quotes and all
+func (t token) written() string {
+	if t.literal != "" {
+		return t.literal
+	}
+	return t.text
+}
+
+// isQuote reports whether a character opens and closes a quoted piece
+func isQuote(r rune) bool {
+	return r == '"' || r == '\''
+}
+
+// commentAt returns the index in a line of a file of flags of the '#' that begins a comment, or -1 if there
+// is none.  A '#' within a quoted piece, e.g., -color "#fff", is part of the value, since tokenize reads
+// the quotes, which are those that begin a piece or follow the '=' of a flag
+func commentAt(line string) int {
+	runes := []rune(line)
+	idx := 0
+	for idx < len(runes) {
+		if unicode.IsSpace(runes[idx]) {
+			idx += 1
+			continue
+		}
+		start := idx
+		if runes[idx] == '-' {
+			for idx < len(runes) && runes[idx] != '=' && runes[idx] != '#' && !unicode.IsSpace(runes[idx]) {
+				idx += 1
+			}
+			if idx+1 < len(runes) && runes[idx] == '=' && isQuote(runes[idx+1]) {
+				idx += 1
+			}
+		}
+		if idx < len(runes) && isQuote(runes[idx]) && (idx == start || runes[idx-1] == '=') {
+			closing := idx + 1
+			for closing < len(runes) && runes[closing] != runes[idx] {
+				closing += 1
+			}
+			idx = closing + 1
+		}
+
+		// the rest of the piece is not quoted
+		for idx < len(runes) && !unicode.IsSpace(runes[idx]) {
+			if runes[idx] == '#' {
+				return len(string(runes[:idx]))
+			}
+			idx += 1
+		}
+	}
+	return -1
+}
+
+// tokenize breaks a string up by white space, attributing every piece to the same origin.
+// A piece beginning with a quote runs to the matching quote, white space and all, and is
+// stored without the quotes, as is a quoted value given to a flag with '=', e.g., -name="a b".
+// Quotes anywhere else are left as they are, e.g., in the JSON text {"a":1}
+func tokenize(str string, at origin) []token {
+	runes := []rune(str)
+	tokens := []token{}
+	idx := 0
+	for {
+		for idx < len(runes) && unicode.IsSpace(runes[idx]) {
+			idx += 1
+		}
+		if idx == len(runes) {
+			break
+		}
+
+		// a flag given its value with '=' may quote the value
+		begin := idx
+		prefix := ""
+		if runes[idx] == '-' {
+			end := idx
+			for end < len(runes) && runes[end] != '=' && !unicode.IsSpace(runes[end]) {
+				end += 1
+			}
+			if end+1 < len(runes) && runes[end] == '=' && isQuote(runes[end+1]) {
+				prefix = string(runes[idx : end+1])
+				idx = end + 1
+			}
+		}
+
+		// a quoted piece runs to the closing quote, or to the end if there is none
+		text := ""
+		quoted := false
+		if isQuote(runes[idx]) {
+			closing := idx + 1
+			for closing < len(runes) && runes[closing] != runes[idx] {
+				closing += 1
+			}
+			text = string(runes[idx+1 : closing])
+			quoted = prefix == ""
+			idx = closing + 1
+			if idx > len(runes) {
+				idx = len(runes)
+			}
+		}
+
+		// anything else runs to the next white space
+		start := idx
+		for idx < len(runes) && !unicode.IsSpace(runes[idx]) {
+			idx += 1
+		}
+		text += string(runes[start:idx])
+		piece := token{text: prefix + text, at: at, quoted: quoted}
+		if literal := string(runes[begin:idx]); literal != piece.text {
+			piece.literal = literal
+		}
+		tokens = append(tokens, piece)
+	}
+	return tokens
+}
+
+// flagValue is a flag paired with its value, and where the value came from
+type flagValue struct {
+	flag    string
+	value   string
+	literal string // the value as it was written, quotes and all
+	at      origin
+	raw     []string // the pieces of the command line the pair was made from, e.g., ["-n", "5"] or ["-n=5"]
+}
+
+func argIsNumber(arg string) bool {
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+// ParseString separates the command line string into individual command statements
+// and stores them in the CmdParser, returning what went wrong, if anything.  A value that begins
+// with a '-' may be given as -name=value, or quoted, e.g., -pattern "-x"
+func (cp *CmdParser) ParseString(cmd_string string) error {
+
+	// break up the input string by white space
+	pieces := tokenize(cmd_string, origin{source: SourceCmdLine})
+	for idx := range pieces {
+		pieces[idx].argAt = idx + 1
+	}
+	return cp.parseTokens(pieces)
+}
+
+// ParseFromString parses the command line string as ParseString does, reporting any error to the
+// output writer and returning whether parsing succeeded
+func (cp *CmdParser) ParseFromString(cmd_string string) bool {
+	if err := cp.ParseString(cmd_string); err != nil {
+		cp.reportf("%v\n", err)
+		return false
+	}
+	return true
+}
+
+// ParseFromMap parses a map from flag names to values, e.g., configuration an embedding program already holds,
+// as though each flag were given on the command line with its value, without building a command line to parse.
+// A name may be given with or without its leading '-'.  Every name must be declared, and every required variable
+// must then have a value.  It returns whether parsing succeeded, and what went wrong if it did not, see MultiError
+func (cp *CmdParser) ParseFromMap(m map[string]string) (bool, error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pr := cp.newPairer()
+	for _, name := range names {
+		flag := "-" + strings.TrimLeft(name, "-")
+		pr.pair(token{text: flag, at: origin{source: SourceCmdLine}}, token{text: m[name]}, []string{flag, m[name]})
+	}
+
+	// the keys not declared, even by the modes their values select, are errors rather than warnings
+	pr.selectModes()
+	if len(pr.unknown) > 0 {
+		pr.note(pr.unknownError())
+		pr.unknown = nil
+	}
+	if err := pr.finish(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// runSubstitution runs the command of a $(command) value, returning its output trimmed of surrounding white space
+func runSubstitution(cmd_text string) (string, error) {
+	command := strings.TrimSuffix(strings.TrimPrefix(cmd_text, "$("), ")")
+	output, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("command substitution %s failed: %v: %s", cmd_text, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("command substitution %s failed: %v", cmd_text, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// pairer matches flags with their values as the pieces of a command line arrive one at a time,
+// setting each command variable as soon as its value is known.  Since at most one piece is held
+// back, a source of any size is parsed without first being gathered up whole
+type pairer struct {
+	cp      *CmdParser
+	pending *token               // a flag whose value, if it has one, is the next piece
+	subst   []token              // the pieces so far of a $(command) value that white space split apart
+	given   map[string]flagValue // the last flag-value pair seen for each declared flag
+	unknown []flagValue          // flags seen that were not declared, in the order seen
+
+	fileNext string          // the --flags-file or -is just seen, whose value, the next piece, names a file
+	reading  map[string]bool // files of flags being read, which may not include themselves
+	defaults bool            // the values are only gathered in 'given', to become defaults
+	dry      bool            // the values are only checked, see VerifyExamples, so no variable is set and no file read
+	list     *token          // the flag of a list variable just given a value, which takes the values that follow
+	problems []error         // what went wrong so far, reported together when the parse ends
+	failed   map[string]bool // variables given a value they cannot hold, so not to be reported missing as well
+	taken    []token         // the positional arguments of this parse, which SetPositionalArity bounds
+	rest     bool            // "--" was seen on the command line, so every piece after it is passed through
+}
+
+// listed notes a flag just given a value, which takes the values that follow if its variable is a list
+func (pr *pairer) listed(flag token) {
+	pr.list = nil
+	if pr.cp.isList(pr.cp.resolve(strings.Replace(flag.text, "-", "", 1))) {
+		pr.list = &flag
+	}
+}
+
+// flagsFile is the argument that, followed by the name of a file, reads the flags in that file in its place,
+// and isFile is another spelling of it, unless a variable named "is" is declared
+const flagsFile = "--flags-file"
+const isFile = "-is"
+
+// namesFile reports whether a flag, e.g., "--flags-file", is one whose value names a file of flags to read in its place
+func (cp *CmdParser) namesFile(flag string) bool {
+	return flag == flagsFile || (flag == isFile && !cp.IsFlag(cp.resolve("is")))
+}
+
+// newPairer starts a parse into the CmdParser
+func (cp *CmdParser) newPairer() *pairer {
+	cp.started = true
+	return &pairer{cp: cp, given: make(map[string]flagValue), reading: make(map[string]bool), failed: make(map[string]bool)}
+}
+
+// note records a problem found during the parse, which carries on so that every problem is found
+func (pr *pairer) note(err error) {
+	if err != nil {
+		pr.problems = append(pr.problems, err)
+	}
+}
+
+// substText joins the pieces of a $(command) value back together as they were written, quotes and all, so that
+// the shell reads the command as it was given.  A value wholly in double quotes, e.g., "$(echo a  b)", is given
+// without them, as the shell would take it
+func (pr *pairer) substText() string {
+	cmd_pieces := make([]string, len(pr.subst))
+	for idx, piece := range pr.subst {
+		cmd_pieces[idx] = piece.written()
+	}
+	if first := pr.subst[0]; first.quoted && strings.HasPrefix(first.literal, `"`) {
+		cmd_pieces[0] = first.text
+	}
+	return strings.Join(cmd_pieces, " ")
+}
+
+// substEnd returns the index just past the ')' that closes the $(command) the text begins with, passing over
+// parentheses that are quoted or nested, or -1 if the text does not close it
+func substEnd(text string) int {
+	depth := 0
+	var quote rune
+	for idx, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			break
+		case isQuote(r):
+			quote = r
+			break
+		case r == '(':
+			depth += 1
+			break
+		case r == ')':
+			depth -= 1
+			if depth == 0 {
+				return idx + 1
+			}
+			break
+		}
+	}
+	return -1
+}
+
+// dropSubstituted forgets the flag whose $(command) value failed, so that it is not taken for a flag with no value,
+// and notes its variable as failed, so that it is not reported missing as well
+func (pr *pairer) dropSubstituted() {
+	if pr.pending == nil {
+		return
+	}
+	name := pr.cp.resolve(strings.Replace(pr.pending.text, "-", "", 1))
+	if pr.cp.IsFlag(name) {
+		pr.failed[name] = true
+	}
+	pr.pending = nil
+}
+
+// add takes the next piece of the command line
+func (pr *pairer) add(piece token) error {
+
+	// replace a $(command) value with the output of the command, if that is allowed, gathering up pieces
+	// through the one holding the ')' that closes the substitution, whose text after it, if any, follows the
+	// output, e.g., "hix" for $(echo hi)x.  Only the value of a flag is replaced, and, as in the shell, not one
+	// in single quotes
+	single_quoted := piece.quoted && strings.HasPrefix(piece.literal, "'")
+	is_value := pr.pending != nil || pr.list != nil
+	if pr.cp.AllowCommandSubstitution && (len(pr.subst) > 0 || (strings.HasPrefix(piece.text, "$(") && is_value && !single_quoted)) {
+		pr.subst = append(pr.subst, piece)
+		cmd_text := pr.substText()
+		end := substEnd(cmd_text)
+		if end < 0 {
+			return nil
+		}
+		at := pr.subst[0].at
+		pr.subst = nil
+		output, err := runSubstitution(cmd_text[:end])
+		if err != nil {
+			pr.dropSubstituted()
+			return fmt.Errorf("%s%w", at.prefix(), err)
+		}
+		piece = token{text: output + cmd_text[end:], at: at}
+	}
+
+	pr.traceToken(piece)
+
+	// after "--" every piece is passed through, see Passthrough
+	if pr.rest {
+		if !pr.dry {
+			pr.cp.passthrough = append(pr.cp.passthrough, piece.text)
+		}
+		return nil
+	}
+
+	// the piece after a --flags-file names the file
+	if pr.fileNext != "" {
+		pr.fileNext = ""
+		return pr.include(piece.text)
+	}
+
+	// some of the arguments may be only flags (indicating value true), so whether a waiting
+	// flag is solo or has a value depends on this piece
+	if pr.pending != nil {
+		flag := *pr.pending
+		pr.pending = nil
+		name := strings.Replace(flag.text, "-", "", 1)
+		if !pr.cp.takesNoValue(name) && !pr.cp.boolBeforePositional(name, piece) &&
+			(!strings.HasPrefix(piece.text, "-") || argIsNumber(piece.text) || piece.quoted) {
+			pr.pair(flag, piece, []string{flag.text, piece.text})
+			pr.listed(flag)
+			return nil
+		}
+		pr.pair(flag, token{text: "true"}, []string{flag.text})
+	}
+
+	// the flag of a list variable takes every value that follows it, e.g., "-tag a b c", up to the next flag
+	if pr.list != nil && (!strings.HasPrefix(piece.text, "-") || argIsNumber(piece.text) || piece.quoted) {
+		pr.pair(*pr.list, piece, []string{piece.text})
+		return nil
+	}
+	pr.list = nil
+
+	// with positional arguments declared, "--" on the command line ends the flags
+	if pr.cp.positionals && piece.text == "--" && !piece.quoted && piece.at.source == SourceCmdLine {
+		pr.rest = true
+		return nil
+	}
+
+	// a --flags-file reads the flags of a file in its place
+	if !piece.quoted && pr.cp.namesFile(piece.text) {
+		pr.fileNext = piece.text
+		return nil
+	}
+	if eq := strings.Index(piece.text, "="); !piece.quoted && eq > 0 && pr.cp.namesFile(piece.text[:eq]) {
+		return pr.include(piece.text[eq+1:])
+	}
+
+	// a flag given as -name=value carries its value, whatever that begins with
+	if strings.HasPrefix(piece.text, "-") && !piece.quoted && !argIsNumber(piece.text) {
+		if eq := strings.Index(piece.text, "="); eq > 0 {
+			flag := token{text: piece.text[:eq], at: piece.at}
+			value := token{text: piece.text[eq+1:]}
+			if piece.literal != "" {
+				value.literal = piece.literal[eq+1:]
+			}
+			pr.pair(flag, value, []string{piece.text})
+			pr.listed(flag)
+			return nil
+		}
+	}
+
+	// otherwise the piece needs to have a flag, unless it can be a positional argument
+	if (!strings.HasPrefix(piece.text, "-") || piece.quoted) && pr.cp.positionals {
+		pr.taken = append(pr.taken, piece)
+		if !pr.dry {
+			pr.cp.args = append(pr.cp.args, piece.text)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(piece.text, "-") || piece.quoted {
+		e := &FormatError{Token: piece.text, Index: piece.argAt - 1}
+		if piece.at.source == SourceFile {
+			e.Position = piece.at.position()
+			e.Index = -1
+		}
+		return e
+	}
+	pr.pending = &piece
+	return nil
+}
+
+// include reads the flags in a file named by --flags-file, as though they appeared in its place
+func (pr *pairer) include(filename string) error {
+	if pr.dry {
+		return nil
+	}
+	if pr.reading[filename] {
+		return fmt.Errorf("%s %s includes itself", flagsFile, filename)
+	}
+	pr.reading[filename] = true
+	defer delete(pr.reading, filename)
+	return pr.cp.feedFile(filename, pr)
+}
+
+// pair sets the variable of a flag to its value, or notes that the flag was not declared.  The raw
+// pieces are those the pair was made from, kept for an undeclared flag in case it is to be passed on as it was
+func (pr *pairer) pair(flag token, value token, raw []string) {
+
+	// an implied flag stands for its variable and value, whatever value it was given,
+	// and likewise a negated boolean flag stands for its variable and false
+	if implied, present := pr.cp.implied[strings.Replace(flag.text, "-", "", 1)]; present {
+		pr.cp.tracef("flag %s stands for -%s %s\n", flag.text, implied.flag, pr.cp.traceValue(implied.flag, implied.value))
+		pr.pair(token{text: "-" + implied.flag, at: flag.at}, token{text: implied.value}, raw)
+		return
+	}
+	if name, negated := pr.cp.negated(strings.Replace(flag.text, "-", "", 1)); negated {
+		pr.cp.tracef("flag %s stands for -%s false\n", flag.text, name)
+		pr.pair(token{text: "-" + name, at: flag.at}, token{text: "false"}, raw)
+		return
+	}
+	fv := flagValue{flag: pr.cp.resolve(strings.Replace(flag.text, "-", "", 1)), value: value.text, literal: value.written(),
+		at: flag.at, raw: raw}
+	if wc := pr.cp.wildcardFor(fv.flag); wc != nil && !pr.cp.IsFlag(fv.flag) {
+		pr.cp.tracef("flag %s matched wildcard -%s\n", flag.text, wc.prefix)
+		if pr.dry {
+			return
+		}
+		if err := wc.handler(strings.TrimPrefix(fv.flag, wc.prefix), fv.value); err != nil {
+			e := &BadValueError{Flag: fv.flag, Value: fv.value, Err: err, detail: err.Error()}
+			if fv.at.source == SourceFile {
+				e.Position = fv.at.position()
+			}
+			pr.note(e)
+		}
+		return
+	}
+	if !pr.cp.IsFlag(fv.flag) {
+		pr.cp.tracef("flag %s not declared\n", flag.text)
+		pr.unknown = append(pr.unknown, fv)
+		return
+	}
+	pr.cp.tracef("flag %s matched variable -%s\n", flag.text, fv.flag)
+	if message := pr.cp.info[fv.flag].deprecated; message != "" && !pr.dry {
+		pr.cp.warnf("%sFlag -%s is deprecated: %s\n", fv.at.prefix(), fv.flag, message)
+	}
+	pr.given[fv.flag] = fv
+	if pr.defaults {
+		return
+	}
+	if pr.dry {
+		if err := checkValue(pr.cp.vars[fv.flag], fv.value); err != nil {
+			pr.note(pr.cp.valueError(fv.flag, fv.value, err, fv.at))
+		}
+		return
+	}
+	if err := pr.cp.setVarAs(fv.flag, fv.value, fv.literal, fv.at); err != nil {
+		pr.note(err)
+		pr.failed[fv.flag] = true
+	}
+}
+
+// positional appends the flags seen that were not declared, with their values, to the positional arguments
+// as they were given, and forgets them
+func (pr *pairer) positional() {
+	for _, fv := range pr.unknown {
+		pr.cp.args = append(pr.cp.args, fv.raw...)
+	}
+	pr.unknown = nil
+}
+
+// Args returns the positional arguments the parses so far left, in the order given.  These are the values
+// that follow no flag, once SetPositionalArity is called, and the flags that were not declared, with their values,
+// when UnknownAsPositional is set, e.g., ["-x", "5"] for "-x 5", which a wrapper may pass on to the program it runs
+func (cp *CmdParser) Args() []string {
+	return append([]string{}, cp.args...)
+}
+
+// SetPositionalArity declares that the program takes positional arguments, values that follow no flag, e.g.,
+// the input files of "prog -v a.txt b.txt", and that a parse must leave between min and max of them, with
+// max -1 for no bound.  The arguments after a "--" on the command line are not flags or positional arguments,
+// but are passed through, see Passthrough, and do not count.  The flags that UnknownAsPositional passes on do not count either
+func (cp *CmdParser) SetPositionalArity(min int, max int) {
+	if min < 0 || (max >= 0 && max < min) {
+		panic(fmt.Sprintf("CmdParser.SetPositionalArity given bounds %d to %d\n", min, max))
+	}
+	cp.positionals = true
+	cp.minPositional = min
+	cp.maxPositional = max
+}
+
+// Passthrough returns the arguments that followed "--" on the command line, in the order given, once
+// SetPositionalArity is called, e.g., the command line of a program to run
+func (cp *CmdParser) Passthrough() []string {
+	return append([]string{}, cp.passthrough...)
+}
+
+// arityError returns an ArityError if the parse left fewer or more positional arguments than SetPositionalArity allows
+func (pr *pairer) arityError() error {
+	cp := pr.cp
+	got := len(pr.taken)
+	if !cp.positionals || (got >= cp.minPositional && (cp.maxPositional < 0 || got <= cp.maxPositional)) {
+		return nil
+	}
+	e := &ArityError{Min: cp.minPositional, Max: cp.maxPositional, Received: got}
+	if cp.maxPositional >= 0 && got > cp.maxPositional {
+		for _, piece := range pr.taken[cp.maxPositional:] {
+			e.Extra = append(e.Extra, piece.text)
+		}
+	}
+	return e
+}
+
+// unknownList lists the flags seen that were not declared, with where each was found in a file
+func (pr *pairer) unknownList() string {
+	flags := []string{}
+	for _, fv := range pr.unknown {
+		flag := "-" + fv.flag
+		if fv.at.source == SourceFile {
+			flag += " (" + fv.at.position() + ")"
+		}
+		flags = append(flags, flag)
+	}
+	return strings.Join(flags, ",")
+}
+
+// flush ends a source of pieces, so that a flag still waiting for a value is taken to have none
+func (pr *pairer) flush() error {
+	if len(pr.subst) > 0 {
+		cmd_text := pr.substText()
+		at := pr.subst[0].at
+		pr.subst = nil
+		pr.dropSubstituted()
+		return fmt.Errorf("%sunterminated command substitution %s", at.prefix(), cmd_text)
+	}
+	if pr.fileNext != "" {
+		flag := pr.fileNext
+		pr.fileNext = ""
+		return fmt.Errorf("%s needs the name of a file", flag)
+	}
+	if pr.pending != nil {
+		pr.pair(*pr.pending, token{text: "true"}, []string{pr.pending.text})
+		pr.pending = nil
+	}
+	return nil
+}
+
+// finish ends the parse, filling in variables whose values come from elsewhere and checking
+// that every required variable then has a value.  It returns every problem found, see MultiError
+func (pr *pairer) finish() error {
+	cp := pr.cp
+	pr.note(pr.flush())
+
+	// let the modes selected declare their flags, and take up those among the flags not declared
+	pr.selectModes()
+
+	// take the values of variables still without one from the environment, if EnvPrefix asks for that
+	pr.applyEnvPrefix()
+
+	// report the flags obtained that were not declared for the CmdParser, or pass them on if asked to
+	if len(pr.unknown) > 0 && cp.UnknownAsPositional {
+		pr.positional()
+	} else if len(pr.unknown) > 0 {
+		pr.note(pr.unknownError())
+	}
+
+	// fill in the variables whose values are in files named by their companion flags
+	pr.note(cp.loadCompanions(pr.given))
+
+	// ensure that every variable that is required is present, asking for any that are not if allowed.
+	// A variable given a value it cannot hold was reported already, so it is not reported missing too
+	if cp.PromptOnMissing {
+		cp.promptMissing()
+	}
+
+	// pass values, including those just asked for, on to the standard library FlagSets they were imported from,
+	// and to the program's variables bound to flags
+	pr.note(cp.writeFlagSets())
+	cp.writeBindings()
+	pr.note(cp.missingRequired(pr.failed))
+	pr.note(pr.arityError())
+	for _, violation := range cp.groupViolations(pr.failed) {
+		pr.note(fmt.Errorf("flag constraint violated: %s", violation))
+	}
+	if len(pr.problems) > 0 {
+		return combineErrors(pr.problems)
+	}
+
+	// leave a record of the values the program runs with, if asked to
+	if cp.echo != nil {
+		return cp.WriteEffectiveConfig(cp.echo)
+	}
+	return nil
+}
+
+// parseTokens pairs flags with their values, and stores them in the CmdParser
+func (cp *CmdParser) parseTokens(pieces []token) error {
+	pr := cp.newPairer()
+	for _, piece := range pieces {
+		pr.note(pr.add(piece))
+	}
+	return pr.finish()
+}
+
+// ErrHelpRequested is returned by ParseFromArgs when the arguments ask for help with "-h", "-help",
+// or "--help".  Usage has then been written, and a program will usually just exit with status 0
+var ErrHelpRequested = errors.New("help requested")
+
+// ErrVersionRequested is returned by ParseFromArgs when SetVersion has been called and the arguments
+// ask for the version with "-version" or "--version".  The version has then been written
+var ErrVersionRequested = errors.New("version requested")
+
+// helpSpellings are the arguments taken to ask for help, and versionSpellings those taken to ask
+// for the version, unless declared as flags of their own
+var helpSpellings = []string{"-h", "-help", "--help"}
+var versionSpellings = []string{"-version", "--version"}
+
+// SetVersion gives the version of the program, which ParseFromArgs writes when asked with "-version"
+// or "--version", followed by any extra lines, e.g., the commit and build date.  Until SetVersion is
+// called those arguments are not special
+func (cp *CmdParser) SetVersion(version string, extra ...string) {
+	cp.version = append([]string{version}, extra...)
+}
+
+// asksFor reports whether any of the arguments is one of the spellings.  A spelling whose flag the program
+// declared, e.g., a variable named "help", is left for the program, as are the arguments following "--"
+func (cp *CmdParser) asksFor(args []string, spellings []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		for _, spelling := range spellings {
+			if arg == spelling && !cp.IsFlag(cp.resolve(strings.Replace(arg, "-", "", 1))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetOutput gives the writer to which the CmdParser writes its messages, i.e., warnings, complaints
+// about values, errors reported by methods that do not return them, help, and the version.  By default
+// it is os.Stderr, so that the messages stay out of the data a program writes to its standard output
+func (cp *CmdParser) SetOutput(w io.Writer) {
+	cp.output = w
+}
+
+// SetQuiet, given true, has the CmdParser write no messages save the help and version asked for,
+// leaving errors to be learned from what its methods return.  A logger given to SetLogger still gets warnings
+func (cp *CmdParser) SetQuiet(quiet bool) {
+	cp.quiet = quiet
+}
+
+// out returns the writer to which the CmdParser writes its messages, which for a command is by default that of the CmdParser above it
+func (cp *CmdParser) out() io.Writer {
+	if cp.output == nil && cp.parent != nil {
+		return cp.parent.out()
+	}
+	if cp.output == nil {
+		return os.Stderr
+	}
+	return cp.output
+}
+
+// reportf writes a message reporting an error to the output writer, unless quiet
+func (cp *CmdParser) reportf(format string, args ...any) {
+	if cp.quiet {
+		return
+	}
+	fmt.Fprintf(cp.out(), format, args...)
+}
+
+// warnf passes a warning to the logger, if there is one, and otherwise writes it to the output writer, unless quiet
+func (cp *CmdParser) warnf(format string, args ...any) {
+	if cp.logger != nil {
+		cp.logger.Warnf(strings.TrimSuffix(format, "\n"), args...)
+		return
+	}
+	cp.reportf(format, args...)
+}
+
+// infof passes a note on what the CmdParser did to the logger, if there is one
+func (cp *CmdParser) infof(format string, args ...any) {
+	if cp.logger != nil {
+		cp.logger.Infof(strings.TrimSuffix(format, "\n"), args...)
+	}
+}
+
+// ParseFromArgs parses the flags in a list of arguments, e.g., os.Args[1:].  Each argument is
+// one piece of the command line as the shell passed it, so a value may hold white space.
+// Since the shell has removed any quotes, a value that begins with a '-' must be given as -name=value.
+// An empty argument, as the shell passes -label "", and -label= both give the variable the empty string as its value.
+// If ConfigEnvVar names an environment variable that holds the path of an existing file, the flags
+// in that file are read first, and the arguments override them.  If an argument asks for help,
+// Usage is written and ErrHelpRequested returned, before anything else is checked, and likewise
+// for the version, see SetVersion.  The arguments "--flags-file path", or "-is path" unless a variable
+// named "is" is declared, read the flags in that file in their place, and may be given any number of times.
+// When commands are declared, see AddCommand, the arguments from the name of the command on are
+// parsed by the command's CmdParser once those before it are parsed here, and so on for its commands.
+// Required variables are checked only in the CmdParsers of the commands selected
+func (cp *CmdParser) ParseFromArgs(args []string) error {
+	return cp.parseArgsFrom(args, 0)
+}
+
+// parseArgsFrom parses arguments as ParseFromArgs does, where base is the index of the first among the
+// arguments of the whole command line, e.g., 1 for os.Args[1:], so that errors give each argument's position there
+func (cp *CmdParser) parseArgsFrom(args []string, base int) error {
+
+	// with commands declared, the arguments from the name of the command on are the command's
+	cmd_at := -1
+	if len(cp.commands) > 0 {
+		cp.selected = ""
+		cmd_at = cp.commandIndex(args)
+	}
+	rest := []string{}
+	if cmd_at >= 0 {
+		args, rest = args[:cmd_at], args[cmd_at:]
+	}
+
+	if cp.asksFor(args, helpSpellings) {
+		if err := cp.Usage(cp.out()); err != nil {
+			return err
+		}
+		return ErrHelpRequested
+	}
+	if len(cp.version) > 0 && cp.asksFor(args, versionSpellings) {
+		for _, line := range cp.version {
+			fmt.Fprintln(cp.out(), line)
+		}
+		return ErrVersionRequested
+	}
+
+	// without the name of a command, all the arguments are those of the default command, if there is one
+	rest_base := base + cmd_at + 1
+	if len(cp.commands) > 0 && cmd_at < 0 && cp.cmdDefault != "" {
+		args, rest = nil, append([]string{cp.cmdDefault}, args...)
+		rest_base = base
+	}
+
+	pr := cp.newPairer()
+	if cfgfile := cp.envConfigFile(); cfgfile != "" {
+		cp.infof("reading flags from %s, named by $%s\n", cfgfile, cp.ConfigEnvVar)
+		if err := cp.feedFile(cfgfile, pr); err != nil {
+			return err
+		}
+	}
+	for idx, arg := range args {
+		pr.note(pr.add(token{text: arg, at: origin{source: SourceCmdLine}, argAt: base + idx + 1}))
+	}
+	if err := pr.finish(); err != nil || len(cp.commands) == 0 {
+		return err
+	}
+
+	// pass what follows the name of the command to the command's CmdParser, or with nothing at all
+	// on the command line, or the help command, write help
+	if len(rest) > 0 {
+		cp.tracef("token %q (%s) names a command\n", rest[0], origin{source: SourceCmdLine})
+	}
+	if len(rest) == 0 && cp.runnable {
+		return nil
+	}
+	if len(rest) == 0 && len(args) == 0 {
+		return cp.helpFor(nil)
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("no command given, expected one of: %s", strings.Join(cp.commandOrder, ", "))
+	}
+	name := cp.resolveCommand(rest[0])
+	sub, present := cp.commands[name]
+	if !present && name == helpCommand {
+		return cp.helpFor(rest[1:])
+	}
+	if !present {
+		return cp.unknownCommand(name)
+	}
+	cp.selected = name
+	return sub.parseArgsFrom(rest[1:], rest_base)
+}
+
+// ParseFromCmdLine gets the command line from os.Args, i.e., the run-time command line, as
+// ParseFromArgs does, reporting any error to the output writer and returning whether parsing succeeded.
+// If ConfigEnvVar names an environment variable that holds the path of an existing file, the flags
+// in that file are read first, and the command line overrides them
+func (cp *CmdParser) ParseFromCmdLine() bool {
+	err := cp.parseArgsFrom(os.Args[1:], 1)
+	if err == ErrHelpRequested || err == ErrVersionRequested {
+		return false
+	}
+	if err != nil {
+		cp.reportf("%v\n", err)
+		return false
+	}
+	return true
+}
+
+// envConfigFile returns the path held by the environment variable named by ConfigEnvVar,
+// or the empty string if there is no such variable or it does not name an existing file
+func (cp *CmdParser) envConfigFile() string {
+	if cp.ConfigEnvVar == "" {
+		return ""
+	}
+	cfgfile := os.Getenv(cp.ConfigEnvVar)
+	if cfgfile == "" {
+		return ""
+	}
+	if fi, err := os.Stat(cfgfile); err != nil || fi.IsDir() {
+		return ""
+	}
+	return cfgfile
+}
+
+// feedFile passes the pieces of a file of flags to a pairer
+func (cp *CmdParser) feedFile(filename string, pr *pairer) error {
+	inFile, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("Cannot open command line file %s: %w", filename, err)
+	}
+	defer inFile.Close()
+	return feedReader(inFile, filename, pr)
+}
+
+// ParseFile gets the command line flags from a file, returning what went wrong, if anything, e.g., an
+// error wrapping the *fs.PathError for a file that cannot be opened.  This enables separation across lines
+// and comments.  Anything on a line after a '#' is a comment, and a line ending in a backslash
+// continues onto the next, which is joined to it in place of the backslash less its indentation
+func (cp *CmdParser) ParseFile(filename string) error {
+
+	// open the file
+	inFile, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("Cannot open command line file %s: %w", filename, err)
+	}
+	defer inFile.Close()
+	return cp.ParseFromReader(inFile, filename)
+}
+
+// ParseFromFile parses the file of command line flags as ParseFile does, reporting any error to the
+// output writer and returning whether parsing succeeded
+func (cp *CmdParser) ParseFromFile(filename string) bool {
+	if err := cp.ParseFile(filename); err != nil {
+		cp.reportf("%v\n", err)
+		return false
+	}
+	return true
+}
+
+// ParseFromReader gets the command line flags from a reader holding text in the format of a
+// file read by ParseFromFile.  The name stands in for a file name in error messages and
+// reports of where values came from
+func (cp *CmdParser) ParseFromReader(r io.Reader, name string) error {
+	pr := cp.newPairer()
+	if err := feedReader(r, name, pr); err != nil {
+		return err
+	}
+	return pr.finish()
+}
+
+// DefaultMaxLineLength is the longest line, in bytes, read from a file of flags unless SetMaxLineLength says otherwise.
+// It is far beyond the 64KB to which a bufio.Scanner is limited, to allow, e.g., a generated list of thousands of hosts on one line
+const DefaultMaxLineLength = 16 << 20
+
+// SetMaxLineLength sets the longest line, in bytes, that ParseFromFile, ParseFromReader, and the other readers
+// of files of flags accept; a longer line is an error naming the file and line.  A limit of 0 or less restores DefaultMaxLineLength
+func (cp *CmdParser) SetMaxLineLength(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	cp.maxLine = limit
+}
+
+// maxLineLength returns the longest line, in bytes, read from a file of flags
+func (cp *CmdParser) maxLineLength() int {
+	if cp.maxLine == 0 {
+		return DefaultMaxLineLength
+	}
+	return cp.maxLine
+}
+
+// newLineScanner returns a scanner for the lines of a file of flags that accepts lines of up to limit bytes
+func newLineScanner(r io.Reader, limit int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	initial := bufio.MaxScanTokenSize
+	if initial > limit+1 {
+		initial = limit + 1
+	}
+	// room for the newline too, which the scanner must see to end the line
+	scanner.Buffer(make([]byte, 0, initial), limit+1)
+	return scanner
+}
+
+// scanError describes an error from scanning the lines of a file, naming the line after line_no, the
+// last line read, as the one too long for the limit when that is the error
+func scanError(name string, line_no int, limit int, err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		at := origin{source: SourceFile, file: name, line: line_no + 1}
+		return fmt.Errorf("%sline is longer than the limit of %d bytes, see SetMaxLineLength: %w", at.prefix(), limit, err)
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// feedReader breaks up text in the format of a file read by ParseFromFile into pieces, passing them
+// to a pairer a line at a time.  The name stands in for a file name
+func feedReader(r io.Reader, name string, pr *pairer) error {
+
+	// read line by line, skipping empty lines and commented lines.
+	// Each piece remembers the line it came from
+	line_no := 0
+	held := ""         // text of a line ending in a backslash, which continues on the next line
+	continued := false // held has text waiting for the next line
+	held_at := origin{}
+	limit := pr.cp.maxLineLength()
+	scanner := newLineScanner(r, limit)
+	for scanner.Scan() {
+
+		// line by line
+		nxt_line := scanner.Text()
+		line_no += 1
+
+		// skip empty lines, unless one ends a continued line
+		if string(nxt_line) == "" && !continued {
+			continue
+		}
+
+		// remove anything after a '#' that begins a comment, which one within a quoted value does not
+		if hash := commentAt(nxt_line); hash >= 0 {
+			nxt_line = nxt_line[:hash]
+		}
+
+		// get rid of "\n" if present
+		nxt_line = strings.Replace(nxt_line, "\n", "", 1)
+		at := origin{source: SourceFile, file: name, line: line_no}
+
+		// a line continuing the one before is joined onto it, less its indentation
+		if continued {
+			nxt_line = held + strings.TrimLeft(nxt_line, " \t")
+			at = held_at
+			continued = false
+		}
+
+		// a line ending in a backslash (outside a comment) continues onto the next, so hold it back
+		trimmed := strings.TrimRight(nxt_line, " \t")
+		if strings.HasSuffix(trimmed, "\\") {
+			held = strings.TrimSuffix(trimmed, "\\")
+			held_at = at
+			continued = true
+			continue
+		}
+
+		feedLine(nxt_line, at, pr)
+	}
+	if err := scanner.Err(); err != nil {
+		return scanError(name, line_no, limit, err)
+	}
+
+	// the last line may have had a backslash with nothing after it to continue onto
+	if continued {
+		feedLine(held, held_at, pr)
+	}
+	return pr.flush()
+}
+
+// feedLine passes the pieces of a line of a file to a pairer, which notes any that are out of place
+// so that the rest of the file is still read.  With SkipMalformedLines set, a malformed line is skipped
+// instead, and a value out of place is skipped by itself, each with a warning
+func feedLine(line string, at origin, pr *pairer) {
+	if pr.cp.SkipMalformedLines {
+		if problem := pr.malformed(line, at); problem != "" {
+			pr.cp.skip(at, problem+", skipping the line")
+			return
+		}
+	}
+	if unterminatedQuote(line) && !pr.cp.SkipMalformedLines {
+		pr.note(fmt.Errorf("%sunterminated quote", at.prefix()))
+		return
+	}
+	for _, piece := range tokenize(line, at) {
+		err := pr.add(piece)
+		var format_err *FormatError
+		if pr.cp.SkipMalformedLines && errors.As(err, &format_err) {
+			pr.cp.skip(at, fmt.Sprintf("value %q follows no flag, skipping it", piece.text))
+			continue
+		}
+		pr.note(err)
+	}
+}
+
+// malformed says what is wrong with a line of a file that cannot be parsed as flags, e.g., "unterminated quote",
+// or returns "" if nothing is.  A line is malformed if it has a quote that is not closed or a flag with no
+// name, or if it begins with a value when no flag before it waits for one
+func (pr *pairer) malformed(line string, at origin) string {
+	if unterminatedQuote(line) {
+		return "unterminated quote"
+	}
+	pieces := tokenize(line, at)
+	for _, piece := range pieces {
+		if malformedFlag(piece) {
+			return fmt.Sprintf("malformed flag %q", piece.text)
+		}
+	}
+	waiting := pr.pending != nil || pr.list != nil || pr.fileNext != "" || len(pr.subst) > 0 || pr.cp.positionals
+	if len(pieces) > 0 && !waiting && (!strings.HasPrefix(pieces[0].text, "-") || pieces[0].quoted) {
+		return fmt.Sprintf("value %q follows no flag", pieces[0].text)
+	}
+	return ""
+}
+
+// skip warns of a part of a file of flags skipped, see SkipMalformedLines, and keeps the warning for SkippedLines
+func (cp *CmdParser) skip(at origin, problem string) {
+	msg := at.prefix() + problem
+	cp.warnf("%s\n", msg)
+	cp.skipped = append(cp.skipped, msg)
+}
+
+// SkippedLines returns a warning for each malformed line, or value out of place, that parses of files of flags
+// skipped with SkipMalformedLines set, in the order skipped, e.g., "run.cfg:3: unterminated quote, skipping the line"
+func (cp *CmdParser) SkippedLines() []string {
+	return append([]string{}, cp.skipped...)
+}
+
+// shellWord quotes a value for a shell if it holds anything but characters a shell takes literally
+func shellWord(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:,=+@%") == "" {
+		return value
+	}
+	return singleQuote(value)
+}
+
+// CommandLine renders the command variables that hold values as flags that, pasted into a shell after
+// the program name, give them those values again, e.g., "-count 5 -name 'run 1' -verbose".  Boolean
+// variables that are true appear as bare flags, and values are quoted for the shell where needed.
+// The variables appear in the order they were declared, and the values of secret variables are redacted
+func (cp *CmdParser) CommandLine() string {
+	pieces := []string{}
+	for _, name := range cp.order {
+		v := cp.vars[name]
+		if !v.Loaded() {
+			continue
+		}
+		value := cp.display(name, v.Get())
+		switch {
+		case v.ArgType() == BoolFlag && value == "true":
+			pieces = append(pieces, "-"+name)
+		case strings.HasPrefix(value, "-") && !argIsNumber(value):
+			pieces = append(pieces, "-"+name+"="+shellWord(value))
+		default:
+			pieces = append(pieces, "-"+name, shellWord(value))
+		}
+	}
+	return strings.Join(pieces, " ")
+}
+
+// WriteEffectiveConfig writes every command variable declared in the CmdParser with the value it
+// finally holds, one per line in alphabetical order, followed by a comment saying where the value came from,
+// e.g., "# from experiment.cfg:12", "# command line", or "# default".  The result is in the format read by ParseFromFile,
+// with values quoted where they need to be to read back as themselves, save that the values of secret variables
+// are redacted.  A variable with neither a value nor a default is written commented out, e.g., "# -n  # unset",
+// so that reading the file back leaves it unset.  A value that cannot be quoted to read back as itself, one
+// needing quotes that holds both kinds, e.g., it's "x", or one holding a line break, is an error, and nothing is written
+func (cp *CmdParser) WriteEffectiveConfig(w io.Writer) error {
+	// line the comments up in a column
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	for _, name := range cp.sortedNames() {
+		if !cp.HasValue(name) {
+			fmt.Fprintf(tw, "# -%s\t# unset\n", name)
+			continue
+		}
+		value, ok := templateValue(cp.display(name, cp.value(name)))
+		if !ok {
+			return fmt.Errorf("WriteEffectiveConfig cannot write the value of -%s, %q, so that it reads back as itself", name, value)
+		}
+		fmt.Fprintf(tw, "-%s %s\t# %s\n", name, value, cp.info[name].origin)
+	}
+	tw.Flush()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SetEchoOnParse has every parse that succeeds end by writing the values of all the command variables
+// to w, as WriteEffectiveConfig does, e.g., to begin the log of a run with its parameters.  A nil w stops the echo
+func (cp *CmdParser) SetEchoOnParse(w io.Writer) {
+	cp.echo = w
+}
+
+// ErrNoArguments is returned by ParseArgs when there are no arguments to parse but a required variable
+// has no value, unless a configuration file is named in the environment, see ConfigEnvVar, or EnvPrefix
+// is set, which may supply it, or commands are declared, when help is written instead
+var ErrNoArguments = errors.New("call requires command line arguments")
+
+// ParseArgs parses a full command line, whose first element, like that of os.Args, is the program name
+// and is skipped.  The remaining arguments are parsed as by ParseFromArgs, which handles requests for help
+// and the version, and reads files of flags named by "-is" anywhere among them.  An empty command line is
+// parsed too, succeeding if no variable is required.  The declarations are checked with CheckConsistency first.
+// It reports whether parsing succeeded
+func (cp *CmdParser) ParseArgs(args []string) (bool, error) {
+
+	// make sure the declarations make sense before parsing against them
+	if err := cp.CheckConsistency(); err != nil {
+		return false, err
+	}
+
+	// see if the command line is empty when it cannot be, with no other source of values
+	rest := []string{}
+	if len(args) > 1 {
+		rest = args[1:]
+	}
+	if len(rest) == 0 && cp.envConfigFile() == "" && cp.EnvPrefix == "" && len(cp.commands) == 0 &&
+		cp.missingRequired(nil) != nil {
+		return false, ErrNoArguments
+	}
+
+	err := cp.parseArgsFrom(rest, 1)
+	return err == nil, err
+}
+
+// Parse parses the run-time command line, os.Args, as ParseArgs does, and reports whether that succeeded.
+// A command line asking for help with "-h", "-help", or "--help" gets Usage, and one asking for the version
+// gets that, see SetVersion.  What happens then, or when parsing fails, e.g., on an empty command line or
+// with required flags missing, is up to the CmdParser's ErrorHandling: under ExitOnError, which NewCmdParser
+// gives, the program exits, with status 0 after help or the version and 2 after reporting an error; under
+// PanicOnError Parse panics with the error; and under ContinueOnError it reports the error and returns false
+func (cp *CmdParser) Parse() bool {
+	_, err := cp.ParseArgs(os.Args)
+	ok, _ := cp.handleError(err)
+	return ok
+}
+
+// ParseOnce calls Parse the first time it is called and thereafter returns the result of that call
+// without parsing again, so that several initialization paths may each ask for the command line to
+// be parsed.  It is safe to call from multiple goroutines.  Under ContinueOnError the error is returned
+// as well as reported, and under PanicOnError the error Parse would panic with is returned instead
+func (cp *CmdParser) ParseOnce() (bool, error) {
+	cp.once.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err, is_err := r.(error)
+				if !is_err {
+					err = fmt.Errorf("%v", r)
+				}
+				cp.onceOK, cp.onceErr = false, err
+			}
+		}()
+		_, err := cp.ParseArgs(os.Args)
+		cp.onceOK, cp.onceErr = cp.handleError(err)
+	})
+	return cp.onceOK, cp.onceErr
 }
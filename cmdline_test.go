@@ -0,0 +1,1683 @@
+package cmdline
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//go:embed testdata/embedded.cfg
+var testFiles embed.FS
+
+// TestCommandSubstitution checks that a $(command) value becomes the command's output when allowed
+func TestCommandSubstitution(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AllowCommandSubstitution = true
+	cp.AddFlag(StringFlag, "rev", false)
+	cp.AddFlag(StringFlag, "greeting", false)
+	cp.AddFlag(StringFlag, "literal", false)
+	if err := cp.ParseString(`-rev $(echo hi) -greeting "$(echo hello there)" -literal '$(echo hi)'`); err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	for name, want := range map[string]string{"rev": "hi", "greeting": "hello there", "literal": "$(echo hi)"} {
+		if got := cp.GetVar(name); got != want {
+			t.Errorf("-%s is %q, want %q", name, got, want)
+		}
+	}
+
+	// a command that fails is a parse error
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AllowCommandSubstitution = true
+	cp.AddFlag(StringFlag, "rev", false)
+	if err := cp.ParseString("-rev $(exit 3)"); err == nil {
+		t.Errorf("ParseString of a failing command returned no error")
+	}
+
+	// without the option the value is taken as it is
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "rev", false)
+	if err := cp.ParseString("-rev $(echo)"); err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if got := cp.GetVar("rev"); got != "$(echo)" {
+		t.Errorf("-rev is %q without AllowCommandSubstitution, want %q", got, "$(echo)")
+	}
+}
+
+// TestCommandSubstitutionOnlyInValues checks that a $(command) in place of a flag is not run
+func TestCommandSubstitutionOnlyInValues(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AllowCommandSubstitution = true
+	cp.AddFlag(StringFlag, "rev", false)
+	if err := cp.ParseString("$(echo -rev) x"); err == nil {
+		t.Errorf("ParseString of a substitution in place of a flag returned no error")
+	}
+	if cp.IsLoaded("rev") {
+		t.Errorf("-rev was set by a substitution in place of a flag")
+	}
+}
+
+// TestCommandSubstitutionText checks that the shell is given the command as it was written, quotes and
+// white space within them included, that the substitution ends at the ')' that closes it, with any text after
+// it following the output, and that a flag whose command fails or is not closed is left without a value
+func TestCommandSubstitutionText(t *testing.T) {
+	cases := []struct {
+		cmd_string string
+		rev        string // "" when the parse fails
+	}{
+		{`-rev $(printf '%s|' "a   b") -count 3`, "a   b|"},
+		{`-rev $(echo hi)x -count 3`, "hix"},
+		{`-rev $(echo "(a)" b) -count 3`, "(a) b"},
+		{`-rev "$(echo a  b)" -count 3`, "a b"},
+		{`-rev $(exit 3) -count 3`, ""},
+		{`-count 3 -rev $(echo hi`, ""},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AllowCommandSubstitution = true
+		cp.AddFlag(StringFlag, "rev", false)
+		cp.AddFlag(IntFlag, "count", false)
+		err := cp.ParseString(c.cmd_string)
+		if cp.GetVar("count") != 3 {
+			t.Errorf("ParseString(%s) gave -count %v, want 3", c.cmd_string, cp.GetVar("count"))
+		}
+		if c.rev == "" {
+			if err == nil || cp.IsLoaded("rev") {
+				t.Errorf("ParseString(%s) gave %v and -rev %q, want an error and no value", c.cmd_string, err, cp.GetVar("rev"))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseString(%s) failed: %v", c.cmd_string, err)
+		} else if cp.GetVar("rev") != c.rev {
+			t.Errorf("ParseString(%s) gave -rev %q, want %q", c.cmd_string, cp.GetVar("rev"), c.rev)
+		}
+	}
+}
+
+// TestAmbiguousPrefixes checks that the prefixes shared by two flags are reported with both names
+func TestAmbiguousPrefixes(t *testing.T) {
+	cp := NewCmdParser()
+	cp.AllowReservedNames = true // "version" is reserved
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.AddFlag(BoolFlag, "version", false)
+	cp.AddFlag(IntFlag, "count", false)
+	want := map[string][]string{
+		"v":   {"verbose", "version"},
+		"ve":  {"verbose", "version"},
+		"ver": {"verbose", "version"},
+	}
+	if got := cp.AmbiguousPrefixes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AmbiguousPrefixes returned %v, want %v", got, want)
+	}
+}
+
+// TestParseFromReader checks that flags are read from a bytes.Reader and from an embedded file
+// as from a file, and that the name given is used in error messages
+func TestParseFromReader(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(FloatFlag, "rate", false)
+		return cp
+	}
+
+	cp := declare()
+	if err := cp.ParseFromReader(bytes.NewReader([]byte("-count 3 # comment\n-name 'run 1'\n")), "inline"); err != nil {
+		t.Fatalf("ParseFromReader of a bytes.Reader failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("name") != "run 1" {
+		t.Errorf("ParseFromReader of a bytes.Reader gave -count %v -name %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+
+	f, err := testFiles.Open("testdata/embedded.cfg")
+	if err != nil {
+		t.Fatalf("cannot open embedded file: %v", err)
+	}
+	defer f.Close()
+	cp = declare()
+	if err := cp.ParseFromReader(f, "embedded.cfg"); err != nil {
+		t.Fatalf("ParseFromReader of an embedded file failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("name") != "run 1" || cp.GetVar("rate") != 0.5 {
+		t.Errorf("ParseFromReader of an embedded file gave -count %v -name %q -rate %v",
+			cp.GetVar("count"), cp.GetVar("name"), cp.GetVar("rate"))
+	}
+
+	cp = declare()
+	err = cp.ParseFromReader(strings.NewReader("-count 3\n-count x\n"), "piped")
+	if err == nil || !strings.HasPrefix(err.Error(), "piped:2: ") {
+		t.Errorf("ParseFromReader error is %v, want one beginning %q", err, "piped:2: ")
+	}
+}
+
+// TestHistory checks that a flag set twice keeps both values in its history, while GetVar returns the last,
+// and that values rejected or ignored are left out
+func TestHistory(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.TrackHistory = true
+	cp.AddFlag(IntFlag, "level", false)
+	if err := cp.ParseString("-level 1 -level 2"); err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if got, want := cp.History("level"), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("History is %v, want %v", got, want)
+	}
+	if got := cp.GetVar("level"); got != 2 {
+		t.Errorf("-level is %v, want 2", got)
+	}
+
+	// values rejected, or ignored for a value of higher precedence, were not assigned
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.TrackHistory = true
+	cp.AddFlag(IntFlag, "level", false)
+	if err := cp.ParseString("-level 1 -level x -level 2"); err == nil {
+		t.Errorf("ParseString of -level x returned no error")
+	}
+	if err := cp.ParseFromReader(strings.NewReader("-level 3\n"), "run.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	if got, want := cp.History("level"), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("History after a rejected value and an ignored one is %v, want %v", got, want)
+	}
+
+	// without TrackHistory nothing is kept
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "level", false)
+	if err := cp.ParseString("-level 1 -level 2"); err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if got := cp.History("level"); len(got) != 0 {
+		t.Errorf("History without TrackHistory is %v, want none", got)
+	}
+}
+
+// TestParseOnce checks that concurrent calls of ParseOnce parse the command line exactly once and all get its result
+func TestParseOnce(t *testing.T) {
+	saved := os.Args
+	defer func() { os.Args = saved }()
+	os.Args = []string{"prog", "-n", "1"}
+
+	cp := NewCmdParserWithHandling(ContinueOnError)
+	cp.SetOutput(io.Discard)
+	cp.TrackHistory = true
+	cp.AddFlag(IntFlag, "n", false)
+
+	var wg sync.WaitGroup
+	results := make([]bool, 8)
+	for idx := range results {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			ok, err := cp.ParseOnce()
+			results[idx] = ok && err == nil
+		}(idx)
+	}
+	wg.Wait()
+	for idx, ok := range results {
+		if !ok {
+			t.Errorf("call %d of ParseOnce did not succeed", idx)
+		}
+	}
+	if got := cp.History("n"); len(got) != 1 {
+		t.Errorf("-n was set %d times, want once", len(got))
+	}
+}
+
+// TestSecretRedacted checks that the value of a secret flag appears in none of the package's output,
+// while GetVar returns it
+func TestSecretRedacted(t *testing.T) {
+	var out, trace, audit bytes.Buffer
+	cp := NewCmdParser()
+	cp.SetOutput(&out)
+	cp.SetTrace(&trace)
+	cp.EnableAudit(true)
+	cp.AddFlag(StringFlag, "password", false)
+	cp.SetSecret("password")
+	cp.AddFlagWithDefault(StringFlag, "token", false, "tok-default")
+	cp.SetSecret("token")
+	cp.AddFlag(IntFlag, "pin", false)
+	cp.SetSecret("pin")
+
+	// the unknown flag is reported and the pin cannot be converted, and neither message shows a secret
+	err := cp.ParseString("-password hunter2 -bogus 1 -pin 12ab34")
+	if err == nil {
+		t.Fatalf("ParseString of a bad pin returned no error")
+	}
+	if strings.Contains(err.Error(), "12ab34") {
+		t.Errorf("conversion error shows the secret value: %v", err)
+	}
+	if !strings.Contains(err.Error(), "-bogus") {
+		t.Errorf("the unknown flag was not reported: %v", err)
+	}
+	if got := cp.GetVar("password"); got != "hunter2" {
+		t.Errorf("GetVar returned %q, want the real value", got)
+	}
+
+	var config, usage strings.Builder
+	if err := cp.WriteEffectiveConfig(&config); err != nil {
+		t.Fatalf("WriteEffectiveConfig failed: %v", err)
+	}
+	if err := cp.Usage(&usage); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if err := cp.WriteAudit(&audit); err != nil {
+		t.Fatalf("WriteAudit failed: %v", err)
+	}
+	outputs := map[string]string{
+		"warnings":             out.String(),
+		"trace":                trace.String(),
+		"audit":                audit.String(),
+		"WriteEffectiveConfig": config.String(),
+		"Usage":                usage.String(),
+		"CommandLine":          cp.CommandLine(),
+	}
+	for path, text := range outputs {
+		for _, secret := range []string{"hunter2", "tok-default", "12ab34"} {
+			if strings.Contains(text, secret) {
+				t.Errorf("%s shows secret %q:\n%s", path, secret, text)
+			}
+		}
+	}
+	for _, path := range []string{"WriteEffectiveConfig", "CommandLine"} {
+		if !strings.Contains(outputs[path], redacted) {
+			t.Errorf("%s does not show %q in place of the secret:\n%s", path, redacted, outputs[path])
+		}
+	}
+}
+
+// TestUnionFlag checks that a union flag stores an int as an int and anything else as a string
+func TestUnionFlag(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  any
+		match FlagArgType
+	}{
+		{"5", 5, IntFlag},
+		{"abc", "abc", StringFlag},
+	} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddUnionFlag("id", false, IntFlag, StringFlag)
+		if err := cp.ParseString("-id " + test.value); err != nil {
+			t.Fatalf("ParseString of -id %s failed: %v", test.value, err)
+		}
+		if got := cp.GetVar("id"); got != test.want {
+			t.Errorf("-id %s is %v (%T), want %v (%T)", test.value, got, got, test.want, test.want)
+		}
+		if got := cp.MatchedType("id"); got != test.match {
+			t.Errorf("-id %s matched %s, want %s", test.value, FlagTypeString(got), FlagTypeString(test.match))
+		}
+	}
+
+	// a value none of the types accepts is an error
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddUnionFlag("id", false, IntFlag, FloatFlag)
+	if err := cp.ParseString("-id abc"); err == nil {
+		t.Errorf("ParseString of a value no type accepts returned no error")
+	}
+}
+
+// TestConfigEnvVar checks that the file named by ConfigEnvVar is read before the arguments, which override it
+func TestConfigEnvVar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myprog.cfg")
+	if err := os.WriteFile(path, []byte("-count 5\n-name base\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config file: %v", err)
+	}
+	t.Setenv("MYPROG_CONFIG", path)
+
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.ConfigEnvVar = "MYPROG_CONFIG"
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "name", false)
+	if err := cp.ParseFromArgs([]string{"-count", "7"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("count"); got != 7 {
+		t.Errorf("-count is %v, want the 7 given as an argument", got)
+	}
+	if got := cp.GetVar("name"); got != "base" {
+		t.Errorf("-name is %q, want %q from the config file", got, "base")
+	}
+
+	// an environment variable naming no file is passed over
+	t.Setenv("MYPROG_CONFIG", filepath.Join(t.TempDir(), "missing.cfg"))
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.ConfigEnvVar = "MYPROG_CONFIG"
+	cp.AddFlag(IntFlag, "count", false)
+	if err := cp.ParseFromArgs([]string{"-count", "7"}); err != nil {
+		t.Errorf("ParseFromArgs with a missing config file failed: %v", err)
+	}
+}
+
+// TestCheckConsistency checks that each kind of contradictory declaration is reported, and that
+// consistent declarations are not
+func TestCheckConsistency(t *testing.T) {
+	loaded := func(state string) *CmdParser {
+		cp, err := LoadState(strings.NewReader(state))
+		if err != nil {
+			t.Fatalf("LoadState failed: %v", err)
+		}
+		return cp
+	}
+	for _, test := range []struct {
+		name    string
+		declare func() *CmdParser
+		want    string
+	}{
+		{"required with a default", func() *CmdParser {
+			return loaded(`{"flags":[{"name":"n","type":"IntFlag","required":true,"default":"3","loaded":false}]}`)
+		}, "flag -n is required but has a default"},
+		{"required and deprecated", func() *CmdParser {
+			cp := NewCmdParser()
+			cp.AddFlag(IntFlag, "old", true)
+			cp.SetDeprecated("old", "use -new")
+			return cp
+		}, "flag -old is required but deprecated"},
+		{"default too long", func() *CmdParser {
+			return loaded(`{"flags":[{"name":"user","type":"StringFlag","length":[1,3],"required":false,"default":"abcdef","loaded":false}]}`)
+		}, `flag -user has default "abcdef", which it cannot hold`},
+		{"default not a log level", func() *CmdParser {
+			return loaded(`{"flags":[{"name":"level","type":"StringFlag","log_level":true,"required":false,"default":"loud","loaded":false}]}`)
+		}, `flag -level has default "loud", which it cannot hold`},
+		{"required and mutually exclusive", func() *CmdParser {
+			cp := NewCmdParser()
+			cp.AddFlag(StringFlag, "a", true)
+			cp.AddFlag(StringFlag, "b", true)
+			cp.SetMutuallyExclusive("a", "b")
+			return cp
+		}, "flags -a, -b are required but mutually exclusive"},
+		{"mode value the flag cannot hold", func() *CmdParser {
+			cp := NewCmdParser()
+			cp.AddFlag(IntFlag, "workers", false)
+			cp.WhenFlag("workers", "many", func(*CmdParser) {})
+			return cp
+		}, "mode -workers many can never be selected"},
+		{"mode value not written as parsed", func() *CmdParser {
+			cp := NewCmdParser()
+			cp.AddFlag(FloatFlag, "rate", false)
+			cp.WhenFlag("rate", "1.0", func(*CmdParser) {})
+			return cp
+		}, "mode -rate 1.0 can never be selected, as the value is written 1"},
+	} {
+		err := test.declare().CheckConsistency()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: CheckConsistency returned %v, want an error holding %q", test.name, err, test.want)
+		}
+	}
+
+	cp := NewCmdParser()
+	cp.AddFlag(StringFlag, "a", true)
+	cp.AddFlag(StringFlag, "b", false)
+	cp.SetMutuallyExclusive("a", "b")
+	cp.AddFlagWithDefault(IntFlag, "n", false, 3)
+	cp.AddLogLevelFlag("level", false)
+	cp.WhenFlag("n", "4", func(*CmdParser) {})
+	if err := cp.CheckConsistency(); err != nil {
+		t.Errorf("CheckConsistency of consistent declarations returned %v", err)
+	}
+}
+
+// largeConfig writes a file of the given number of lines of flags, as generated configs may have, returning its path
+func largeConfig(b *testing.B, lines int) string {
+	var text strings.Builder
+	for idx := 0; idx < lines; idx++ {
+		fmt.Fprintf(&text, "-count %d -name 'host %d' # line %d\n", idx, idx, idx)
+	}
+	path := filepath.Join(b.TempDir(), "large.cfg")
+	if err := os.WriteFile(path, []byte(text.String()), 0o644); err != nil {
+		b.Fatalf("cannot write large config: %v", err)
+	}
+	return path
+}
+
+// largeParser declares the flags of the large config
+func largeParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "name", false)
+	return cp
+}
+
+// BenchmarkParseFileStreaming parses a large file line by line, as ParseFile does.  Compare its allocations,
+// with -benchmem, to those of BenchmarkParseFileWhole, which gathers the file into one string first
+func BenchmarkParseFileStreaming(b *testing.B) {
+	path := largeConfig(b, 20000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := largeParser().ParseFile(path); err != nil {
+			b.Fatalf("ParseFile failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkParseFileWhole parses the same large file by reading it whole, stripping its comments, and
+// parsing the concatenated lines as one string, as files were parsed before reading them line by line
+func BenchmarkParseFileWhole(b *testing.B) {
+	path := largeConfig(b, 20000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatalf("cannot read large config: %v", err)
+		}
+		lines := strings.Split(string(contents), "\n")
+		for idx, line := range lines {
+			if hash := commentAt(line); hash >= 0 {
+				lines[idx] = line[:hash]
+			}
+		}
+		if err := largeParser().ParseString(strings.Join(lines, " ")); err != nil {
+			b.Fatalf("ParseString failed: %v", err)
+		}
+	}
+}
+
+// continuationParser declares the flags of the files of TestLineContinuation
+func continuationParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(FloatFlag, "rate", false)
+	return cp
+}
+
+// TestLineContinuation checks that a line ending in a backslash is joined to the next, and that
+// errors on the joined line name the line it began on
+func TestLineContinuation(t *testing.T) {
+	cp := continuationParser()
+	if err := cp.ParseFromReader(strings.NewReader("-count 3 \\\n    -name 'run 1'\n-rate 0.5\n"), "two.cfg"); err != nil {
+		t.Fatalf("ParseFromReader of a continued line failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("name") != "run 1" || cp.GetVar("rate") != 0.5 {
+		t.Errorf("continued lines gave -count %v -name %q -rate %v", cp.GetVar("count"), cp.GetVar("name"), cp.GetVar("rate"))
+	}
+
+	// a flag and its value may be split across lines
+	cp = continuationParser()
+	if err := cp.ParseFromReader(strings.NewReader("-name \\\n  host\n"), "split.cfg"); err != nil {
+		t.Fatalf("ParseFromReader of a split flag and value failed: %v", err)
+	}
+	if got := cp.GetVar("name"); got != "host" {
+		t.Errorf("-name is %q, want %q", got, "host")
+	}
+
+	cp = continuationParser()
+	err := cp.ParseFromReader(strings.NewReader("-name host \\\n  -count x\n"), "bad.cfg")
+	if err == nil || !strings.HasPrefix(err.Error(), "bad.cfg:1: ") {
+		t.Errorf("error on a continued line is %v, want one beginning %q", err, "bad.cfg:1: ")
+	}
+}
+
+// TestLineContinuationComment checks how a continuation meets a trailing comment: a backslash before
+// the comment continues the line, while one within the comment does not
+func TestLineContinuationComment(t *testing.T) {
+	cp := continuationParser()
+	if err := cp.ParseFromReader(strings.NewReader("-count 3 \\ # the count\n  -name host\n"), "before.cfg"); err != nil {
+		t.Fatalf("ParseFromReader of a backslash before a comment failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("name") != "host" {
+		t.Errorf("backslash before a comment gave -count %v -name %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+
+	// the value on the next line is not joined, so is out of place
+	cp = continuationParser()
+	err := cp.ParseFromReader(strings.NewReader("-count 3 # the count \\\nhost\n"), "within.cfg")
+	if err == nil || !strings.Contains(err.Error(), `within.cfg:2: "host"`) {
+		t.Errorf("backslash within a comment gave error %v, want one about %q on line 2", err, "host")
+	}
+	if !cp.IsLoaded("count") || cp.GetVar("count") != 3 {
+		t.Errorf("-count is %v, want 3 from the line before the comment", cp.GetVar("count"))
+	}
+}
+
+// TestMarkSensitive checks that the value of a flag marked sensitive is shown as "****" in the table of values
+// WriteEffectiveConfig writes, in the echo after a parse, in the audit log as JSON, and in what AsValues shows,
+// but that GetVar, GetVarE, and GetRaw return it
+func TestMarkSensitive(t *testing.T) {
+	var echo strings.Builder
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetEchoOnParse(&echo)
+	cp.EnableAudit(true)
+	cp.AddFlag(StringFlag, "api-key", false)
+	cp.MarkSensitive("api-key")
+	cp.AddFlag(StringFlag, "user", false)
+	if err := cp.ParseString("-api-key s3cr3t -user alice"); err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+	if !cp.IsSecret("api-key") {
+		t.Errorf("IsSecret is false for a flag marked sensitive")
+	}
+	if got := cp.GetVar("api-key"); got != "s3cr3t" {
+		t.Errorf("GetVar returned %q, want the raw value %q", got, "s3cr3t")
+	}
+	if got, err := cp.GetVarE("api-key"); err != nil || got != "s3cr3t" {
+		t.Errorf("GetVarE returned %q and %v, want the raw value %q", got, err, "s3cr3t")
+	}
+	if got, _ := cp.GetRaw("api-key"); got != "s3cr3t" {
+		t.Errorf("GetRaw returned %q, want the raw text %q", got, "s3cr3t")
+	}
+
+	var table strings.Builder
+	if err := cp.WriteEffectiveConfig(&table); err != nil {
+		t.Fatalf("WriteEffectiveConfig failed: %v", err)
+	}
+	audit, err := json.Marshal(cp.AuditLog())
+	if err != nil {
+		t.Fatalf("cannot encode the audit log: %v", err)
+	}
+	outputs := map[string]string{
+		"WriteEffectiveConfig": table.String(),
+		"SetEchoOnParse":       echo.String(),
+		"AuditLog as JSON":     string(audit),
+		"AsValues":             cp.AsValues()["api-key"].String(),
+	}
+	for path, text := range outputs {
+		if strings.Contains(text, "s3cr3t") || !strings.Contains(text, redacted) {
+			t.Errorf("%s does not redact the sensitive value:\n%s", path, text)
+		}
+	}
+	if !strings.Contains(table.String(), "-api-key ****") || !strings.Contains(table.String(), "-user alice") {
+		t.Errorf("WriteEffectiveConfig does not show -api-key as **** and -user as alice:\n%s", table.String())
+	}
+}
+
+// TestParseArgs checks that ParseArgs skips the program name and reads plain flags, and flags from
+// a file named by -is
+func TestParseArgs(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", true)
+		cp.AddFlag(StringFlag, "name", false)
+		return cp
+	}
+
+	cp := declare()
+	if ok, err := cp.ParseArgs([]string{"prog", "-count", "4", "-name", "plain"}); !ok || err != nil {
+		t.Fatalf("ParseArgs of plain flags gave %v, %v", ok, err)
+	}
+	if cp.GetVar("count") != 4 || cp.GetVar("name") != "plain" {
+		t.Errorf("plain flags gave -count %v -name %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+
+	path := filepath.Join(t.TempDir(), "args.cfg")
+	if err := os.WriteFile(path, []byte("-count 6\n-name 'from file'\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	cp = declare()
+	if ok, err := cp.ParseArgs([]string{"prog", "-is", path}); !ok || err != nil {
+		t.Fatalf("ParseArgs of -is %s gave %v, %v", path, ok, err)
+	}
+	if cp.GetVar("count") != 6 || cp.GetVar("name") != "from file" {
+		t.Errorf("-is gave -count %v -name %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+
+	// the program name alone leaves the required flag without a value
+	cp = declare()
+	if ok, err := cp.ParseArgs([]string{"prog"}); ok || err != ErrNoArguments {
+		t.Errorf("ParseArgs of the program name alone gave %v, %v, want false, ErrNoArguments", ok, err)
+	}
+}
+
+// TestDashValues checks that a value beginning with a '-' is taken as a value when given with '='
+// or quoted, but that unquoted after the flag it is taken as a flag of its own
+func TestDashValues(t *testing.T) {
+	for _, line := range []string{`-pattern=-x`, `-pattern "-x"`, `-pattern '-x'`, `-pattern="-x"`} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(StringFlag, "pattern", false)
+		if err := cp.ParseString(line); err != nil {
+			t.Errorf("ParseString(%s) failed: %v", line, err)
+			continue
+		}
+		if got := cp.GetVar("pattern"); got != "-x" {
+			t.Errorf("ParseString(%s) gave -pattern %q, want %q", line, got, "-x")
+		}
+	}
+
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "pattern", false)
+	if err := cp.ParseFromArgs([]string{"-pattern=-x"}); err != nil {
+		t.Fatalf("ParseFromArgs(-pattern=-x) failed: %v", err)
+	}
+	if got := cp.GetVar("pattern"); got != "-x" {
+		t.Errorf("ParseFromArgs(-pattern=-x) gave -pattern %q, want %q", got, "-x")
+	}
+
+	// unquoted, -x is a flag of its own, which is an error as it is not declared
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "pattern", false)
+	err := cp.ParseString("-pattern -x")
+	if got := cp.GetVar("pattern"); got == "-x" {
+		t.Errorf("ParseString(-pattern -x) gave -pattern the value %q", got)
+	}
+	var unknown *UnknownFlagError
+	if !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Names, []string{"x"}) {
+		t.Errorf("ParseString(-pattern -x) gave %v, want the undeclared flag -x reported", err)
+	}
+}
+
+// TestFlagsFile checks that --flags-file reads the flags of a file among the arguments, and that a flag on
+// the command line overrides the file's value for it wherever the two appear
+func TestFlagsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.cfg")
+	if err := os.WriteFile(path, []byte("-count 3\n-name 'from file'\n"), 0o644); err != nil {
+		t.Fatalf("cannot write flags file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--flags-file", path, "-count", "9"},
+		{"-count", "9", "--flags-file", path},
+		{"-count", "9", "--flags-file=" + path},
+	} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(StringFlag, "name", false)
+		if err := cp.ParseFromArgs(args); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", args, err)
+			continue
+		}
+		if cp.GetVar("count") != 9 || cp.GetVar("name") != "from file" {
+			t.Errorf("ParseFromArgs(%q) gave -count %v -name %q, want 9 and %q", args, cp.GetVar("count"), cp.GetVar("name"), "from file")
+		}
+	}
+
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	if err := cp.ParseFromArgs([]string{"-count", "9", "--flags-file"}); err == nil {
+		t.Errorf("ParseFromArgs of --flags-file without a file returned no error")
+	}
+}
+
+// TestImpliedFlag checks that an implied flag gives its target the implied value, takes no value of
+// its own, and is overridden by a later value for the target
+func TestImpliedFlag(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlagWithDefault(StringFlag, "loglevel", false, "info")
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddImpliedFlag("q", "loglevel", "quiet")
+		return cp
+	}
+
+	cp := declare()
+	if err := cp.ParseFromArgs([]string{"-q", "-count", "2"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("loglevel"); got != "quiet" {
+		t.Errorf("-q gave -loglevel %q, want %q", got, "quiet")
+	}
+	if got := cp.GetVar("count"); got != 2 {
+		t.Errorf("-count is %v after -q, want 2", got)
+	}
+
+	cp = declare()
+	if err := cp.ParseFromArgs([]string{"-q", "-loglevel", "debug"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("loglevel"); got != "debug" {
+		t.Errorf("-q then -loglevel debug gave -loglevel %q, want %q", got, "debug")
+	}
+
+	cp = declare()
+	if err := cp.ParseFromArgs([]string{}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := cp.GetVar("loglevel"); got != "info" {
+		t.Errorf("without -q -loglevel is %q, want its default %q", got, "info")
+	}
+}
+
+// TestInvertedBool checks that a boolean flag defaulting to true stays true when not given, is turned
+// off by -no-color, and is true when given as -color
+func TestInvertedBool(t *testing.T) {
+	for args, want := range map[string]bool{"": true, "-no-color": false, "-color": true, "-no-color -color": true} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlagWithDefault(BoolFlag, "color", false, true)
+		if err := cp.ParseFromArgs(strings.Fields(args)); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", args, err)
+			continue
+		}
+		if got := cp.GetVar("color"); got != want {
+			t.Errorf("ParseFromArgs(%q) gave -color %v, want %v", args, got, want)
+		}
+	}
+}
+
+// TestCommandLineRoundTrip checks that the command line CommandLine renders for parsed values, parsed
+// again by ParseFromString, gives the same values
+func TestCommandLineRoundTrip(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(FloatFlag, "offset", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(StringFlag, "pattern", false)
+		cp.AddFlag(BoolFlag, "verbose", false)
+		cp.AddFlag(StringFlag, "unused", false)
+		return cp
+	}
+
+	cp := declare()
+	if err := cp.ParseFromArgs([]string{"-count", "5", "-offset", "-1.5", "-name", "run 1", "-pattern=-x", "-verbose"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	line := cp.CommandLine()
+	again := declare()
+	if !again.ParseFromString(line) {
+		t.Fatalf("ParseFromString(%s) failed", line)
+	}
+	for _, name := range []string{"count", "offset", "name", "pattern", "verbose"} {
+		if got, want := again.GetVar(name), cp.GetVar(name); got != want {
+			t.Errorf("-%s is %v after the round trip through %s, want %v", name, got, line, want)
+		}
+	}
+	if again.IsLoaded("unused") {
+		t.Errorf("-unused, which was not given, is loaded after the round trip through %s", line)
+	}
+}
+
+// TestFileErrorPositions checks that errors in a file of flags name the file and the line of each
+// problem, on the first, a middle, and the last line, counting blank and comment lines
+func TestFileErrorPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cfg")
+	text := "-count x\n\n# a comment\n-name a stray\n-name b\n-rate y"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(FloatFlag, "rate", false)
+	err := cp.ParseFile(path)
+	multi, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("ParseFile gave %v, want a *MultiError", err)
+	}
+	want := []string{
+		path + `:1: flag -count: cannot parse "x" as int`,
+		path + `:4: "stray": expected a flag beginning with '-'`,
+		path + `:6: flag -rate: cannot parse "y" as float`,
+	}
+	if len(multi.Errors()) != len(want) {
+		t.Fatalf("ParseFile found %d problems, want %d:\n%v", len(multi.Errors()), len(want), err)
+	}
+	for idx, problem := range multi.Errors() {
+		if problem.Error() != want[idx] {
+			t.Errorf("problem %d is %q, want %q", idx+1, problem.Error(), want[idx])
+		}
+	}
+	var bad *BadValueError
+	if !errors.As(err, &bad) {
+		t.Fatalf("ParseFile gave %v, which holds no *BadValueError", err)
+	}
+	if bad.Position != path+":1" {
+		t.Errorf("the first *BadValueError has position %q, want %q", bad.Position, path+":1")
+	}
+}
+
+// TestGetDefault checks that GetDefault returns the declared default whatever value was given, and
+// reports that a flag declared without one has none
+func TestGetDefault(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(IntFlag, "workers", false, 4)
+	cp.AddFlag(StringFlag, "name", false)
+	if err := cp.ParseFromArgs([]string{"-workers", "9", "-name", "x"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if def, ok := cp.GetDefault("workers"); !ok || def != 4 {
+		t.Errorf("GetDefault(workers) is %v, %v, want 4, true", def, ok)
+	}
+	if def, ok := cp.GetDefault("name"); ok || def != nil {
+		t.Errorf("GetDefault(name) is %v, %v, want nil, false", def, ok)
+	}
+}
+
+// TestWildcardFlag checks that -Xmx 512 reaches the handler for prefix "X" with suffix "mx", that a
+// declared flag sharing the prefix is not routed, and that an error from the handler is a *BadValueError
+func TestWildcardFlag(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "Xtra", false)
+	got := map[string]string{}
+	cp.AddWildcardFlag("X", func(suffix, value string) error {
+		got[suffix] = value
+		return nil
+	})
+	if err := cp.ParseFromArgs([]string{"-Xmx", "512", "-Xss=4", "-Xtra", "1"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if want := map[string]string{"mx": "512", "ss": "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the handler was given %v, want %v", got, want)
+	}
+	if cp.GetVar("Xtra") != 1 {
+		t.Errorf("-Xtra is %v, want 1", cp.GetVar("Xtra"))
+	}
+
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddWildcardFlag("X", func(suffix, value string) error {
+		return fmt.Errorf("unknown setting %s", suffix)
+	})
+	err := cp.ParseFromArgs([]string{"-Xzz", "1"})
+	var bad *BadValueError
+	if !errors.As(err, &bad) || bad.Flag != "Xzz" || !strings.Contains(err.Error(), "unknown setting zz") {
+		t.Errorf("a failing handler gave %v, want a *BadValueError for -Xzz saying why", err)
+	}
+}
+
+// TestUnset checks that a flag that was set reads as unloaded with its default once unset, that a flag
+// without a default reads as its zero value, and that an unknown name is reported
+func TestUnset(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(IntFlag, "count", false, 7)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(IntSliceFlag, "ports", false)
+	if err := cp.ParseFromArgs([]string{"-count", "3", "-name", "x", "-ports", "80,443"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	for _, name := range []string{"count", "name", "ports"} {
+		if !cp.Unset(name) {
+			t.Errorf("Unset(%s) reported no such variable", name)
+		}
+		if cp.IsLoaded(name) {
+			t.Errorf("-%s is loaded after Unset", name)
+		}
+	}
+	if got := cp.GetVar("count"); got != 7 {
+		t.Errorf("-count is %v after Unset, want its default 7", got)
+	}
+	if got := cp.GetVar("name"); got != "" {
+		t.Errorf("-name is %q after Unset, want the empty string", got)
+	}
+	if got := cp.GetIntSlice("ports"); len(got) != 0 {
+		t.Errorf("-ports is %v after Unset, want no elements", got)
+	}
+	if cp.Unset("nosuch") {
+		t.Errorf("Unset(nosuch) reported a variable")
+	}
+}
+
+// TestListValuesOnLine checks that the flag of a list takes every value after it on a line of a file, up
+// to the next flag, while a scalar flag takes only one, leaving the next value out of place
+func TestListValuesOnLine(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntSliceFlag, "ports", false)
+	cp.AddFlag(IntFlag, "count", false)
+	if err := cp.ParseFromReader(strings.NewReader("-ports 80 443 8080 -count 2\n"), "list.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	if got, want := cp.GetIntSlice("ports"), []int{80, 443, 8080}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-ports is %v, want %v", got, want)
+	}
+	if got := cp.GetVar("count"); got != 2 {
+		t.Errorf("-count is %v after the list, want 2", got)
+	}
+
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntSliceFlag, "ports", false)
+	cp.AddFlag(IntFlag, "count", false)
+	err := cp.ParseFromReader(strings.NewReader("-count 2 3 4\n"), "scalar.cfg")
+	if err == nil || !strings.Contains(err.Error(), `scalar.cfg:1: "3": expected a flag beginning with '-'`) {
+		t.Errorf("-count 2 3 4 gave error %v, want the value 3 out of place", err)
+	}
+	if got := cp.GetVar("count"); got != 2 {
+		t.Errorf("-count is %v, want 2", got)
+	}
+}
+
+// TestFlagTypeName checks the type name returned for a flag of each kind, including flags declared by
+// the methods for particular types, and that an unknown name has none
+func TestFlagTypeName(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	want := map[string]string{}
+	for arg_type := IntFlag; arg_type < None; arg_type++ {
+		if arg_type == UnionFlag || arg_type == TypedMapFlag {
+			continue
+		}
+		name := strings.ToLower(FlagTypeString(arg_type))
+		cp.AddFlag(arg_type, name, false)
+		want[name] = FlagTypeString(arg_type)
+	}
+	cp.AddUnionFlag("either", false, IntFlag, StringFlag)
+	want["either"] = "UnionFlag"
+	cp.AddTypedMapFlag("limits", false, func(value string) (any, error) { return value, nil })
+	want["limits"] = "TypedMapFlag"
+	cp.AddStringFlagWithLength("user", false, 1, 8)
+	want["user"] = "StringFlag"
+	cp.AddLogLevelFlag("level", false)
+	want["level"] = "StringFlag"
+
+	for name, type_name := range want {
+		if got, ok := cp.FlagTypeName(name); !ok || got != type_name {
+			t.Errorf("FlagTypeName(%s) is %q, %v, want %q, true", name, got, ok, type_name)
+		}
+	}
+	if got, ok := cp.FlagTypeName("nosuch"); ok || got != "" {
+		t.Errorf("FlagTypeName(nosuch) is %q, %v, want \"\", false", got, ok)
+	}
+}
+
+// TestUnknownAsPositional checks that with UnknownAsPositional set a flag that is not declared ends up
+// among the positional arguments with its value, as it was given, while declared flags are parsed as usual
+func TestUnknownAsPositional(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.UnknownAsPositional = true
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(BoolFlag, "v", false)
+	if err := cp.ParseFromArgs([]string{"-x", "5", "-count", "2", "-fast", "-v"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.Args(), []string{"-x", "5", "-fast"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args is %q, want %q", got, want)
+	}
+	if cp.GetVar("count") != 2 || cp.GetVar("v") != true {
+		t.Errorf("declared flags gave -count %v -v %v", cp.GetVar("count"), cp.GetVar("v"))
+	}
+
+	// without it the flag is an error
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	err := cp.ParseFromArgs([]string{"-x", "5"})
+	var unknown *UnknownFlagError
+	if !errors.As(err, &unknown) || len(cp.Args()) != 0 {
+		t.Errorf("without UnknownAsPositional ParseFromArgs gave %v and Args %q, want -x reported and no arguments", err, cp.Args())
+	}
+}
+
+// TestConditionalDefault checks that a conditional default follows the value of the bool flag it depends
+// on, and that a value given for the flag itself overrides it
+func TestConditionalDefault(t *testing.T) {
+	for args, want := range map[string]int{"": 8080, "-tls": 443, "-tls -port 8443": 8443, "-port 9000": 9000} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(BoolFlag, "tls", false)
+		cp.AddFlagWithConditionalDefault(IntFlag, "port", false, func(cp *CmdParser) any {
+			if cp.GetVar("tls") == true {
+				return 443
+			}
+			return 8080
+		})
+		if err := cp.ParseFromArgs(strings.Fields(args)); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", args, err)
+			continue
+		}
+		if got := cp.GetVar("port"); got != want {
+			t.Errorf("ParseFromArgs(%q) gave -port %v, want %d", args, got, want)
+		}
+	}
+}
+
+// TestFreeze checks that every kind of declaration after Freeze panics naming the method and the name,
+// while parsing and getting values still work and a mode may still declare its flags
+func TestFreeze(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "mode", false)
+	cp.WhenFlag("mode", "server", func(cp *CmdParser) {
+		cp.AddFlag(IntFlag, "port", false)
+	})
+	cp.Freeze()
+	if !cp.IsFrozen() {
+		t.Fatalf("IsFrozen is false after Freeze")
+	}
+
+	// AddFlagWithDefault declares through AddFlag, which is the one to panic
+	cases := []struct {
+		method  string
+		declare func()
+	}{
+		{"AddFlag", func() { cp.AddFlag(IntFlag, "late", false) }},
+		{"AddFlag", func() { cp.AddFlagWithDefault(IntFlag, "late", false, 1) }},
+		{"AddStringFlagWithLength", func() { cp.AddStringFlagWithLength("late", false, 1, 2) }},
+		{"AddAlias", func() { cp.AddAlias("count", "late") }},
+		{"AddImpliedFlag", func() { cp.AddImpliedFlag("late", "count", "1") }},
+		{"WhenFlag", func() { cp.WhenFlag("count", "1", func(*CmdParser) {}) }},
+	}
+	for _, c := range cases {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.HasPrefix(msg, "CmdParser."+c.method+" given") || !strings.Contains(msg, "after Freeze") {
+					t.Errorf("a declaration after Freeze panicked with %q, want a panic naming %s", msg, c.method)
+				}
+			}()
+			c.declare()
+		}()
+	}
+	if cp.IsFlag("late") {
+		t.Errorf("a flag declared after Freeze is declared")
+	}
+
+	if err := cp.ParseFromArgs([]string{"-count", "3", "-mode", "server", "-port", "80"}); err != nil {
+		t.Fatalf("ParseFromArgs after Freeze failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("port") != 80 {
+		t.Errorf("after Freeze -count is %v and -port %v", cp.GetVar("count"), cp.GetVar("port"))
+	}
+	if !cp.IsFrozen() {
+		t.Errorf("the CmdParser is not frozen after a mode declared its flags")
+	}
+}
+
+// TestPositionalArity checks that positional arguments are counted against the arity, with errors saying
+// how many were expected and received, and that the arguments after "--", even help, are passed through uncounted
+func TestPositionalArity(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(BoolFlag, "v", false)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.SetPositionalArity(1, 2)
+		return cp
+	}
+
+	cp := declare()
+	if err := cp.ParseFromArgs([]string{"-v", "a.txt", "-count", "2", "b.txt", "--", "run", "-h", "x"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.Args(), []string{"a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args is %q, want %q", got, want)
+	}
+	if got, want := cp.Passthrough(), []string{"run", "-h", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Passthrough is %q, want %q", got, want)
+	}
+
+	for args, want := range map[string]string{
+		"-v":               "expected 1 to 2 positional arguments, received 0: missing argument 1",
+		"a b c":            `expected 1 to 2 positional arguments, received 3: extra "c"`,
+		"-- a b":           "expected 1 to 2 positional arguments, received 0: missing argument 1",
+		"a -count 3 b c d": `expected 1 to 2 positional arguments, received 4: extra "c" "d"`,
+	} {
+		cp := declare()
+		err := cp.ParseFromArgs(strings.Fields(args))
+		var arity *ArityError
+		if !errors.As(err, &arity) || err.Error() != want {
+			t.Errorf("ParseFromArgs(%q) gave %v, want %q", args, err, want)
+		}
+	}
+}
+
+// TestIsEmpty checks that IsEmpty tells a flag given an explicitly empty value, in each way one can be
+// given, from one given a value and from one not given at all
+func TestIsEmpty(t *testing.T) {
+	cases := []struct {
+		parse   func(cp *CmdParser) error
+		loaded  bool
+		isEmpty bool
+	}{
+		{func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"-label", ""}) }, true, true},
+		{func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"-label="}) }, true, true},
+		{func(cp *CmdParser) error { return cp.ParseString(`-label ""`) }, true, true},
+		{func(cp *CmdParser) error { return cp.ParseString(`-label x`) }, true, false},
+		{func(cp *CmdParser) error { return cp.ParseString(`-other x`) }, false, false},
+	}
+	for idx, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(StringFlag, "label", false)
+		cp.AddFlag(StringFlag, "other", false)
+		if err := c.parse(cp); err != nil {
+			t.Errorf("case %d failed: %v", idx+1, err)
+			continue
+		}
+		if cp.IsLoaded("label") != c.loaded || cp.IsEmpty("label") != c.isEmpty {
+			t.Errorf("case %d gave IsLoaded %v and IsEmpty %v, want %v and %v", idx+1, cp.IsLoaded("label"), cp.IsEmpty("label"), c.loaded, c.isEmpty)
+		}
+	}
+}
+
+// TestCheckName checks that CheckName names the rule each bad name breaks, that declaring such a name
+// panics, that reserved names may be declared only with AllowReservedNames, and that LoadState rejects bad
+// saved names with an error
+func TestCheckName(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	for name, want := range map[string]string{
+		"":        "flag name is empty",
+		"-rate":   `flag name "-rate" begins with '-'`,
+		"my rate": `flag name "my rate" holds white space`,
+		"a=b":     `flag name "a=b" holds '='`,
+		"a#b":     `flag name "a#b" holds '#'`,
+		"help":    `flag name "help" is reserved`,
+		"is":      `flag name "is" is reserved`,
+		"rate":    "",
+	} {
+		err := cp.CheckName(name)
+		if want == "" && err != nil {
+			t.Errorf("CheckName(%q) gave %v, want nil", name, err)
+		}
+		if want != "" && (err == nil || !strings.HasPrefix(err.Error(), want)) {
+			t.Errorf("CheckName(%q) gave %v, want an error beginning %q", name, err, want)
+		}
+	}
+
+	func() {
+		defer func() {
+			msg, _ := recover().(string)
+			if !strings.HasPrefix(msg, `CmdParser.AddFlag given invalid name: flag name "-rate" begins with '-'`) {
+				t.Errorf("AddFlag of -rate panicked with %q", msg)
+			}
+		}()
+		cp.AddFlag(FloatFlag, "-rate", true)
+	}()
+
+	cp.AllowReservedNames = true
+	cp.AddFlag(StringFlag, "version", false)
+	if err := cp.CheckNames()["version"]; err != nil {
+		t.Errorf("CheckNames gave %v for version with AllowReservedNames set", err)
+	}
+	cp.AllowReservedNames = false
+	if err := cp.CheckNames()["version"]; err == nil {
+		t.Errorf("CheckNames gave no error for version once AllowReservedNames is cleared")
+	}
+
+	for _, saved := range []string{
+		`{"flags":[{"name":"my rate","type":"FloatFlag","required":false,"loaded":false}]}`,
+		`{"flags":[{"name":"help","type":"BoolFlag","required":false,"loaded":false}]}`,
+		`{"flags":[{"name":"rate","type":"FloatFlag","required":false,"loaded":false,"aliases":["-r"]}]}`,
+	} {
+		if _, err := LoadState(strings.NewReader(saved)); err == nil || !strings.HasPrefix(err.Error(), "LoadState cannot restore") {
+			t.Errorf("LoadState(%s) gave %v, want an error saying it cannot restore the name", saved, err)
+		}
+	}
+	restored, err := LoadState(strings.NewReader(`{"allow_reserved_names":true,"flags":[{"name":"help","type":"BoolFlag","required":false,"loaded":false}]}`))
+	if err != nil || !restored.IsFlag("help") {
+		t.Errorf("LoadState of a reserved name with allow_reserved_names gave %v", err)
+	}
+}
+
+// TestParseFromMap checks that a map of values of several types sets each flag as its type, with names
+// given with or without their '-', and that an undeclared name or a bad value fails the parse
+func TestParseFromMap(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", true)
+		cp.AddFlag(FloatFlag, "rate", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(BoolFlag, "verbose", false)
+		cp.AddFlag(IntSliceFlag, "ports", false)
+		cp.AddFlag(FlexDurationFlag, "timeout", false)
+		return cp
+	}
+
+	cp := declare()
+	ok, err := cp.ParseFromMap(map[string]string{"count": "3", "-rate": "0.25", "name": "run 1", "verbose": "true",
+		"ports": "80,443", "timeout": "2s"})
+	if !ok || err != nil {
+		t.Fatalf("ParseFromMap failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("rate") != 0.25 || cp.GetVar("name") != "run 1" || cp.GetVar("verbose") != true {
+		t.Errorf("ParseFromMap gave -count %v -rate %v -name %q -verbose %v",
+			cp.GetVar("count"), cp.GetVar("rate"), cp.GetVar("name"), cp.GetVar("verbose"))
+	}
+	if got := cp.GetIntSlice("ports"); !reflect.DeepEqual(got, []int{80, 443}) {
+		t.Errorf("-ports is %v, want [80 443]", got)
+	}
+	if got := cp.GetFlexDuration("timeout"); got != 2*time.Second {
+		t.Errorf("-timeout is %v, want 2s", got)
+	}
+
+	for _, m := range []map[string]string{{"count": "3", "bogus": "1"}, {"count": "three"}, {"rate": "1"}} {
+		cp := declare()
+		if ok, err := cp.ParseFromMap(m); ok || err == nil {
+			t.Errorf("ParseFromMap(%v) gave %v, %v, want it to fail", m, ok, err)
+		}
+	}
+}
+
+// TestEmptyStringValues checks that a string flag that is absent, given the empty string either way it can
+// be, and given only white space are told apart, the white space being kept as the value
+func TestEmptyStringValues(t *testing.T) {
+	cases := []struct {
+		args    []string
+		loaded  bool
+		isEmpty bool
+		want    string
+	}{
+		{[]string{}, false, false, ""},
+		{[]string{"-label", ""}, true, true, ""},
+		{[]string{"-label="}, true, true, ""},
+		{[]string{"-label", "  "}, true, false, "  "},
+		{[]string{"-label=\t"}, true, false, "\t"},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(StringFlag, "label", false)
+		if err := cp.ParseFromArgs(c.args); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", c.args, err)
+			continue
+		}
+		if cp.IsLoaded("label") != c.loaded || cp.IsEmpty("label") != c.isEmpty || cp.GetVar("label") != c.want {
+			t.Errorf("ParseFromArgs(%q) gave IsLoaded %v, IsEmpty %v, and -label %q, want %v, %v, and %q", c.args,
+				cp.IsLoaded("label"), cp.IsEmpty("label"), cp.GetVar("label"), c.loaded, c.isEmpty, c.want)
+		}
+	}
+
+	// an empty value satisfies a required flag
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "label", true)
+	if err := cp.ParseFromArgs([]string{"-label="}); err != nil {
+		t.Errorf("ParseFromArgs(-label=) of a required flag failed: %v", err)
+	}
+}
+
+// TestSkipMalformedLines checks that with SkipMalformedLines set a file of flags with one bad line among good
+// ones applies the good lines and warns of the bad one by its line, and that without it the parse fails
+func TestSkipMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cfg")
+	text := "-count 3\n-name 'open\n-rate 0.5\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	newParser := func(skip bool) *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(FloatFlag, "rate", false)
+		cp.SkipMalformedLines = skip
+		return cp
+	}
+
+	cp := newParser(true)
+	if err := cp.ParseFile(path); err != nil {
+		t.Fatalf("ParseFile with SkipMalformedLines failed: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("rate") != 0.5 {
+		t.Errorf("ParseFile gave -count %v and -rate %v, want 3 and 0.5", cp.GetVar("count"), cp.GetVar("rate"))
+	}
+	if cp.IsLoaded("name") {
+		t.Errorf("ParseFile loaded -name %q from the malformed line", cp.GetVar("name"))
+	}
+	skipped := cp.SkippedLines()
+	if len(skipped) != 1 || !strings.Contains(skipped[0], path+":2:") {
+		t.Errorf("SkippedLines gave %q, want one warning naming %s:2", skipped, path)
+	}
+
+	if err := newParser(false).ParseFile(path); err == nil || !strings.Contains(err.Error(), path+":2:") {
+		t.Errorf("ParseFile without SkipMalformedLines gave %v, want an error naming %s:2", err, path)
+	}
+}
+
+// TestValuesOfType checks that ValuesOfType gives the loaded variables of the type asked for, leaving out
+// those of other types, those holding only defaults, and union variables whatever their values matched
+func TestValuesOfType(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(StringFlag, "dir", false)
+	cp.AddFlag(StringFlag, "unused", false)
+	cp.AddFlagWithDefault(StringFlag, "mode", false, "fast")
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.AddUnionFlag("limit", false, IntFlag, StringFlag)
+	args := []string{"-name", "run1", "-dir", "/tmp/out", "-count", "4", "-verbose", "-limit", "none"}
+	if err := cp.ParseFromArgs(args); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+
+	want := map[string]any{"name": "run1", "dir": "/tmp/out"}
+	if got := cp.ValuesOfType(StringFlag); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValuesOfType(StringFlag) gave %v, want %v", got, want)
+	}
+	if got := cp.ValuesOfType(IntFlag); !reflect.DeepEqual(got, map[string]any{"count": 4}) {
+		t.Errorf("ValuesOfType(IntFlag) gave %v, want map[count:4]", got)
+	}
+	if got := cp.ValuesOfType(UnionFlag); len(got) != 1 || got["limit"] != "none" {
+		t.Errorf("ValuesOfType(UnionFlag) gave %v, want map[limit:none]", got)
+	}
+	if got := cp.ValuesOfType(FloatFlag); len(got) != 0 {
+		t.Errorf("ValuesOfType(FloatFlag) gave %v, want none", got)
+	}
+}
+
+// TestQuotedValuesRoundTrip checks that quotes around values in a file of flags are removed, with the spaces
+// they hold kept and GetRaw giving the value as written, that mismatched quotes are an error at the file and
+// line, and that WriteEffectiveConfig writes values that read back as themselves, or refuses one it cannot
+func TestQuotedValuesRoundTrip(t *testing.T) {
+	newParser := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(StringFlag, "path", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(StringFlag, "note", false)
+		cp.AddFlag(StringFlag, "tag", false)
+		return cp
+	}
+
+	cp := newParser()
+	text := "-path '/data/run 1'\n-name \"baseline\"\n-note \"it's here\"\n-tag ''\n"
+	if err := cp.ParseFromReader(strings.NewReader(text), "run.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	want := map[string]string{"path": "/data/run 1", "name": "baseline", "note": "it's here", "tag": ""}
+	for name, value := range want {
+		if cp.GetVar(name) != value {
+			t.Errorf("-%s is %q, want %q", name, cp.GetVar(name), value)
+		}
+	}
+	if raw, _ := cp.GetRaw("path"); raw != "'/data/run 1'" {
+		t.Errorf("GetRaw(path) gave %q, want %q", raw, "'/data/run 1'")
+	}
+
+	// what is written reads back as the same values
+	var b bytes.Buffer
+	if err := cp.WriteEffectiveConfig(&b); err != nil {
+		t.Fatalf("WriteEffectiveConfig failed: %v", err)
+	}
+	again := newParser()
+	if err := again.ParseFromReader(strings.NewReader(b.String()), "effective.cfg"); err != nil {
+		t.Fatalf("reading back what WriteEffectiveConfig wrote failed: %v\n%s", err, b.String())
+	}
+	for name, value := range want {
+		if again.GetVar(name) != value {
+			t.Errorf("-%s read back as %q, want %q, from\n%s", name, again.GetVar(name), value, b.String())
+		}
+	}
+
+	// a value holding both kinds of quote cannot be written to read back
+	both := newParser()
+	if err := both.ParseFromArgs([]string{"-note", `it's "x"`}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	b.Reset()
+	if err := both.WriteEffectiveConfig(&b); err == nil || b.Len() != 0 {
+		t.Errorf("WriteEffectiveConfig of a value holding both quotes gave %v and wrote %q, want an error and nothing", err, b.String())
+	}
+
+	// a quote left open is reported at its line
+	err := newParser().ParseFromReader(strings.NewReader("-name a\n-path '/data/run 1\n"), "run.cfg")
+	if err == nil || !strings.Contains(err.Error(), "run.cfg:2:") {
+		t.Errorf("ParseFromReader of a mismatched quote gave %v, want an error at run.cfg:2", err)
+	}
+}
+
+// TestRequiredOrDefault checks that a default satisfies a variable made RequiredOrDefault, that a value given
+// replaces the default, and that with neither the parse fails naming the variable
+func TestRequiredOrDefault(t *testing.T) {
+	cases := []struct {
+		withDefault bool
+		args        []string
+		want        any // nil when the parse fails
+	}{
+		{true, []string{}, "/var/out"},
+		{true, []string{"-dir", "/tmp/out"}, "/tmp/out"},
+		{false, []string{"-dir", "/tmp/out"}, "/tmp/out"},
+		{false, []string{}, nil},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		if c.withDefault {
+			cp.AddFlagWithDefault(StringFlag, "dir", false, "/var/out")
+		} else {
+			cp.AddFlag(StringFlag, "dir", false)
+		}
+		cp.RequiredOrDefault("dir")
+		err := cp.ParseFromArgs(c.args)
+		if c.want == nil {
+			var missing *MissingRequiredError
+			if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Names, []string{"dir"}) {
+				t.Errorf("ParseFromArgs(%q) with no default gave %v, want -dir missing", c.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFromArgs(%q) with default %v failed: %v", c.args, c.withDefault, err)
+		} else if cp.GetVar("dir") != c.want {
+			t.Errorf("ParseFromArgs(%q) with default %v gave -dir %v, want %v", c.args, c.withDefault, cp.GetVar("dir"), c.want)
+		}
+	}
+}
+
+// TestWriteEffectiveConfig checks that each variable is written with its value and where the value came from,
+// that one with neither a value nor a default is written commented out, and that reading what is written back
+// sets the values written and leaves that one unset
+func TestWriteEffectiveConfig(t *testing.T) {
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "n", false)
+		cp.AddFlagWithDefault(IntFlag, "count", false, 4)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.AddFlag(FloatFlag, "rate", false)
+		return cp
+	}
+	cp := declare()
+	if err := cp.ParseFromReader(strings.NewReader("# a comment\n-name 'run 1'\n"), "run.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	if err := cp.ParseFromArgs([]string{"-rate", "0.5"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	var b strings.Builder
+	if err := cp.WriteEffectiveConfig(&b); err != nil {
+		t.Fatalf("WriteEffectiveConfig failed: %v", err)
+	}
+	want := "-count 4       # default\n" +
+		"# -n           # unset\n" +
+		"-name 'run 1'  # from run.cfg:2\n" +
+		"-rate 0.5      # command line\n"
+	if b.String() != want {
+		t.Errorf("WriteEffectiveConfig wrote\n%s\nwant\n%s", b.String(), want)
+	}
+
+	again := declare()
+	if err := again.ParseFromReader(strings.NewReader(b.String()), "effective.cfg"); err != nil {
+		t.Fatalf("reading back what WriteEffectiveConfig wrote failed: %v", err)
+	}
+	if again.IsLoaded("n") || again.GetVar("name") != "run 1" || again.GetVar("rate") != 0.5 || again.GetVar("count") != 4 {
+		t.Errorf("read back -n loaded %v, -name %q, -rate %v, -count %v, want unset, \"run 1\", 0.5, and 4",
+			again.IsLoaded("n"), again.GetVar("name"), again.GetVar("rate"), again.GetVar("count"))
+	}
+}
+
+// TestMaxLineLength checks that a line as long as the limit set by SetMaxLineLength is read, that a longer one
+// is an error naming its line, and that the default limit reads a line longer than a bufio.Scanner would
+func TestMaxLineLength(t *testing.T) {
+	cases := []struct {
+		limit int
+		text  string
+		err   string // "" when the parse succeeds
+	}{
+		{16, "-n 1\n-name 0123456789\n", ""},
+		{16, "-n 1\n-name 0123456789a\n", "run.cfg:2: line is longer than the limit of 16 bytes"},
+		{16, "-n 1\n-name 0123456789a", "run.cfg:2: line is longer than the limit of 16 bytes"},
+		{0, "-name " + strings.Repeat("x", 100000) + "\n", ""},
+		{-1, "-name " + strings.Repeat("x", 100000) + "\n", ""},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "n", false)
+		cp.AddFlag(StringFlag, "name", false)
+		cp.SetMaxLineLength(16)
+		cp.SetMaxLineLength(c.limit)
+		err := cp.ParseFromReader(strings.NewReader(c.text), "run.cfg")
+		if c.err == "" && err != nil {
+			t.Errorf("ParseFromReader with limit %d failed: %v", c.limit, err)
+		}
+		if c.err != "" && (err == nil || !strings.HasPrefix(err.Error(), c.err) || !errors.Is(err, bufio.ErrTooLong)) {
+			t.Errorf("ParseFromReader with limit %d of %q gave %v, want an error beginning %q", c.limit, c.text, err, c.err)
+		}
+	}
+}
+
+// TestWasSetHasValue checks that WasSet is true only for values the user set, from the command line, a file,
+// or the environment, while HasValue is true for those and for defaults, applied or not, and that neither is
+// true for a variable with no value and no default
+func TestWasSetHasValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cfg")
+	if err := os.WriteFile(path, []byte("-file 2\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	t.Setenv("ZZW_ENV", "3")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.EnvPrefix = "ZZW"
+	cp.AddFlagWithDefault(IntFlag, "def", false, 1)
+	cp.AddFlagWithConditionalDefault(IntFlag, "cond", false, func(cp *CmdParser) any { return 1 })
+	cp.AddFlag(IntFlag, "env", false)
+	cp.AddFlag(IntFlag, "file", false)
+	cp.AddFlagWithDefault(IntFlag, "cmd", false, 1)
+	cp.AddFlag(IntFlag, "none", false)
+	if err := cp.ParseFromArgs([]string{"-is", path, "-cmd", "4"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	for name, want := range map[string][2]bool{
+		"def":  {false, true},
+		"cond": {false, true},
+		"env":  {true, true},
+		"file": {true, true},
+		"cmd":  {true, true},
+		"none": {false, false},
+	} {
+		if got := [2]bool{cp.WasSet(name), cp.HasValue(name)}; got != want {
+			t.Errorf("-%s has WasSet %v and HasValue %v, want %v and %v", name, got[0], got[1], want[0], want[1])
+		}
+	}
+
+	// a default applied loads the variable, but the user still did not set it
+	if err := cp.ApplyDefaults(); err != nil {
+		t.Fatalf("ApplyDefaults failed: %v", err)
+	}
+	if !cp.IsLoaded("def") || cp.WasSet("def") || !cp.HasValue("def") {
+		t.Errorf("after ApplyDefaults -def has IsLoaded %v, WasSet %v, and HasValue %v, want true, false, and true",
+			cp.IsLoaded("def"), cp.WasSet("def"), cp.HasValue("def"))
+	}
+	if cp.WasSet("undeclared") || cp.HasValue("undeclared") {
+		t.Errorf("a name not declared has WasSet %v and HasValue %v", cp.WasSet("undeclared"), cp.HasValue("undeclared"))
+	}
+}
+
+// TestSetVersion checks that "-version" and "--version" write the version and the extra lines and return
+// ErrVersionRequested, that they are not special until SetVersion is called, and that a flag the program
+// declares named "version", or an argument after "--", is left for the program
+func TestSetVersion(t *testing.T) {
+	for _, arg := range []string{"-version", "--version"} {
+		var out bytes.Buffer
+		cp := NewCmdParser()
+		cp.SetOutput(&out)
+		cp.AddFlag(IntFlag, "count", true)
+		cp.SetVersion("tool 1.2.0", "commit abc123", "built 2024-01-02")
+		if err := cp.ParseFromArgs([]string{"-count", "1", arg}); err != ErrVersionRequested {
+			t.Errorf("%s gave %v, want ErrVersionRequested", arg, err)
+		}
+		if want := "tool 1.2.0\ncommit abc123\nbuilt 2024-01-02\n"; out.String() != want {
+			t.Errorf("%s wrote %q, want %q", arg, out.String(), want)
+		}
+	}
+
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	if err := cp.ParseFromArgs([]string{"-version"}); err == nil || err == ErrVersionRequested {
+		t.Errorf("-version without SetVersion gave %v, want an error for an unknown flag", err)
+	}
+
+	var out bytes.Buffer
+	cp = NewCmdParser()
+	cp.SetOutput(&out)
+	cp.SetVersion("tool 1.2.0")
+	cp.AllowReservedNames = true
+	cp.AddFlag(BoolFlag, "version", false)
+	if err := cp.ParseFromArgs([]string{"-version"}); err != nil || cp.GetVar("version") != true || out.Len() != 0 {
+		t.Errorf("a declared -version gave %v, value %v, writing %q, want nil, true, and nothing", err, cp.GetVar("version"), out.String())
+	}
+	cp = NewCmdParser()
+	cp.SetOutput(&out)
+	cp.SetVersion("tool 1.2.0")
+	cp.SetPositionalArity(0, -1)
+	if err := cp.ParseFromArgs([]string{"--", "-version"}); err != nil || out.Len() != 0 ||
+		!reflect.DeepEqual(cp.Passthrough(), []string{"-version"}) {
+		t.Errorf("-version after -- gave %v, writing %q, passing through %v, want nil, nothing, and [-version]",
+			err, out.String(), cp.Passthrough())
+	}
+}
+
+// TestSetEchoOnParse checks that a parse that succeeds writes the values of the variables as
+// WriteEffectiveConfig does, with a secret redacted, that one that fails writes nothing, and that nil stops the echo
+func TestSetEchoOnParse(t *testing.T) {
+	var echo bytes.Buffer
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.AddFlag(StringFlag, "token", false)
+	cp.SetSecret("token")
+	cp.SetEchoOnParse(&echo)
+
+	if err := cp.ParseFromArgs([]string{"-token", "hunter2"}); err == nil {
+		t.Fatalf("a parse without the required -count returned no error")
+	}
+	if echo.Len() != 0 {
+		t.Errorf("a parse that failed echoed %q, want nothing", echo.String())
+	}
+
+	if err := cp.ParseFromArgs([]string{"-count", "2", "-token", "hunter2"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	var want strings.Builder
+	if err := cp.WriteEffectiveConfig(&want); err != nil {
+		t.Fatalf("WriteEffectiveConfig failed: %v", err)
+	}
+	if echo.String() != want.String() || !strings.Contains(echo.String(), "-count 2") {
+		t.Errorf("a parse that succeeded echoed\n%s\nwant\n%s", echo.String(), want.String())
+	}
+	if strings.Contains(echo.String(), "hunter2") {
+		t.Errorf("the echo shows the secret value:\n%s", echo.String())
+	}
+
+	echo.Reset()
+	cp.SetEchoOnParse(nil)
+	if err := cp.ParseFromArgs([]string{"-count", "3"}); err != nil || echo.Len() != 0 {
+		t.Errorf("after SetEchoOnParse(nil) the parse gave %v, echoing %q, want nil and nothing", err, echo.String())
+	}
+}
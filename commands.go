@@ -0,0 +1,231 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddCommand declares a command of the program, e.g., "run" in "simtool -v run -config x.cfg", whose
+// flags are declared in their own CmdParser.  ParseFromArgs takes the first piece of the command line
+// that is neither a flag nor the value of one as the name of the command, parses the flags before it
+// with this CmdParser, as flags that hold for every command, and the flags after it with the command's.
+// The command's CmdParser may have commands of its own, e.g., "simtool cluster node add".  The description
+// is the one line help gives the command in the list of commands.  Unless a command "help" is declared,
+// "simtool help" writes the list, and "simtool help run" the help of command "run".  Execute calls
+// the handler, if not nil, with the command's CmdParser when the command is selected
+func (cp *CmdParser) AddCommand(name string, sub *CmdParser, description string, handler func(sub *CmdParser) error) {
+	cp.checkFrozen("AddCommand", name)
+	if name == "" || strings.HasPrefix(name, "-") {
+		panic(fmt.Sprintf("CmdParser.AddCommand given invalid command name %q\n", name))
+	}
+	if _, present := cp.commands[name]; present || cp.cmdAliases[name] != "" {
+		panic(fmt.Sprintf("CmdParser.AddCommand given command %s, which is already declared\n", name))
+	}
+	if sub == nil || sub == cp || sub.parent != nil {
+		panic(fmt.Sprintf("CmdParser.AddCommand given no CmdParser of its own for command %s\n", name))
+	}
+	if cp.commands == nil {
+		cp.commands = make(map[string]*CmdParser)
+	}
+	cp.commands[name] = sub
+	cp.commandOrder = append(cp.commandOrder, name)
+	sub.parent = cp
+	sub.name = name
+	sub.summary = description
+	sub.handler = handler
+}
+
+// SetRunnable has a CmdParser with commands of its own accept a command line that names none of them,
+// so that Execute calls its own handler, rather than writing help or complaining that no command was given
+func (cp *CmdParser) SetRunnable() {
+	cp.runnable = true
+}
+
+// Execute parses the arguments as ParseFromArgs does, and then calls the handler given to AddCommand
+// for the last command selected, returning what it returns.  Help and the version, when asked for,
+// return ErrHelpRequested and ErrVersionRequested without calling a handler.  A command that has
+// commands of its own is only run if selected without one of them, which SetRunnable allows
+func (cp *CmdParser) Execute(args []string) error {
+	if err := cp.ParseFromArgs(args); err != nil {
+		return err
+	}
+	path := cp.SelectedCommand()
+	if len(path) == 0 {
+		return nil
+	}
+	target := cp
+	for _, name := range path {
+		target = target.commands[name]
+	}
+	if target.handler == nil {
+		return fmt.Errorf("command %q has no handler to run", strings.Join(path, " "))
+	}
+	return target.handler(target)
+}
+
+// AddCommandAlias declares another name by which a command may be selected, e.g., "r" for "run".
+// The alias may not be the name of a command or of another alias
+func (cp *CmdParser) AddCommandAlias(command string, alias string) {
+	cp.checkFrozen("AddCommandAlias", alias)
+	if _, present := cp.commands[command]; !present {
+		panic(fmt.Sprintf("CmdParser.AddCommandAlias given unrecognized command name %s\n", command))
+	}
+	if _, present := cp.commands[alias]; present || cp.cmdAliases[alias] != "" || alias == "" || strings.HasPrefix(alias, "-") {
+		panic(fmt.Sprintf("CmdParser.AddCommandAlias given alias %q, which is already a command or alias\n", alias))
+	}
+	if cp.cmdAliases == nil {
+		cp.cmdAliases = make(map[string]string)
+	}
+	cp.cmdAliases[alias] = command
+}
+
+// SetDefaultCommand names the command taken when the command line names none, i.e., when it is empty or
+// holds only flags, which are then all the command's, e.g., "simtool -config x.cfg" for "simtool run -config x.cfg".
+// A word that is not a command is still an error
+func (cp *CmdParser) SetDefaultCommand(name string) {
+	if _, present := cp.commands[name]; !present {
+		panic(fmt.Sprintf("CmdParser.SetDefaultCommand given unrecognized command name %s\n", name))
+	}
+	cp.cmdDefault = name
+}
+
+// resolveCommand returns the name of the command for which a name stands, which is the name itself
+// unless it is an alias
+func (cp *CmdParser) resolveCommand(name string) string {
+	if command, present := cp.cmdAliases[name]; present {
+		return command
+	}
+	return name
+}
+
+// SelectedCommand returns the path of names of the commands selected by the last call of ParseFromArgs,
+// e.g., ["cluster" "node" "add"] for "simtool cluster node add", or nil if there was none.  Commands
+// selected by an alias are given by name
+func (cp *CmdParser) SelectedCommand() []string {
+	if cp.selected == "" {
+		return nil
+	}
+	return append([]string{cp.selected}, cp.commands[cp.selected].SelectedCommand()...)
+}
+
+// program returns how the program is invoked to reach this CmdParser, e.g., "simtool cluster node"
+// for the CmdParser of the command "node" of the command "cluster"
+func (cp *CmdParser) program() string {
+	if cp.parent == nil {
+		return progName()
+	}
+	return cp.parent.program() + " " + cp.name
+}
+
+// Command returns the CmdParser of the command with the input argument 'name', or nil if there is no such command
+func (cp *CmdParser) Command(name string) *CmdParser {
+	return cp.commands[name]
+}
+
+// commandIndex returns the position among the arguments of the name of the command, which is the first
+// that is neither a flag nor the value of one, or -1 if there is none.  An argument following a boolean
+// or undeclared flag is the command if it names one, and otherwise that flag's value, and likewise for
+// the arguments following the value of a list variable, which take further values.  No argument after "--" is the command
+func (cp *CmdParser) commandIndex(args []string) int {
+	pending, list := "", false
+	for idx, arg := range args {
+		if arg == "--" {
+			break
+		}
+		isFlag := strings.HasPrefix(arg, "-") && !argIsNumber(arg)
+		if pending != "" && !isFlag {
+			name := cp.resolve(pending)
+			if !cp.isCommand(arg) || (cp.IsFlag(name) && cp.vars[name].ArgType() != BoolFlag) || cp.namesFile(pending) {
+				pending, list = "", cp.isList(name)
+				continue
+			}
+		}
+		if list && !isFlag && !cp.isCommand(arg) {
+			continue
+		}
+		pending, list = "", false
+		if !isFlag {
+			return idx
+		}
+		if cp.namesFile(arg) {
+			pending = arg
+		} else if eq := strings.Index(arg, "="); eq > 0 {
+			list = cp.isList(cp.resolve(strings.Replace(arg[:eq], "-", "", 1)))
+		} else if !cp.takesNoValue(strings.Replace(arg, "-", "", 1)) {
+			pending = strings.Replace(arg, "-", "", 1)
+		}
+	}
+	return -1
+}
+
+// helpCommand is the command that writes the help of the program or of one of its commands,
+// unless a command of that name is declared
+const helpCommand = "help"
+
+// isCommand reports whether a name selects a command, which "help" does unless declared as a flag's value
+func (cp *CmdParser) isCommand(name string) bool {
+	_, present := cp.commands[cp.resolveCommand(name)]
+	return present || name == helpCommand
+}
+
+// helpFor writes the help of the command reached by following the path of names of commands from
+// this CmdParser, or its own help for an empty path
+func (cp *CmdParser) helpFor(path []string) error {
+	target := cp
+	for _, name := range path {
+		sub, present := target.commands[target.resolveCommand(name)]
+		if !present {
+			return target.unknownCommand(name)
+		}
+		target = sub
+	}
+	if err := target.Usage(target.out()); err != nil {
+		return err
+	}
+	return ErrHelpRequested
+}
+
+// unknownCommand returns the error for a name that is not that of a command, listing the commands
+// and suggesting the closest, if any is close
+func (cp *CmdParser) unknownCommand(name string) error {
+	msg := fmt.Sprintf("unknown command %q, expected one of: %s", name, strings.Join(cp.commandOrder, ", "))
+	best, bestDist := "", 0
+	for _, command := range cp.commandOrder {
+		if dist := editDistance(name, command); best == "" || dist < bestDist {
+			best, bestDist = command, dist
+		}
+	}
+	if best != "" && bestDist <= 2 {
+		msg += fmt.Sprintf("; did you mean %q?", best)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// editDistance returns the number of single character insertions, deletions, and substitutions
+// that turn one string into the other
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,266 @@
+package cmdline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// commandParser declares the flags and commands of the tests of commands: flags that hold for every
+// command, a command "run" with a required flag, and the nested commands "cluster node add"
+func commandParser(out io.Writer) *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(out)
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.AddFlag(StringFlag, "config", false)
+	cp.AddFlag(IntFlag, "level", false)
+	cp.AddFlag(IntSliceFlag, "ids", false)
+	run := NewCmdParser()
+	run.AddFlag(IntFlag, "n", true)
+	cp.AddCommand("run", run, "run the model", nil)
+	cluster := NewCmdParser()
+	cluster.AddFlag(StringFlag, "region", false)
+	node := NewCmdParser()
+	add := NewCmdParser()
+	add.AddFlag(StringFlag, "host", true)
+	node.AddCommand("add", add, "add a node", nil)
+	cluster.AddCommand("node", node, "manage nodes", nil)
+	cp.AddCommand("cluster", cluster, "manage the cluster", nil)
+	return cp
+}
+
+// TestCommandDispatch checks that the command is the first argument that is neither a flag nor the value of
+// one, whatever kind of flag comes before it, that the flags before it are set in the program's CmdParser and
+// those after it in the command's, and that a word that is not a command is an error suggesting the closest
+func TestCommandDispatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "global.cfg")
+	if err := os.WriteFile(path, []byte("-config base\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	cases := []struct {
+		args   []string
+		global map[string]any
+	}{
+		{[]string{"run", "-n", "3"}, map[string]any{}},
+		{[]string{"-v", "run", "-n", "3"}, map[string]any{"v": true}},
+		{[]string{"-level", "2", "run", "-n", "3"}, map[string]any{"level": 2}},
+		{[]string{"-config", "run", "run", "-n", "3"}, map[string]any{"config": "run"}},
+		{[]string{"-ids", "1", "2", "run", "-n", "3"}, map[string]any{"ids": []int{1, 2}}},
+		{[]string{"-ids=1,2", "-v", "run", "-n", "3"}, map[string]any{"ids": []int{1, 2}, "v": true}},
+		{[]string{"-is", path, "run", "-n", "3"}, map[string]any{"config": "base"}},
+	}
+	for _, c := range cases {
+		cp := commandParser(io.Discard)
+		if err := cp.ParseFromArgs(c.args); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", c.args, err)
+			continue
+		}
+		if got := cp.SelectedCommand(); !reflect.DeepEqual(got, []string{"run"}) {
+			t.Errorf("ParseFromArgs(%q) selected %q, want [run]", c.args, got)
+		}
+		for name, want := range c.global {
+			if got := cp.GetVar(name); !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseFromArgs(%q) gave -%s %v, want %v", c.args, name, got, want)
+			}
+		}
+		if got := cp.Command("run").GetVar("n"); got != 3 {
+			t.Errorf("ParseFromArgs(%q) gave run -n %v, want 3", c.args, got)
+		}
+	}
+
+	failures := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"rnu", "-n", "3"}, `unknown command "rnu", expected one of: run, cluster; did you mean "run"?`},
+		{[]string{"deploy"}, `unknown command "deploy", expected one of: run, cluster`},
+		{[]string{"-v"}, "no command given, expected one of: run, cluster"},
+		{[]string{"run", "-n", "3", "-level", "2"}, "Flags not declared in CmdParser: -level"}, // a global flag after the command
+	}
+	for _, c := range failures {
+		cp := commandParser(io.Discard)
+		err := cp.ParseFromArgs(c.args)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("ParseFromArgs(%q) gave %v, want %q", c.args, err, c.want)
+		}
+	}
+}
+
+// TestNestedCommands checks that commands of commands are selected by their path, with each CmdParser along it
+// taking its own flags, and that a required flag is checked only in the CmdParsers along the path selected
+func TestNestedCommands(t *testing.T) {
+	cp := commandParser(io.Discard)
+	if err := cp.ParseFromArgs([]string{"-v", "cluster", "-region", "eu", "node", "add", "-host", "h1"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.SelectedCommand(), []string{"cluster", "node", "add"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectedCommand is %q, want %q", got, want)
+	}
+	cluster := cp.Command("cluster")
+	if cp.GetVar("v") != true || cluster.GetVar("region") != "eu" || cluster.Command("node").Command("add").GetVar("host") != "h1" {
+		t.Errorf("the CmdParsers along the path hold -v %v, -region %v, and -host %v, want true, eu, and h1", cp.GetVar("v"),
+			cluster.GetVar("region"), cluster.Command("node").Command("add").GetVar("host"))
+	}
+	if got := cluster.SelectedCommand(); !reflect.DeepEqual(got, []string{"node", "add"}) {
+		t.Errorf("SelectedCommand of cluster is %q, want [node add]", got)
+	}
+
+	// run's required -n is not missing when run is not selected, but add's required -host is
+	err := commandParser(io.Discard).ParseFromArgs([]string{"cluster", "node", "add"})
+	var missing *MissingRequiredError
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Names, []string{"host"}) {
+		t.Errorf("ParseFromArgs(cluster node add) gave %v, want only -host missing", err)
+	}
+	err = commandParser(io.Discard).ParseFromArgs([]string{"run"})
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Names, []string{"n"}) {
+		t.Errorf("ParseFromArgs(run) gave %v, want only -n missing", err)
+	}
+}
+
+// TestCommandHelp checks that "help", an empty command line, and the program name alone write the help
+// of the program, listing its commands, that "help run" and "run -h" write the help of the command, each
+// returning ErrHelpRequested, and that help for a command not declared is an error
+func TestCommandHelp(t *testing.T) {
+	program := "Usage: " + progName() + " [flags] <command> [command flags]"
+	command := "Usage: " + progName() + " run [flags]"
+	cases := []struct {
+		what  string
+		parse func(cp *CmdParser) error
+		want  string
+	}{
+		{"help", func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"help"}) }, program},
+		{"no arguments", func(cp *CmdParser) error { return cp.ParseFromArgs([]string{}) }, program},
+		{"the program name alone", func(cp *CmdParser) error {
+			_, err := cp.ParseArgs([]string{progName()})
+			return err
+		}, program},
+		{"help run", func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"help", "run"}) }, command},
+		{"run -h", func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"run", "-h"}) }, command},
+	}
+	for _, c := range cases {
+		var b bytes.Buffer
+		cp := commandParser(&b)
+		if err := c.parse(cp); err != ErrHelpRequested {
+			t.Errorf("%s gave %v, want ErrHelpRequested", c.what, err)
+		}
+		if !strings.HasPrefix(b.String(), c.want+"\n") {
+			t.Errorf("%s wrote\n%s\nwant it to begin %q", c.what, b.String(), c.want)
+		}
+		if c.want == program && !strings.Contains(b.String(), "\n  run      run the model\n") {
+			t.Errorf("%s does not list the commands:\n%s", c.what, b.String())
+		}
+		if c.want == command && !strings.Contains(b.String(), "\n  -n <int>") {
+			t.Errorf("%s does not show the flags of run:\n%s", c.what, b.String())
+		}
+	}
+
+	var b bytes.Buffer
+	err := commandParser(&b).ParseFromArgs([]string{"help", "rnu"})
+	if err == nil || err.Error() != `unknown command "rnu", expected one of: run, cluster; did you mean "run"?` || b.Len() != 0 {
+		t.Errorf("help rnu gave %v and wrote %q, want an error suggesting run and nothing written", err, b.String())
+	}
+}
+
+// TestCommandAliasAndDefault checks that an alias selects its command, which SelectedCommand gives by name,
+// that the default command takes a command line naming no command, flags and all, and that a word that is
+// not a command is still an error
+func TestCommandAliasAndDefault(t *testing.T) {
+	cp := commandParser(io.Discard)
+	cp.AddCommandAlias("run", "r")
+	cp.Command("cluster").AddCommandAlias("node", "n")
+	for _, args := range [][]string{{"r", "-n", "3"}, {"-v", "r", "-n", "3"}} {
+		if err := cp.ParseFromArgs(args); err != nil {
+			t.Errorf("ParseFromArgs(%q) failed: %v", args, err)
+		} else if got := cp.SelectedCommand(); !reflect.DeepEqual(got, []string{"run"}) {
+			t.Errorf("ParseFromArgs(%q) selected %q, want [run]", args, got)
+		}
+	}
+	if err := cp.ParseFromArgs([]string{"cluster", "n", "add", "-host", "h1"}); err != nil {
+		t.Errorf("ParseFromArgs(cluster n add) failed: %v", err)
+	} else if got, want := cp.SelectedCommand(), []string{"cluster", "node", "add"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFromArgs(cluster n add) selected %q, want %q", got, want)
+	}
+
+	cp = commandParser(io.Discard)
+	cp.SetDefaultCommand("run")
+	if err := cp.ParseFromArgs([]string{"-n", "3"}); err != nil {
+		t.Errorf("ParseFromArgs(-n 3) with a default command failed: %v", err)
+	} else if !reflect.DeepEqual(cp.SelectedCommand(), []string{"run"}) || cp.Command("run").GetVar("n") != 3 {
+		t.Errorf("ParseFromArgs(-n 3) selected %q with run -n %v, want [run] and 3", cp.SelectedCommand(), cp.Command("run").GetVar("n"))
+	}
+	cp = commandParser(io.Discard)
+	cp.SetDefaultCommand("run")
+	var missing *MissingRequiredError
+	if err := cp.ParseFromArgs([]string{}); !errors.As(err, &missing) || !reflect.DeepEqual(cp.SelectedCommand(), []string{"run"}) {
+		t.Errorf("ParseFromArgs() with a default command gave %v, selecting %q, want run selected and its -n missing", err, cp.SelectedCommand())
+	}
+	if err := cp.ParseFromArgs([]string{"rnu", "-n", "3"}); err == nil || !strings.HasPrefix(err.Error(), `unknown command "rnu"`) {
+		t.Errorf("ParseFromArgs(rnu -n 3) with a default command gave %v, want the unknown command reported", err)
+	}
+}
+
+// TestExecute checks that Execute runs the handler of the command selected with the command's CmdParser and
+// returns what it returns, that help and the version return without running it, that a command selected
+// without a handler is an error, and that SetRunnable lets a command with commands of its own run by itself
+func TestExecute(t *testing.T) {
+	ran := []string{}
+	declare := func() *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.SetVersion("simtool 1.2")
+		run := NewCmdParser()
+		run.AddFlag(IntFlag, "n", false)
+		cp.AddCommand("run", run, "run the model", func(sub *CmdParser) error {
+			ran = append(ran, fmt.Sprintf("run -n %v", sub.GetVar("n")))
+			return nil
+		})
+		fail := NewCmdParser()
+		cp.AddCommand("fail", fail, "fail", func(sub *CmdParser) error { return errors.New("failed") })
+		cp.AddCommand("idle", NewCmdParser(), "do nothing", nil)
+		cluster := NewCmdParser()
+		cluster.AddCommand("status", NewCmdParser(), "show the status", func(sub *CmdParser) error {
+			ran = append(ran, "status")
+			return nil
+		})
+		cp.AddCommand("cluster", cluster, "manage the cluster", func(sub *CmdParser) error {
+			ran = append(ran, "cluster")
+			return nil
+		})
+		return cp
+	}
+	cases := []struct {
+		args     []string
+		runnable bool
+		want     error // nil, a sentinel error, or one with the message wanted
+		ran      []string
+	}{
+		{[]string{"run", "-n", "3"}, false, nil, []string{"run -n 3"}},
+		{[]string{"cluster", "status"}, false, nil, []string{"status"}},
+		{[]string{"fail"}, false, errors.New("failed"), nil},
+		{[]string{"idle"}, false, errors.New(`command "idle" has no handler to run`), nil},
+		{[]string{"run", "-h"}, false, ErrHelpRequested, nil},
+		{[]string{"-version", "run"}, false, ErrVersionRequested, nil},
+		{[]string{"cluster"}, false, ErrHelpRequested, nil},
+		{[]string{"cluster"}, true, nil, []string{"cluster"}},
+	}
+	for _, c := range cases {
+		ran = []string{}
+		cp := declare()
+		if c.runnable {
+			cp.Command("cluster").SetRunnable()
+		}
+		err := cp.Execute(c.args)
+		if (err == nil) != (c.want == nil) || err != nil && err != c.want && err.Error() != c.want.Error() {
+			t.Errorf("Execute(%q) gave %v, want %v", c.args, err, c.want)
+		}
+		if fmt.Sprint(ran) != fmt.Sprint(c.ran) {
+			t.Errorf("Execute(%q) ran %q, want %q", c.args, ran, c.ran)
+		}
+	}
+}
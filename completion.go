@@ -0,0 +1,203 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completionFlag is what the shell completion generators need to know about a flag
+type completionFlag struct {
+	name        string   // flag name, without the leading '-'
+	description string   // the flag's usage string
+	takesValue  bool     // a value follows the flag
+	takesFile   bool     // the value is the path of a file
+	choices     []string // the values the flag allows, if it allows only some, e.g., the names of log levels
+}
+
+// completionFlags gathers, in alphabetical order, what the completion generators need to know about
+// every declared flag and alias that is not hidden, plus "-is" and "--flags-file", through which Parse reads
+// flags from a file.  Sharing this keeps the scripts for all shells in step with the declarations
+func (cp *CmdParser) completionFlags() []completionFlag {
+	files := map[string]bool{"is": true}
+	for _, info := range cp.info {
+		if info.companion != "" {
+			files[info.companion] = true
+		}
+	}
+
+	flags := []completionFlag{{name: strings.TrimPrefix(flagsFile, "-"), description: "read flags from a file", takesValue: true, takesFile: true}}
+	if !cp.IsFlag("is") {
+		flags = append(flags, completionFlag{name: "is", description: "read flags from a file", takesValue: true, takesFile: true})
+	}
+	for _, name := range cp.sortedNames() {
+		if cp.info[name].hidden {
+			continue
+		}
+		choices := []string{}
+		if _, ok := cp.vars[name].(*logLevelVar); ok {
+			choices = logLevelNames
+		}
+		for _, flag := range append([]string{name}, cp.info[name].aliases...) {
+			flags = append(flags, completionFlag{name: flag,
+				description: cp.info[name].usage,
+				takesValue:  cp.vars[name].ArgType() != BoolFlag,
+				takesFile:   files[name],
+				choices:     choices})
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// progName returns the name by which the running program was invoked, less any directory
+func progName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// shellIdentifier turns a program name into something usable as part of a shell function name
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// singleQuote quotes text for a shell, closing and reopening the quotes around any embedded single quote
+func singleQuote(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
+
+// GenerateBashCompletion writes a bash script that completes the flags of the running program, file names
+// after flags that take a path, and the values allowed after flags that allow only some, e.g., the names of
+// log levels.  Sourcing the script enables it
+func (cp *CmdParser) GenerateBashCompletion(w io.Writer) error {
+	prog := progName()
+	fn := "_" + shellIdentifier(prog) + "_complete"
+	flags := cp.completionFlags()
+
+	names := []string{}
+	fileFlags := []string{}
+	valueFlags := []string{}
+	for _, cf := range flags {
+		names = append(names, "-"+cf.name)
+		if cf.takesFile {
+			fileFlags = append(fileFlags, "-"+cf.name)
+		} else if cf.takesValue && len(cf.choices) == 0 {
+			valueFlags = append(valueFlags, "-"+cf.name)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", prog)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "    case \"$prev\" in\n")
+	if len(fileFlags) > 0 {
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(fileFlags, "|"))
+		fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -f -- \"$cur\") )\n")
+		fmt.Fprintf(&b, "            return 0\n")
+		fmt.Fprintf(&b, "            ;;\n")
+	}
+	for _, cf := range flags {
+		if len(cf.choices) == 0 || cf.takesFile {
+			continue
+		}
+		fmt.Fprintf(&b, "        -%s)\n", cf.name)
+		fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", singleQuote(strings.Join(cf.choices, " ")))
+		fmt.Fprintf(&b, "            return 0\n")
+		fmt.Fprintf(&b, "            ;;\n")
+	}
+	if len(valueFlags) > 0 {
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(valueFlags, "|"))
+		fmt.Fprintf(&b, "            COMPREPLY=()\n")
+		fmt.Fprintf(&b, "            return 0\n")
+		fmt.Fprintf(&b, "            ;;\n")
+	}
+	fmt.Fprintf(&b, "    esac\n")
+	fmt.Fprintf(&b, "    COMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", singleQuote(strings.Join(names, " ")))
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, prog)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshEscape escapes the characters that are special inside a zsh _arguments option description
+func zshEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+// GenerateZshCompletion writes a zsh completion script for the running program, showing each flag's
+// usage string as its description and completing file names after flags that take a path and the
+// values allowed after flags that allow only some.
+// The script is meant to be installed as "_prog" in a directory on $fpath
+func (cp *CmdParser) GenerateZshCompletion(w io.Writer) error {
+	prog := progName()
+	flags := cp.completionFlags()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", prog)
+	fmt.Fprintf(&b, "_arguments")
+	for _, cf := range flags {
+		spec := "-" + cf.name + "[" + zshEscape(cf.description) + "]"
+		if cf.takesFile {
+			spec += ":file:_files"
+		} else if len(cf.choices) > 0 {
+			spec += ":value:(" + strings.Join(cf.choices, " ") + ")"
+		} else if cf.takesValue {
+			spec += ":value: "
+		}
+		fmt.Fprintf(&b, " \\\n  %s", singleQuote(spec))
+	}
+	fmt.Fprintf(&b, "\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote quotes text for fish, which within single quotes treats only \ and ' specially
+func fishQuote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(text) + "'"
+}
+
+// GenerateFishCompletion writes a fish completion script for the running program, showing each flag's
+// usage string as its description and completing file names after flags that take a path and the
+// values allowed after flags that allow only some.
+// The script is meant to be installed as "prog.fish" in a fish completions directory
+func (cp *CmdParser) GenerateFishCompletion(w io.Writer) error {
+	prog := progName()
+	flags := cp.completionFlags()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", prog)
+	for _, cf := range flags {
+		// fish takes a flag written with two dashes, e.g., --flags-file, as a long option
+		line := "complete -c " + fishQuote(prog) + " -o " + fishQuote(cf.name)
+		if strings.HasPrefix(cf.name, "-") {
+			line = "complete -c " + fishQuote(prog) + " -l " + fishQuote(cf.name[1:])
+		}
+		if cf.description != "" {
+			line += " -d " + fishQuote(cf.description)
+		}
+		if cf.takesFile {
+			line += " -r -F"
+		} else if len(cf.choices) > 0 {
+			line += " -r -f -a " + fishQuote(strings.Join(cf.choices, " "))
+		} else if cf.takesValue {
+			line += " -r -f"
+		} else {
+			line += " -f"
+		}
+		fmt.Fprintln(&b, line)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package cmdline
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// completionParser declares a representative set of flags: a required int, a bool with an alias and a
+// usage string needing escapes, a string with a file companion, a log level, and a hidden flag
+func completionParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs")
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.SetUsage("verbose", "say more: [all] of it")
+	cp.AddAlias("verbose", "v")
+	cp.AddFlag(StringFlag, "key", false)
+	cp.EnableFileCompanion("key")
+	cp.AddLogLevelFlag("level", false)
+	cp.SetUsage("level", "log level")
+	cp.AddFlag(StringFlag, "secret", false)
+	cp.SetHidden("secret")
+	return cp
+}
+
+// expectedScript fills the name of the test binary into a snapshot of a completion script, in place of
+// PROG, and the shell identifier made from it in place of IDENT
+func expectedScript(snapshot string) string {
+	return strings.NewReplacer("PROG", progName(), "IDENT", shellIdentifier(progName())).Replace(snapshot)
+}
+
+// checkScript compares a generated completion script with its snapshot
+func checkScript(t *testing.T, shell string, generate func(w io.Writer) error, snapshot string) {
+	t.Helper()
+	var b bytes.Buffer
+	if err := generate(&b); err != nil {
+		t.Fatalf("generating the %s script failed: %v", shell, err)
+	}
+	if want := expectedScript(snapshot); b.String() != want {
+		t.Errorf("%s script is\n%s\nwant\n%s", shell, b.String(), want)
+	}
+}
+
+// TestBashCompletion checks the bash script generated for a representative parser against a snapshot
+func TestBashCompletion(t *testing.T) {
+	checkScript(t, "bash", completionParser().GenerateBashCompletion, `# bash completion for PROG
+_IDENT_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+        --flags-file|-is|-key-file)
+            COMPREPLY=( $(compgen -f -- "$cur") )
+            return 0
+            ;;
+        -level)
+            COMPREPLY=( $(compgen -W 'debug info warn error fatal' -- "$cur") )
+            return 0
+            ;;
+        -count|-key)
+            COMPREPLY=()
+            return 0
+            ;;
+    esac
+    COMPREPLY=( $(compgen -W '--flags-file -count -is -key -key-file -level -v -verbose' -- "$cur") )
+}
+complete -F _IDENT_complete PROG
+`)
+}
+
+// TestZshCompletion checks the zsh script generated for a representative parser against a snapshot
+func TestZshCompletion(t *testing.T) {
+	checkScript(t, "zsh", completionParser().GenerateZshCompletion, `#compdef PROG
+
+_arguments \
+  '--flags-file[read flags from a file]:file:_files' \
+  '-count[number of runs]:value: ' \
+  '-is[read flags from a file]:file:_files' \
+  '-key[]:value: ' \
+  '-key-file[]:file:_files' \
+  '-level[log level]:value:(debug info warn error fatal)' \
+  '-v[say more\: \[all\] of it]' \
+  '-verbose[say more\: \[all\] of it]'
+`)
+}
+
+// TestFishCompletion checks the fish script generated for a representative parser against a snapshot
+func TestFishCompletion(t *testing.T) {
+	checkScript(t, "fish", completionParser().GenerateFishCompletion, `# fish completion for PROG
+complete -c 'PROG' -l 'flags-file' -d 'read flags from a file' -r -F
+complete -c 'PROG' -o 'count' -d 'number of runs' -r -f
+complete -c 'PROG' -o 'is' -d 'read flags from a file' -r -F
+complete -c 'PROG' -o 'key' -r -f
+complete -c 'PROG' -o 'key-file' -r -F
+complete -c 'PROG' -o 'level' -d 'log level' -r -f -a 'debug info warn error fatal'
+complete -c 'PROG' -o 'v' -d 'say more: [all] of it' -f
+complete -c 'PROG' -o 'verbose' -d 'say more: [all] of it' -f
+`)
+}
@@ -0,0 +1,134 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// constraintKind is how a constraint on a group of command variables restricts which of them are given
+type constraintKind int
+
+const (
+	exclusiveGroup  constraintKind = iota // at most one may be given
+	togetherGroup                         // all or none may be given
+	atLeastOneGroup                       // one or more must be given
+)
+
+// constraint restricts which of a group of command variables may be given together
+type constraint struct {
+	kind  constraintKind
+	names []string
+}
+
+// SetMutuallyExclusive declares that at most one of the command variables named may be given a value
+func (cp *CmdParser) SetMutuallyExclusive(names ...string) {
+	cp.addConstraint("SetMutuallyExclusive", exclusiveGroup, names)
+}
+
+// SetRequiredTogether declares that if any of the command variables named is given a value, all must be
+func (cp *CmdParser) SetRequiredTogether(names ...string) {
+	cp.addConstraint("SetRequiredTogether", togetherGroup, names)
+}
+
+// SetAtLeastOne declares that at least one of the command variables named must be given a value
+func (cp *CmdParser) SetAtLeastOne(names ...string) {
+	cp.addConstraint("SetAtLeastOne", atLeastOneGroup, names)
+}
+
+// addConstraint records a constraint on a group of command variables, panicking as the method
+// that declares it if a name is not that of a variable
+func (cp *CmdParser) addConstraint(method string, kind constraintKind, names []string) {
+	if len(names) < 2 {
+		panic(fmt.Sprintf("CmdParser.%s needs at least two variable names\n", method))
+	}
+	for _, name := range names {
+		if !cp.IsFlag(name) {
+			panic(fmt.Sprintf("CmdParser.%s given unrecognized variable name %s\n", method, name))
+		}
+	}
+	cp.constraints = append(cp.constraints, constraint{kind: kind, names: append([]string{}, names...)})
+}
+
+// flagList renders names of command variables as flags, e.g., "-a, -b"
+func flagList(names []string) string {
+	return "-" + strings.Join(names, ", -")
+}
+
+// ValidateGroups checks the values held against every constraint declared by SetMutuallyExclusive,
+// SetRequiredTogether, and SetAtLeastOne, in the order declared, returning one error that describes
+// every constraint violated, or nil.  A variable counts as given when the user set it, see WasSet, so neither
+// a default nor a value loaded by ApplyDefaults counts.  A parse runs it once the required variables are present,
+// and it can be run again after SetVar
+func (cp *CmdParser) ValidateGroups() error {
+	if problems := cp.groupViolations(nil); len(problems) > 0 {
+		return fmt.Errorf("flag constraints violated: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// groupViolations describes each constraint violated, in the order declared.  Variables among those that
+// failed, which were given values they cannot hold, count as given, so that no knock-on violation is reported
+func (cp *CmdParser) groupViolations(failed map[string]bool) []string {
+	problems := []string{}
+	for _, c := range cp.constraints {
+		given := []string{}
+		missing := []string{}
+		for _, name := range c.names {
+			if cp.WasSet(name) || failed[name] {
+				given = append(given, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		switch c.kind {
+		case exclusiveGroup:
+			if len(given) > 1 {
+				problems = append(problems, fmt.Sprintf("only one of %s may be given, got %s", flagList(c.names), flagList(given)))
+			}
+			break
+		case togetherGroup:
+			if len(given) > 0 && len(missing) > 0 {
+				problems = append(problems, fmt.Sprintf("%s must be given together, missing %s", flagList(c.names), flagList(missing)))
+			}
+			break
+		case atLeastOneGroup:
+			if len(given) == 0 {
+				problems = append(problems, fmt.Sprintf("at least one of %s must be given", flagList(c.names)))
+			}
+			break
+		}
+	}
+	return problems
+}
+
+// Validate checks the values held as a parse does once all values are in: that every required variable
+// has a value, and that the constraints checked by ValidateGroups hold.  Each value was already checked
+// against its type and any bounds as it was set.  It returns one error describing every problem found,
+// or nil.  Since the Apply methods leave these checks to the caller, a program can check a file of flags
+// without running, e.g., for a -check-config flag:
+//
+//	if err := cp.ApplyFile(path); err != nil {
+//		return err
+//	}
+//	return cp.Validate()
+func (cp *CmdParser) Validate() error {
+	problems := []error{}
+	if err := cp.missingRequired(nil); err != nil {
+		problems = append(problems, err)
+	}
+	if err := cp.ValidateGroups(); err != nil {
+		problems = append(problems, err)
+	}
+	return combineErrors(problems)
+}
+
+// combineErrors returns the one error of a list as it is, or a *MultiError holding them all, or nil for none
+func combineErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return &MultiError{errs: errs}
+}
@@ -0,0 +1,104 @@
+package cmdline
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// groupParser declares flags under each kind of constraint
+func groupParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(BoolFlag, "json", false)
+	cp.AddFlag(BoolFlag, "yaml", false)
+	cp.AddFlag(StringFlag, "user", false)
+	cp.AddFlag(StringFlag, "password", false)
+	cp.AddFlag(StringFlag, "in", false)
+	cp.AddFlag(StringFlag, "url", false)
+	cp.SetMutuallyExclusive("json", "yaml")
+	cp.SetRequiredTogether("user", "password")
+	cp.SetAtLeastOne("in", "url")
+	return cp
+}
+
+// TestValidateGroups checks that a parse reports each kind of constraint violated, and that ValidateGroups
+// checks the values again after SetVar
+func TestValidateGroups(t *testing.T) {
+	exclusive := "only one of -json, -yaml may be given, got -json, -yaml"
+	together := "-user, -password must be given together, missing -password"
+	at_least := "at least one of -in, -url must be given"
+	for args, want := range map[string][]string{
+		"-in a":                {},
+		"-in a -json -yaml":    {exclusive},
+		"-url u -user me":      {together},
+		"-json":                {at_least},
+		"-json -yaml -user me": {exclusive, together, at_least},
+	} {
+		cp := groupParser()
+		err := cp.ParseFromArgs(strings.Fields(args))
+		if len(want) == 0 {
+			if err != nil {
+				t.Errorf("ParseFromArgs(%q) failed: %v", args, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("ParseFromArgs(%q) returned no error, want %q", args, want)
+			continue
+		}
+		for _, violation := range want {
+			if !strings.Contains(err.Error(), "flag constraint violated: "+violation) {
+				t.Errorf("ParseFromArgs(%q) gave %v, which does not report %q", args, err, violation)
+			}
+		}
+	}
+
+	cp := groupParser()
+	if err := cp.ParseFromArgs([]string{"-in", "a", "-json"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if err := cp.SetVar("yaml", "true"); err != nil {
+		t.Fatalf("SetVar failed: %v", err)
+	}
+	if err := cp.ValidateGroups(); err == nil || !strings.Contains(err.Error(), "only one of -json, -yaml") {
+		t.Errorf("ValidateGroups after SetVar gave %v, want -json and -yaml reported", err)
+	}
+}
+
+// TestValidate checks that Validate, run after the Apply methods, which leave these checks to the caller,
+// returns a *MultiError holding both a missing required variable and a constraint violated, the one problem
+// found as it is, and nil once all is well
+func TestValidate(t *testing.T) {
+	cp := groupParser()
+	cp.AddFlag(StringFlag, "out", true)
+	if err := cp.ApplyArgs([]string{"-json", "-yaml", "-in", "a.txt"}); err != nil {
+		t.Fatalf("ApplyArgs failed: %v", err)
+	}
+	err := cp.Validate()
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors()) != 2 {
+		t.Fatalf("Validate gave %v, want a *MultiError of two errors", err)
+	}
+	var missing *MissingRequiredError
+	if !errors.As(multi.Errors()[0], &missing) || len(missing.Names) != 1 || missing.Names[0] != "out" {
+		t.Errorf("the first error of Validate is %v, want a *MissingRequiredError naming -out", multi.Errors()[0])
+	}
+	if !strings.Contains(multi.Errors()[1].Error(), "only one of -json, -yaml may be given") {
+		t.Errorf("the second error of Validate is %v, want the -json, -yaml constraint violated", multi.Errors()[1])
+	}
+
+	if err := cp.ApplyArgs([]string{"-out", "b.txt"}); err != nil {
+		t.Fatalf("ApplyArgs failed: %v", err)
+	}
+	err = cp.Validate()
+	if errors.As(err, &multi) || err == nil || !strings.Contains(err.Error(), "only one of -json, -yaml") {
+		t.Errorf("with -out given Validate gave %v, want the constraint violated alone", err)
+	}
+
+	cp.Unset("yaml")
+	if err := cp.Validate(); err != nil {
+		t.Errorf("with all well Validate gave %v, want nil", err)
+	}
+}
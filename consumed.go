@@ -0,0 +1,59 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+)
+
+// ExemptUnconsumed leaves the command variable with the input argument 'name' out of Unconsumed and
+// ReportUnconsumed, e.g., one whose value the program reads some other way than GetVar and the typed getters
+func (cp *CmdParser) ExemptUnconsumed(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.ExemptUnconsumed given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].exempt = true
+}
+
+// Unconsumed returns the names of the command variables given values from the user's input, the command
+// line, a file, or the environment, whose values the program has never asked for through GetVar, GetVarE,
+// or a typed getter such as GetIntSlice, in the order declared.  Each is a flag the program no longer
+// reads, or a value the user set that the program silently ignored.  Variables exempted by ExemptUnconsumed
+// and those holding only their defaults are left out
+func (cp *CmdParser) Unconsumed() []string {
+	names := []string{}
+	for _, name := range cp.order {
+		info := cp.info[name]
+		if info.consumed || info.exempt || !cp.WasSet(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ReportUnconsumed writes a line for each command variable that Unconsumed returns, saying where its value
+// came from, e.g., "flag -retries was set from run.cfg:3 but never read", and is meant to be deferred at the
+// start of the program so that it runs as the program ends
+func (cp *CmdParser) ReportUnconsumed(w io.Writer) error {
+	for _, name := range cp.Unconsumed() {
+		if _, err := fmt.Fprintf(w, "flag -%s was set %s but never read\n", name, cp.setFrom(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFrom says where the value of a command variable came from, for a message that follows "was set"
+func (cp *CmdParser) setFrom(name string) string {
+	at := cp.info[name].origin
+	switch at.source {
+	case SourceFile:
+		return "from " + at.position()
+	case SourceEnv:
+		if at.file != "" {
+			return "from $" + at.file
+		}
+		return "from the environment"
+	}
+	return "on the " + at.source.String()
+}
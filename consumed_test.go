@@ -0,0 +1,56 @@
+package cmdline
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestUnconsumed checks that Unconsumed returns the variables given values but never read, leaving out those
+// read, those exempted, and those holding only their defaults, and that ReportUnconsumed says where each
+// value came from
+func TestUnconsumed(t *testing.T) {
+	t.Setenv("ZZU_TIMEOUT", "30")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.EnvPrefix = "ZZU"
+	cp.AddFlag(IntFlag, "retries", false)
+	cp.AddFlag(IntFlag, "timeout", false)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(StringFlag, "trace", false)
+	cp.AddFlag(IntFlag, "read", false)
+	cp.AddFlagWithDefault(IntFlag, "workers", false, 4)
+	cp.ExemptUnconsumed("trace")
+	if err := cp.ParseFromReader(strings.NewReader("# retries\n\n-retries 3\n"), "run.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	if err := cp.ParseFromArgs([]string{"-name", "x", "-trace", "on", "-read", "1"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	cp.GetVar("read")
+
+	want := []string{"retries", "timeout", "name"}
+	if got := cp.Unconsumed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unconsumed gave %v, want %v", got, want)
+	}
+	var report bytes.Buffer
+	if err := cp.ReportUnconsumed(&report); err != nil {
+		t.Fatalf("ReportUnconsumed failed: %v", err)
+	}
+	lines := "flag -retries was set from run.cfg:3 but never read\n" +
+		"flag -timeout was set from $ZZU_TIMEOUT but never read\n" +
+		"flag -name was set on the command line but never read\n"
+	if report.String() != lines {
+		t.Errorf("ReportUnconsumed wrote\n%s\nwant\n%s", report.String(), lines)
+	}
+
+	// reading a value through GetVarE consumes it too
+	if _, err := cp.GetVarE("name"); err != nil {
+		t.Fatalf("GetVarE failed: %v", err)
+	}
+	if got := cp.Unconsumed(); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("after reading -name, Unconsumed gave %v, want %v", got, want[:2])
+	}
+}
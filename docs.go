@@ -0,0 +1,186 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// templateValue renders a value for a file of flags, quoting it if it would not otherwise read back
+// as itself, e.g., an empty string, one holding white space or a '#', or one beginning with a quote or
+// a '-' that is not a number.  It is quoted with whichever quote it does not hold, since a quoted piece
+// runs to the next of its quote.  It reports false for a value that cannot be written to read back as
+// itself, one needing quotes that holds both kinds, or one holding a line break, which would end its line
+func templateValue(text string) (string, bool) {
+	if strings.ContainsAny(text, "\r\n") {
+		return text, false
+	}
+	needs_quotes := text == "" || strings.IndexFunc(text, unicode.IsSpace) >= 0 || strings.Contains(text, "#") ||
+		isQuote([]rune(text)[0]) || (strings.HasPrefix(text, "-") && !argIsNumber(text)) || strings.HasSuffix(text, "\\")
+	switch {
+	case !needs_quotes:
+		return text, true
+	case !strings.Contains(text, "'"):
+		return "'" + text + "'", true
+	case !strings.Contains(text, `"`):
+		return `"` + text + `"`, true
+	}
+	return text, false
+}
+
+// GenerateTemplate writes a file of flags in the format read by ParseFromFile, to be filled in.  Each flag
+// that is not hidden gets a comment with its usage string and type, followed by the flag itself.
+// Required flags are given with a placeholder for their value, and optional ones are commented out,
+// with their default when they have one.  A default that cannot be written to read back as itself, see
+// WriteEffectiveConfig, is an error, and nothing is written
+func (cp *CmdParser) GenerateTemplate(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# flags for %s\n", progName())
+	for _, flag := range cp.usageData(false, 0).Flags {
+		about := flag.Type
+		if flag.Required {
+			about += ", required"
+		}
+		if flag.Deprecated != "" {
+			about += ", deprecated"
+		}
+		fmt.Fprintln(&b)
+		if flag.Usage != "" {
+			fmt.Fprintf(&b, "# %s (%s)\n", flag.Usage, about)
+		} else {
+			fmt.Fprintf(&b, "# (%s)\n", about)
+		}
+
+		line := "-" + flag.Name
+		if flag.Type != typeName(BoolFlag) {
+			value := "<" + flag.Type + ">"
+			if info := cp.info[flag.Name]; info.hasDef {
+				def, ok := templateValue(cp.display(flag.Name, info.def))
+				if !ok {
+					return fmt.Errorf("GenerateTemplate cannot write the default of -%s, %q, so that it reads back as itself", flag.Name, def)
+				}
+				value = def
+			}
+			line += " " + value
+		}
+		if !flag.Required {
+			line = "# " + line
+		}
+		fmt.Fprintln(&b, line)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text for a cell of a Markdown table
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", "<br>").Replace(text)
+}
+
+// markdownAnchor makes an anchor name from a title, e.g., "input-options" from "Input options"
+func markdownAnchor(title string) string {
+	anchor := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, title)
+	return strings.Trim(anchor, "-")
+}
+
+// markdownRow renders a flag as a row of a Markdown table with columns for its names, type with the values
+// it allows, e.g., "debug|info|..." for a log level or "string, 3 to 32 characters", whether it is required,
+// its default, and its description, ending in a newline
+func markdownRow(flag UsageFlag) string {
+	names := []string{"`-" + flag.Name + "`"}
+	for _, alias := range flag.Aliases {
+		names = append(names, "`-"+alias+"`")
+	}
+	required := "no"
+	if flag.Required {
+		required = "yes"
+	}
+	def := ""
+	if flag.HasDefault {
+		def = "`" + flag.Default + "`"
+	}
+	description := flag.Usage
+	if flag.Deprecated != "" {
+		description = strings.TrimSpace(description + " **Deprecated:** " + flag.Deprecated)
+	}
+	kind := flag.Type
+	if flag.Bounds != "" {
+		kind += ", " + flag.Bounds
+	}
+	return fmt.Sprintf("| %s | %s | %s | %s | %s |\n", strings.Join(names, ", "), markdownCell(kind),
+		required, markdownCell(def), markdownCell(description))
+}
+
+// UsageMarkdown returns the help for the flags as a single Markdown table, with columns Name, Type,
+// Required, Default, and Description and a row for each flag that is not hidden, sorted by name
+// whatever the UsageOrder, so that the output, e.g., embedded in a README, changes only with the declarations
+func (cp *CmdParser) UsageMarkdown() string {
+	flags := cp.usageData(false, 0).Flags
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+
+	var b strings.Builder
+	b.WriteString("| Name | Type | Required | Default | Description |\n")
+	b.WriteString("|------|------|----------|---------|-------------|\n")
+	for _, flag := range flags {
+		b.WriteString(markdownRow(flag))
+	}
+	return b.String()
+}
+
+// GenerateMarkdown writes a command line reference for the program in Markdown: the summary given to
+// SetDescription, a table of the flags that are not hidden for each group, see SetGroup, giving each
+// flag's aliases, type, the values it allows, whether it is required, its default, and its usage string, any examples given to
+// AddExample, and a section on files of flags with an example from GenerateTemplate.  Each section has an anchor to link to.
+// The output depends only on the declarations, so it can be committed and diffed
+func (cp *CmdParser) GenerateMarkdown(w io.Writer) error {
+	prog := progName()
+	data := cp.usageData(false, 0)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Command line reference: %s\n", prog)
+	if data.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", data.Description)
+	}
+
+	for _, section := range data.Sections {
+		title := strings.TrimSuffix(section.Title, ":")
+		fmt.Fprintf(&b, "\n<a id=\"%s\"></a>\n## %s\n\n", markdownAnchor(title), title)
+		if section.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", section.Description)
+		}
+		fmt.Fprintf(&b, "| Flag | Type | Required | Default | Description |\n")
+		fmt.Fprintf(&b, "|------|------|----------|---------|-------------|\n")
+		for _, flag := range section.Flags {
+			b.WriteString(markdownRow(flag))
+		}
+	}
+
+	if len(data.Examples) > 0 {
+		fmt.Fprintf(&b, "\n<a id=\"examples\"></a>\n## Examples\n")
+	}
+	for _, ex := range data.Examples {
+		fmt.Fprintf(&b, "\n%s\n\n```\n%s %s\n```\n", ex.Description, prog, ex.Command)
+	}
+
+	var example strings.Builder
+	if err := cp.GenerateTemplate(&example); err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "\n<a id=\"files-of-flags\"></a>\n## Files of flags\n\n")
+	fmt.Fprintf(&b, "Flags may be read from a file named among the other flags, as in `%s -is run.cfg` or `%s --flags-file run.cfg`, ", prog, prog)
+	fmt.Fprintf(&b, "where flags on the command line override those in the file. ")
+	fmt.Fprintf(&b, "The file holds flags as they are written on the command line, on any number of lines. ")
+	fmt.Fprintf(&b, "Anything on a line after a `#` is a comment, and a line ending in `\\` continues on the next. ")
+	fmt.Fprintf(&b, "For example:\n\n```\n%s```\n", example.String())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,53 @@
+package cmdline
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestUsageMarkdown checks that the Markdown help holds a table row for each flag that is not hidden,
+// with a '|' in a description escaped so that it does not split the row
+func TestUsageMarkdown(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number | of runs")
+	cp.AddFlagWithDefault(FloatFlag, "rate", false, 0.5)
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.AddStringFlagWithLength("user", false, 3, 8)
+	cp.AddFlag(StringFlag, "hidden", false)
+	cp.SetHidden("hidden")
+
+	text := cp.UsageMarkdown()
+	want := []string{
+		"| Name | Type | Required | Default | Description |",
+		"|------|------|----------|---------|-------------|",
+		"| `-count` | int | yes |  | number \\| of runs |",
+		"| `-rate` | float | no | `0.5` |  |",
+		"| `-user` | string, 3 to 8 characters | no |  |  |",
+		"| `-verbose` | bool | no |  |  |",
+	}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("UsageMarkdown has %d lines, want %d:\n%s", len(lines), len(want), text)
+	}
+	for idx, line := range lines {
+		if line != want[idx] {
+			t.Errorf("line %d of UsageMarkdown is %q, want %q", idx+1, line, want[idx])
+		}
+	}
+
+	var b strings.Builder
+	if err := cp.GenerateMarkdown(&b); err != nil {
+		t.Fatalf("GenerateMarkdown failed: %v", err)
+	}
+	for _, row := range want[2:] {
+		if !strings.Contains(b.String(), row+"\n") {
+			t.Errorf("GenerateMarkdown does not hold the row %q:\n%s", row, b.String())
+		}
+	}
+	if strings.Contains(text, "-hidden") || strings.Contains(b.String(), "`-hidden`") {
+		t.Errorf("the Markdown shows the hidden flag")
+	}
+}
@@ -0,0 +1,337 @@
+package cmdline
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MissingRequiredError is returned by a parse that leaves required command variables without values
+type MissingRequiredError struct {
+	Names []string // names of the missing variables, without the leading '-', sorted
+	Hint  string   // how to get full usage, e.g., "run with -h for full usage"
+
+	lines []string // for each missing variable, its flag syntax and usage string as help shows them
+}
+
+// Error lists the missing flags with the kind of value each takes and its usage string, followed by the hint,
+// e.g., "missing required flag: -rate <float>  arrival rate" when only one is missing
+func (e *MissingRequiredError) Error() string {
+	var b strings.Builder
+	if len(e.lines) == 1 {
+		b.WriteString("missing required flag: " + e.lines[0])
+	} else {
+		b.WriteString("missing required flags:")
+		for _, line := range e.lines {
+			b.WriteString("\n  " + line)
+		}
+	}
+	if e.Hint != "" {
+		b.WriteString("\n" + e.Hint)
+	}
+	return b.String()
+}
+
+// isMissing reports whether a variable that must be given a value lacks one: a required variable the user
+// has not set, see WasSet, that has no default read by LoadDefaultsFromFile either, or one made RequiredOrDefault
+// that has no value at all
+func (cp *CmdParser) isMissing(name string) bool {
+	info := cp.info[name]
+	if info.orDefault {
+		return !cp.HasValue(name)
+	}
+	return cp.vars[name].Required() && !cp.WasSet(name) && info.defFile == ""
+}
+
+// missingRequired returns a MissingRequiredError for the variables that are missing, see isMissing,
+// sorted by name, or nil if there are none.  Variables among those that failed, which were
+// given values they cannot hold, are left out
+func (cp *CmdParser) missingRequired(failed map[string]bool) error {
+	names := []string{}
+	width := 0
+	for _, name := range cp.sortedNames() {
+		if cp.isMissing(name) && !failed[name] {
+			names = append(names, name)
+			if syntax := cp.flagSyntax(name); len(syntax) > width {
+				width = len(syntax)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	// line up the usage strings as help does
+	e := &MissingRequiredError{Names: names}
+	for _, name := range names {
+		line := cp.flagSyntax(name)
+		if usage := cp.info[name].usage; usage != "" {
+			line += strings.Repeat(" ", width-len(line)+2) + usage
+		}
+		e.lines = append(e.lines, line)
+	}
+	e.Hint = cp.usageHint()
+	return e
+}
+
+// UnknownFlagError is returned by a parse that meets flags that are not declared, unless UnknownAsPositional is set
+type UnknownFlagError struct {
+	Names []string // names of the flags, without the leading '-', in the order seen
+
+	list string // the flags, each with where it was found in a file
+}
+
+// Error lists the flags, e.g., "Flags not declared in CmdParser: -sped (run.cfg:3)"
+func (e *UnknownFlagError) Error() string {
+	return "Flags not declared in CmdParser: " + e.list
+}
+
+// unknownError returns an UnknownFlagError for the flags seen that were not declared
+func (pr *pairer) unknownError() error {
+	e := &UnknownFlagError{list: pr.unknownList()}
+	for _, fv := range pr.unknown {
+		e.Names = append(e.Names, fv.flag)
+	}
+	return e
+}
+
+// intRangeError is the error for an integer too big, or too negative, for the number of bits that hold it.
+// It unwraps to the *strconv.NumError
+type intRangeError struct {
+	value string
+	bits  int
+	err   error
+}
+
+// Error names the width that the value does not fit, e.g., "value "3000000000" is out of range for a 32-bit int"
+func (e *intRangeError) Error() string {
+	return fmt.Sprintf("value %q is out of range for a %d-bit int", e.value, e.bits)
+}
+
+// Unwrap returns the *strconv.NumError
+func (e *intRangeError) Unwrap() error {
+	return e.err
+}
+
+// BadValueError describes a value that a command variable cannot hold.  It unwraps to the error from
+// converting the value, if any, e.g., a *strconv.NumError, so errors.Is(err, strconv.ErrSyntax) works
+type BadValueError struct {
+	Flag     string // name of the variable, without the leading '-'
+	Value    string // the value, or "****" for a secret variable
+	Type     string // kind of value the variable takes, e.g., "int"
+	Position string // file and line the value came from, e.g., "run.cfg:3", if it came from a file
+	Err      error  // what converting the value reported, if it may be shown
+
+	detail string // what was wrong, when there is more to say than that the value is not of the type
+}
+
+// Error says what was wrong, e.g., "run.cfg:3: flag -count: cannot parse "x" as int"
+func (e *BadValueError) Error() string {
+	prefix := ""
+	if e.Position != "" {
+		prefix = e.Position + ": "
+	}
+	if e.detail != "" {
+		return fmt.Sprintf("%sflag -%s: %s", prefix, e.Flag, e.detail)
+	}
+	return fmt.Sprintf("%sflag -%s: cannot parse %q as %s", prefix, e.Flag, e.Value, e.Type)
+}
+
+// Unwrap returns the error from converting the value
+func (e *BadValueError) Unwrap() error {
+	return e.Err
+}
+
+// valueError returns the error to report for a value the command variable with the input argument 'name'
+// cannot hold, given the error from checking it and where the value came from.  A secret value is
+// redacted, and so is any error that might show it
+func (cp *CmdParser) valueError(name string, value string, err error, at origin) *BadValueError {
+	v := cp.vars[name]
+	e := &BadValueError{Flag: name, Value: value, Type: strings.Trim(valueName(v), "<>"), Err: err}
+	if e.Type == "" {
+		e.Type = typeName(v.ArgType())
+	}
+	if at.source == SourceFile {
+		e.Position = at.position()
+	}
+
+	// the errors for a length, a log level, an int's range, a list of ranges, an element of a list, and an entry of a map say more
+	// than the value and type
+	_, bounded := v.(*lengthStringVar)
+	_, level := v.(*logLevelVar)
+	var range_err *intRangeError
+	ranged := errors.As(err, &range_err)
+	if bounded || level || ranged || v.ArgType() == IntRangeListFlag || v.ArgType() == IntSliceFlag ||
+		v.ArgType() == FloatSliceFlag || v.ArgType() == TypedMapFlag {
+		e.detail = err.Error()
+	}
+	if cp.IsSecret(name) {
+		e.Value = redacted
+		e.detail = ""
+		if bounded {
+			e.detail = "value " + redacted + " has the wrong length"
+		}
+		if ranged {
+			e.detail = fmt.Sprintf("value %s is out of range for a %d-bit int", redacted, range_err.bits)
+		}
+		var num_err *strconv.NumError
+		e.Err = nil
+		if errors.As(err, &num_err) {
+			e.Err = num_err.Err
+		}
+	}
+	return e
+}
+
+// FormatError describes a piece of a command line or file of flags that does not fit, e.g., a value
+// with no flag before it
+type FormatError struct {
+	Token    string // the piece
+	Position string // file and line the piece is on, e.g., "run.cfg:3", if it is in a file
+	Index    int    // zero-based position of the piece among the arguments parsed, or -1 if it is not one of them
+}
+
+// Error says which piece does not fit and where, e.g., "argument 7 ("out.dat"): expected a flag beginning
+// with '-'" or "run.cfg:3: "out.dat": expected a flag beginning with '-'".  The index of an argument
+// parsed by ParseArgs or ParseFromCmdLine is its position in os.Args
+func (e *FormatError) Error() string {
+	problem := "expected a flag beginning with '-'"
+	if e.Position != "" {
+		return fmt.Sprintf("%s: %q: %s", e.Position, e.Token, problem)
+	}
+	if e.Index >= 0 {
+		return fmt.Sprintf("argument %d (%q): %s", e.Index, e.Token, problem)
+	}
+	return fmt.Sprintf("%q: %s", e.Token, problem)
+}
+
+// ArityError describes a parse that left fewer or more positional arguments than SetPositionalArity allows
+type ArityError struct {
+	Min      int      // the fewest positional arguments allowed
+	Max      int      // the most allowed, or -1 for no bound
+	Received int      // how many the parse left
+	Extra    []string // the arguments past the most allowed, if there were too many
+}
+
+// Error says how many positional arguments were expected and received, and which are extra or missing,
+// e.g., "expected exactly 2 positional arguments, received 3: extra "c"" or
+// "expected 1 to 4 positional arguments, received 0: missing argument 1"
+func (e *ArityError) Error() string {
+	var expected string
+	bound := e.Max
+	switch {
+	case e.Min == e.Max:
+		expected = fmt.Sprintf("exactly %d", e.Min)
+		break
+	case e.Max < 0:
+		expected = fmt.Sprintf("at least %d", e.Min)
+		bound = e.Min
+		break
+	case e.Min == 0:
+		expected = fmt.Sprintf("at most %d", e.Max)
+		break
+	default:
+		expected = fmt.Sprintf("%d to %d", e.Min, e.Max)
+	}
+	noun := "positional arguments"
+	if bound == 1 {
+		noun = "positional argument"
+	}
+	msg := fmt.Sprintf("expected %s %s, received %d", expected, noun, e.Received)
+	if len(e.Extra) > 0 {
+		quoted := []string{}
+		for _, extra := range e.Extra {
+			quoted = append(quoted, strconv.Quote(extra))
+		}
+		return msg + ": extra " + strings.Join(quoted, " ")
+	}
+	switch missing := e.Min - e.Received; {
+	case missing == 1:
+		msg += fmt.Sprintf(": missing argument %d", e.Min)
+		break
+	case missing > 1:
+		msg += fmt.Sprintf(": missing arguments %d through %d", e.Received+1, e.Min)
+		break
+	}
+	return msg
+}
+
+// MultiError is returned by a parse that found more than one problem, e.g., an undeclared flag, two values
+// of the wrong type, and a missing required flag, so that all of them can be fixed at once
+type MultiError struct {
+	errs []error
+}
+
+// Errors returns the problems found, in the order found
+func (e *MultiError) Errors() []error {
+	return e.errs
+}
+
+// Error counts the problems and lists them, one to a line, e.g., "2 problems found parsing command line:"
+// followed by "  flag -count: cannot parse "x" as int" and "  missing required flag: -rate <float>"
+func (e *MultiError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems found parsing command line:", len(e.errs))
+	for _, err := range e.errs {
+		b.WriteString("\n  " + strings.ReplaceAll(err.Error(), "\n", "\n    "))
+	}
+	return b.String()
+}
+
+// Unwrap returns the problems found, so that errors.Is and errors.As look at each from Go 1.20 on
+func (e *MultiError) Unwrap() []error {
+	return e.errs
+}
+
+// Is reports whether any of the problems found matches the target, so that errors.Is looks at each
+// before Go 1.20 too, which does not unwrap to more than one error
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the problems found that matches the target, as errors.As does for each, and sets the target to it
+func (e *MultiError) As(target any) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownName is wrapped by the errors returned for a name that is not that of a command variable, e.g., by GetVarE
+var ErrUnknownName = errors.New("unrecognized variable name")
+
+// maxListedNames is the most names of command variables listed in the complaint about a name that is
+// none of them.  With more, only the closest is suggested
+const maxListedNames = 8
+
+// unknownName returns the error for a name that is not that of a command variable
+func (cp *CmdParser) unknownName(name string) error {
+	return fmt.Errorf("%w %s%s", ErrUnknownName, name, cp.nameHint(name))
+}
+
+// nameHint helps with a name that is not that of a command variable, e.g., a misspelling, suggesting the
+// closest name, if any is close, and listing the names declared when there are few of them
+func (cp *CmdParser) nameHint(name string) string {
+	hint := ""
+	best, best_dist := "", 0
+	for _, declared := range cp.order {
+		if dist := editDistance(name, declared); best == "" || dist < best_dist {
+			best, best_dist = declared, dist
+		}
+	}
+	if best != "" && best_dist <= 2 {
+		hint += fmt.Sprintf(" (did you mean %s?)", best)
+	}
+	if len(cp.order) > 0 && len(cp.order) <= maxListedNames {
+		hint += "; declared: " + strings.Join(cp.order, ", ")
+	}
+	return hint
+}
@@ -0,0 +1,151 @@
+package cmdline
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMultiError checks that a parse with several independent problems reports them all, counted, that
+// a flag given a bad value is not also reported missing, and that errors.Is and errors.As see each problem
+func TestMultiError(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.AddFlag(FloatFlag, "rate", true)
+	cp.AddFlag(StringFlag, "name", true)
+	cp.AddFlag(BoolFlag, "json", false)
+	cp.AddFlag(BoolFlag, "yaml", false)
+	cp.SetMutuallyExclusive("json", "yaml")
+	err := cp.ParseFromArgs([]string{"-count", "x", "-rate", "y", "-json", "-yaml"})
+	multi, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("ParseFromArgs gave %v, want a *MultiError", err)
+	}
+	if !strings.HasPrefix(err.Error(), "4 problems found parsing command line:") || len(multi.Errors()) != 4 {
+		t.Errorf("ParseFromArgs reported %d problems, want 4, for the two bad values, the missing -name, "+
+			"and the group:\n%v", len(multi.Errors()), err)
+	}
+	if strings.Contains(err.Error(), "-count <int>") {
+		t.Errorf("-count, given a bad value, is also reported missing:\n%v", err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("errors.Is does not find strconv.ErrSyntax among the problems:\n%v", err)
+	}
+	var missing *MissingRequiredError
+	if !errors.As(err, &missing) || len(missing.Names) != 1 || missing.Names[0] != "name" {
+		t.Errorf("errors.As does not find the *MissingRequiredError for -name among the problems:\n%v", err)
+	}
+
+	// the Apply methods report flags that are not declared among the rest
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	err = cp.ApplyArgs([]string{"-count", "x", "-bogus", "1"})
+	var unknown *UnknownFlagError
+	if !errors.As(err, &unknown) || len(unknown.Names) != 1 || unknown.Names[0] != "bogus" {
+		t.Errorf("errors.As does not find the *UnknownFlagError for -bogus among the problems:\n%v", err)
+	}
+	var bad *BadValueError
+	if !errors.As(err, &bad) || bad.Flag != "count" {
+		t.Errorf("errors.As does not find the *BadValueError for -count among the problems:\n%v", err)
+	}
+}
+
+// TestMultiErrorUnknownFlag checks that a parse reports a flag that is not declared among the other problems
+// it finds, whether the flags come from arguments, a string, or a file
+func TestMultiErrorUnknownFlag(t *testing.T) {
+	parses := map[string]func(cp *CmdParser) error{
+		"ParseFromArgs": func(cp *CmdParser) error { return cp.ParseFromArgs([]string{"-cuont", "3", "-count", "x"}) },
+		"ParseString":   func(cp *CmdParser) error { return cp.ParseString("-cuont 3 -count x") },
+		"ParseFromReader": func(cp *CmdParser) error {
+			return cp.ParseFromReader(strings.NewReader("-cuont 3\n-count x\n"), "run.cfg")
+		},
+	}
+	for parse, run := range parses {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		err := run(cp)
+		multi, ok := err.(*MultiError)
+		if !ok || len(multi.Errors()) != 2 {
+			t.Errorf("%s gave %v, want a *MultiError of two problems", parse, err)
+			continue
+		}
+		var unknown *UnknownFlagError
+		if !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Names, []string{"cuont"}) {
+			t.Errorf("%s gave %v, without the *UnknownFlagError for -cuont", parse, err)
+		}
+		var bad *BadValueError
+		if !errors.As(err, &bad) || bad.Flag != "count" {
+			t.Errorf("%s gave %v, without the *BadValueError for -count", parse, err)
+		}
+	}
+}
+
+// TestMisspelledNames checks that a misspelled name given to GetVarE or SetVar is an error wrapping
+// ErrUnknownName that suggests the name meant and lists those declared, and that GetVar panics saying the same
+func TestMisspelledNames(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(StringFlag, "name", false)
+	hint := " (did you mean count?); declared: count, name"
+
+	value, err := cp.GetVarE("coutn")
+	if !errors.Is(err, ErrUnknownName) || value != nil {
+		t.Errorf("GetVarE(coutn) gave %v, %v, want nil and an error wrapping ErrUnknownName", value, err)
+	}
+	if err != nil && err.Error() != "unrecognized variable name coutn"+hint {
+		t.Errorf("GetVarE(coutn) error is %q, want it to end %q", err.Error(), hint)
+	}
+
+	err = cp.SetVar("counts", "3")
+	if !errors.Is(err, ErrUnknownName) {
+		t.Errorf("SetVar(counts) gave %v, want an error wrapping ErrUnknownName", err)
+	}
+	if err != nil && !strings.HasSuffix(err.Error(), "counts"+hint) {
+		t.Errorf("SetVar(counts) error is %q, want it to end %q", err.Error(), hint)
+	}
+	if cp.IsLoaded("count") {
+		t.Errorf("SetVar(counts) set -count")
+	}
+
+	func() {
+		defer func() {
+			msg, _ := recover().(string)
+			if !strings.Contains(msg, "unrecognized variable name cuont"+hint) {
+				t.Errorf("GetVar(cuont) panicked with %q, want it to say %q", msg, hint)
+			}
+		}()
+		cp.GetVar("cuont")
+	}()
+}
+
+// TestMissingRequiredMessage checks the exact message for one and for two missing required flags
+func TestMissingRequiredMessage(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(FloatFlag, "rate", true)
+	cp.SetUsage("rate", "arrival rate")
+	cp.AddFlag(IntFlag, "count", true)
+	cp.AddFlag(StringFlag, "name", false)
+	err := cp.ParseFromArgs([]string{"-name", "x"})
+	want := "missing required flags:\n  -count <int>\n  -rate <float>  arrival rate\nrun with -h for full usage"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseFromArgs gave\n%v\nwant\n%s", err, want)
+	}
+	var missing *MissingRequiredError
+	if !errors.As(err, &missing) || strings.Join(missing.Names, ",") != "count,rate" {
+		t.Errorf("ParseFromArgs gave %v, want a *MissingRequiredError naming count and rate", err)
+	}
+
+	err = cp.ParseFromArgs([]string{"-count", "2"})
+	want = "missing required flag: -rate <float>  arrival rate\nrun with -h for full usage"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseFromArgs gave\n%v\nwant\n%s", err, want)
+	}
+}
@@ -0,0 +1,87 @@
+package cmdline
+
+import (
+	"fmt"
+)
+
+// example is a command line showing how to use the program, with what it does
+type example struct {
+	description string
+	command     string
+}
+
+// AddExample adds an example of using the program, which help shows after the flags, in the order added.
+// The command line holds the arguments, which help shows after the program name, e.g., "-workers 10"
+func (cp *CmdParser) AddExample(description string, command string) {
+	cp.examples = append(cp.examples, example{description: description, command: command})
+}
+
+// VerifyExamples checks that the command line of every example given to AddExample parses as ParseString
+// would parse it, with only declared flags, each with a value its variable can hold, and only declared commands,
+// so that examples do not go stale as the flags change.  No variable is set and no file named in an example
+// is read.  Flags that a mode declares, see WhenFlag, are taken as not declared, since the mode is not configured.
+// It is meant to be called from a test.  The error describes the problems of the first example that has any
+func (cp *CmdParser) VerifyExamples() error {
+	for _, ex := range cp.examples {
+		if err := cp.verifyExample(tokenize(ex.command, origin{source: SourceCmdLine})); err != nil {
+			return fmt.Errorf("example %q: %w", ex.command, err)
+		}
+	}
+	return nil
+}
+
+// verifyExample checks the pieces of an example command line by pairing them as a parse does, without setting
+// any variable, then checks the pieces after the name of a command, if any, against the command's CmdParser
+func (cp *CmdParser) verifyExample(pieces []token) error {
+	args := make([]string, len(pieces))
+	for idx, piece := range pieces {
+		args[idx] = piece.text
+	}
+
+	// with commands declared, the pieces from the name of the command on are the command's
+	cmd_at := -1
+	if len(cp.commands) > 0 {
+		cmd_at = cp.commandIndex(args)
+	}
+	rest := []token{}
+	if cmd_at >= 0 {
+		pieces, rest = pieces[:cmd_at], pieces[cmd_at:]
+		args = args[:cmd_at]
+	}
+	if cp.asksFor(args, helpSpellings) || len(cp.version) > 0 && cp.asksFor(args, versionSpellings) {
+		return nil
+	}
+	if len(cp.commands) > 0 && cmd_at < 0 && cp.cmdDefault != "" {
+		return cp.commands[cp.cmdDefault].verifyExample(pieces)
+	}
+
+	// the parse leaves the CmdParser as it was, untraced, so that precedence may still be set
+	started, trace := cp.started, cp.trace
+	cp.trace = nil
+	defer func() {
+		cp.started, cp.trace = started, trace
+	}()
+	pr := cp.newPairer()
+	pr.dry = true
+	for _, piece := range pieces {
+		pr.note(pr.add(piece))
+	}
+	pr.note(pr.flush())
+	if len(pr.unknown) > 0 && !cp.UnknownAsPositional {
+		pr.note(pr.unknownError())
+	}
+	pr.note(pr.arityError())
+	if err := combineErrors(pr.problems); err != nil || len(rest) == 0 {
+		return err
+	}
+
+	name := cp.resolveCommand(rest[0].text)
+	sub, present := cp.commands[name]
+	if !present && name == helpCommand {
+		return nil
+	}
+	if !present {
+		return cp.unknownCommand(name)
+	}
+	return sub.verifyExample(rest[1:])
+}
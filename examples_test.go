@@ -0,0 +1,97 @@
+package cmdline
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// exampleParser declares the flags the examples of the tests use
+func exampleParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "workers", false)
+	cp.AddFlag(StringFlag, "name", false)
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.AddFlag(IntSliceFlag, "ids", false)
+	return cp
+}
+
+// TestExamplesInUsage checks that examples appear in usage after the program name, in the order added
+func TestExamplesInUsage(t *testing.T) {
+	cp := exampleParser()
+	cp.AddExample("run with ten workers", "-workers 10")
+	cp.AddExample("name the run", "-name 'run 1' -verbose")
+	var b strings.Builder
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	first := strings.Index(b.String(), progName()+" -workers 10")
+	second := strings.Index(b.String(), progName()+" -name 'run 1' -verbose")
+	if first < 0 || second < first {
+		t.Errorf("usage does not show both examples in order:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "run with ten workers") {
+		t.Errorf("usage does not show the description of an example:\n%s", b.String())
+	}
+}
+
+// TestVerifyExamples checks that VerifyExamples accepts examples that parse, with declared flags given values
+// they can hold, including lists and commands, and reports one that uses an undeclared flag or command, a value
+// of the wrong type, or a piece that is neither a flag nor a value, without setting any variable
+func TestVerifyExamples(t *testing.T) {
+	withCommand := func() *CmdParser {
+		cp := exampleParser()
+		run := NewCmdParser()
+		run.AddFlag(IntFlag, "n", false)
+		cp.AddCommand("run", run, "run the model", nil)
+		return cp
+	}
+	good := []struct {
+		declare func() *CmdParser
+		command string
+	}{
+		{exampleParser, "-workers 10 -name=x -verbose"},
+		{exampleParser, "--flags-file run.cfg -workers 2"},
+		{exampleParser, "-ids 1 2 3 -verbose"},
+		{exampleParser, "-ids=1,2 -name 'run 1'"},
+		{withCommand, "-verbose run -n 3"},
+		{withCommand, "help run"},
+	}
+	for _, c := range good {
+		cp := c.declare()
+		cp.AddExample("good", c.command)
+		if err := cp.VerifyExamples(); err != nil {
+			t.Errorf("VerifyExamples of %q failed: %v", c.command, err)
+		}
+		if cp.IsLoaded("workers") || cp.IsLoaded("ids") || cp.IsLoaded("verbose") {
+			t.Errorf("VerifyExamples of %q set variables", c.command)
+		}
+		if err := cp.SetPrecedence([]Source{SourceDefault, SourceFile, SourceEnv, SourceCmdLine}); err != nil {
+			t.Errorf("SetPrecedence after VerifyExamples of %q failed: %v", c.command, err)
+		}
+	}
+
+	bad := []struct {
+		declare func() *CmdParser
+		command string
+		want    string
+	}{
+		{exampleParser, "-wrokers 10", "Flags not declared in CmdParser: -wrokers"},
+		{exampleParser, "-workers ten", `flag -workers: cannot parse "ten" as int`},
+		{exampleParser, "-verbose -workers", `flag -workers: cannot parse "true" as int`},
+		{exampleParser, "-workers 1 stray", `"stray": expected a flag beginning with '-'`},
+		{exampleParser, "-ids 1 x", `flag -ids`},
+		{withCommand, "rnu -n 3", `unknown command "rnu"`},
+		{withCommand, "run -n three", `flag -n: cannot parse "three" as int`},
+		{withCommand, "run -workers 3", "Flags not declared in CmdParser: -workers"},
+	}
+	for _, c := range bad {
+		cp := c.declare()
+		cp.AddExample("bad", c.command)
+		err := cp.VerifyExamples()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("VerifyExamples of %q gave %v, want an error saying %q", c.command, err, c.want)
+		}
+	}
+}
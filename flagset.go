@@ -0,0 +1,155 @@
+package cmdline
+
+import (
+	"flag"
+	"fmt"
+)
+
+// flagSetType infers the type of command variable that holds the value of a standard library flag,
+// from the type of value its flag.Value reports.  Values of other types are held as strings
+func flagSetType(f *flag.Flag) FlagArgType {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return StringFlag
+	}
+	switch getter.Get().(type) {
+	case int:
+		return IntFlag
+	case int64:
+		return Int64Flag
+	case float64:
+		return FloatFlag
+	case bool:
+		return BoolFlag
+	default:
+		return StringFlag
+	}
+}
+
+// ImportFlagSet declares a command variable for each flag defined in a standard library flag.FlagSet,
+// with the flag's default and usage string.  Whenever the CmdParser parses, the values it loads for
+// those variables are written back through fs.Set, so code reading the FlagSet's variables sees them.
+// It is an error for a flag in the FlagSet to have the name of a variable already declared or a name CheckName
+// rejects, or to import after Freeze
+func (cp *CmdParser) ImportFlagSet(fs *flag.FlagSet) error {
+	if cp.frozen {
+		return fmt.Errorf("ImportFlagSet cannot import flags after Freeze")
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if cp.IsFlag(f.Name) {
+			err = fmt.Errorf("ImportFlagSet cannot import flag -%s, which is already declared", f.Name)
+			return
+		}
+		if nameErr := cp.CheckName(f.Name); nameErr != nil {
+			err = fmt.Errorf("ImportFlagSet cannot import flag -%s: %w", f.Name, nameErr)
+			return
+		}
+		arg_type := flagSetType(f)
+		if _, convErr := convertValue(arg_type, f.DefValue); convErr != nil {
+			arg_type = StringFlag
+		}
+		cp.AddFlagWithDefault(arg_type, f.Name, false, f.DefValue)
+		cp.info[f.Name].usage = f.Usage
+	})
+	if err != nil {
+		return err
+	}
+	cp.flagSets = append(cp.flagSets, fs)
+	return nil
+}
+
+// writeFlagSets writes the values of loaded variables imported from standard library FlagSets back through them
+func (cp *CmdParser) writeFlagSets() error {
+	for _, fs := range cp.flagSets {
+		var err error
+		fs.VisitAll(func(f *flag.Flag) {
+			if err != nil || !cp.IsLoaded(f.Name) {
+				return
+			}
+			if setErr := fs.Set(f.Name, fmt.Sprint(cp.value(f.Name))); setErr != nil {
+				err = fmt.Errorf("flag -%s: %w", f.Name, setErr)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A ValueAdapter presents a command variable of a CmdParser in the form of a value of the pflag package
+// used by Cobra, with Set, String, and Type methods.  Setting a value through the adapter sets the variable,
+// so the value is seen by GetVar and IsLoaded, and the adapter's String shows values the CmdParser parsed
+type ValueAdapter struct {
+	cp   *CmdParser
+	name string
+}
+
+// String returns the variable's value, or its default, in printed form
+func (va ValueAdapter) String() string {
+	if va.cp == nil {
+		return ""
+	}
+	return va.cp.display(va.name, va.cp.value(va.name))
+}
+
+// Set checks that the string represents a value of the variable's type and, if so, sets the variable
+func (va ValueAdapter) Set(value string) error {
+	if err := checkValue(va.cp.vars[va.name], value); err != nil {
+		return err
+	}
+	return va.cp.setVar(va.name, value, origin{source: SourceCmdLine})
+}
+
+// Type returns the name of the variable's type, derived from FlagTypeString, e.g., "int" for an IntFlag
+func (va ValueAdapter) Type() string {
+	return typeName(va.cp.vars[va.name].ArgType())
+}
+
+// AsValues returns an adapter for each command variable declared in the CmdParser, indexed by name,
+// through which the variable can be registered with a pflag or Cobra flag set
+func (cp *CmdParser) AsValues() map[string]ValueAdapter {
+	values := make(map[string]ValueAdapter, len(cp.vars))
+	for name := range cp.vars {
+		values[name] = ValueAdapter{cp: cp, name: name}
+	}
+	return values
+}
+
+// flagSetValue is the flag.Value through which a FlagSet made by ExportFlagSet reads and
+// writes a command variable of the CmdParser
+type flagSetValue struct {
+	ValueAdapter
+}
+
+// Get returns the variable's value, or its default, in native form
+func (fv *flagSetValue) Get() any {
+	return fv.cp.value(fv.name)
+}
+
+// IsBoolFlag tells the flag package that a Boolean variable needs no value after its flag
+func (fv *flagSetValue) IsBoolFlag() bool {
+	return fv.cp.vars[fv.name].ArgType() == BoolFlag
+}
+
+// ExportFlagSet returns a standard library flag.FlagSet defining a flag for each command variable declared
+// in the CmdParser, with its default and usage string.  The FlagSet's flags read and write the CmdParser's
+// variables, so values parsed by either are seen through both
+func (cp *CmdParser) ExportFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(progName(), flag.ContinueOnError)
+	for _, name := range cp.sortedNames() {
+		fs.Var(&flagSetValue{ValueAdapter{cp: cp, name: name}}, name, cp.info[name].usage)
+
+		// the flag package takes the default from the value at definition, which may already have been parsed
+		def := ""
+		if cp.info[name].hasDef {
+			def = cp.display(name, cp.info[name].def)
+		}
+		fs.Lookup(name).DefValue = def
+	}
+	return fs
+}
@@ -0,0 +1,85 @@
+package cmdline
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestImportFlagSet checks that the type of each imported flag is inferred from its value, falling back to
+// StringFlag, that its default and usage are kept, that values parsed are written back through the FlagSet,
+// and that a flag with the name of a variable already declared is an error
+func TestImportFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("legacy", flag.ContinueOnError)
+	workers := fs.Int("workers", 4, "number of workers")
+	size := fs.Int64("size", 1<<40, "bytes to read")
+	rate := fs.Float64("rate", 0.5, "arrival rate")
+	verbose := fs.Bool("verbose", false, "say more")
+	name := fs.String("name", "run", "name of the run")
+	timeout := fs.Duration("timeout", time.Second, "how long to wait")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	if err := cp.ImportFlagSet(fs); err != nil {
+		t.Fatalf("ImportFlagSet failed: %v", err)
+	}
+	types := map[string]FlagArgType{"workers": IntFlag, "size": Int64Flag, "rate": FloatFlag, "verbose": BoolFlag,
+		"name": StringFlag, "timeout": StringFlag}
+	for flag_name, want := range types {
+		if got, _ := cp.FlagType(flag_name); got != want {
+			t.Errorf("-%s was imported as %s, want %s", flag_name, FlagTypeString(got), FlagTypeString(want))
+		}
+	}
+	if cp.GetVar("workers") != 4 || cp.info["rate"].usage != "arrival rate" {
+		t.Errorf("-workers has default %v and -rate usage %q, want 4 and %q", cp.GetVar("workers"), cp.info["rate"].usage, "arrival rate")
+	}
+
+	if err := cp.ParseFromArgs([]string{"-workers", "8", "-verbose", "-name", "x", "-timeout", "2s", "-size", "5"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if *workers != 8 || !*verbose || *name != "x" || *timeout != 2*time.Second || *size != 5 {
+		t.Errorf("the FlagSet holds -workers %d -verbose %v -name %q -timeout %v -size %d, want 8, true, x, 2s, and 5",
+			*workers, *verbose, *name, *timeout, *size)
+	}
+	if *rate != 0.5 {
+		t.Errorf("the FlagSet holds -rate %v, not given, want its default 0.5", *rate)
+	}
+
+	// a name already declared is an error
+	cp = NewCmdParser()
+	cp.AddFlag(IntFlag, "workers", false)
+	other := flag.NewFlagSet("legacy", flag.ContinueOnError)
+	other.Int("workers", 4, "number of workers")
+	if err := cp.ImportFlagSet(other); err == nil || !strings.Contains(err.Error(), "-workers, which is already declared") {
+		t.Errorf("ImportFlagSet of a declared name gave %v, want an error naming -workers", err)
+	}
+}
+
+// TestExportFlagSet checks that the exported FlagSet has each variable's default and usage, and that values
+// parsed by it are seen by the CmdParser and values parsed by the CmdParser are seen through it
+func TestExportFlagSet(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(IntFlag, "count", false, 2)
+	cp.SetUsage("count", "how many")
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.AddFlag(StringFlag, "name", false)
+	fs := cp.ExportFlagSet()
+	fs.SetOutput(io.Discard)
+	if f := fs.Lookup("count"); f == nil || f.DefValue != "2" || f.Usage != "how many" {
+		t.Fatalf("the exported -count is %+v, want default 2 and usage %q", f, "how many")
+	}
+	if err := fs.Parse([]string{"-count", "3", "-v"}); err != nil {
+		t.Fatalf("the exported FlagSet failed to parse: %v", err)
+	}
+	if cp.GetVar("count") != 3 || cp.GetVar("v") != true {
+		t.Errorf("after the FlagSet parsed, -count is %v and -v %v, want 3 and true", cp.GetVar("count"), cp.GetVar("v"))
+	}
+	if err := cp.ParseFromArgs([]string{"-name", "x"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got := fs.Lookup("name").Value.String(); got != "x" {
+		t.Errorf("the FlagSet shows -name %q, want x", got)
+	}
+}
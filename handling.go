@@ -0,0 +1,72 @@
+package cmdline
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrorHandling says what Parse does when parsing fails, as it does for the standard library flag package
+type ErrorHandling int
+
+const (
+	ContinueOnError ErrorHandling = iota // report the error and return false, leaving the program to go on
+	ExitOnError                          // report the error with how to get usage, and exit with status 2
+	PanicOnError                         // panic with the error
+)
+
+// NewCmdParserWithHandling is a constructor, as NewCmdParser is, for a CmdParser whose Parse does what
+// the ErrorHandling says when parsing fails.  NewCmdParser gives ExitOnError
+func NewCmdParserWithHandling(handling ErrorHandling) *CmdParser {
+	cp := NewCmdParser()
+	cp.handling = handling
+	return cp
+}
+
+// ErrorHandling returns what Parse does when parsing fails
+func (cp *CmdParser) ErrorHandling() ErrorHandling {
+	return cp.handling
+}
+
+// usageHint says how to get full usage, e.g., "run with -h for full usage", or returns "" if
+// every spelling of help is declared as a flag of the program
+func (cp *CmdParser) usageHint() string {
+	for _, spelling := range helpSpellings {
+		if !cp.IsFlag(cp.resolve(strings.Replace(spelling, "-", "", 1))) {
+			return "run with " + spelling + " for full usage"
+		}
+	}
+	return ""
+}
+
+// handleError does what the CmdParser's ErrorHandling says with the error from a parse, reporting whether
+// the parse succeeded and returning the error when the program is to go on.  Help and the version asked for
+// end the program with status 0 under ExitOnError, and are not reported, having been written already
+func (cp *CmdParser) handleError(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	asked := errors.Is(err, ErrHelpRequested) || errors.Is(err, ErrVersionRequested)
+	switch cp.handling {
+	case ContinueOnError:
+		if !asked {
+			cp.reportf("%v\n", err)
+		}
+		return false, err
+	case PanicOnError:
+		panic(err)
+	}
+	if asked {
+		os.Exit(0)
+	}
+
+	// the usage line, and how to get the rest, unless the error says that already
+	cp.reportf("%v\n", err)
+	cp.reportf("Usage: %s [flags]\n", cp.program())
+	var missing *MissingRequiredError
+	if hint := cp.usageHint(); hint != "" && !(errors.As(err, &missing) && missing.Hint != "") {
+		cp.reportf("%s\n", hint)
+	}
+	os.Exit(2)
+	return false, err
+}
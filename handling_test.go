@@ -0,0 +1,88 @@
+package cmdline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestErrorHandling checks that NewCmdParser exits on error, that under ContinueOnError the error is
+// reported and returned, and that under PanicOnError it is panicked with
+func TestErrorHandling(t *testing.T) {
+	if got := NewCmdParser().ErrorHandling(); got != ExitOnError {
+		t.Errorf("NewCmdParser gives ErrorHandling %v, want ExitOnError", got)
+	}
+	failure := errors.New("bad flags")
+
+	var out bytes.Buffer
+	cp := NewCmdParserWithHandling(ContinueOnError)
+	cp.SetOutput(&out)
+	if ok, err := cp.handleError(failure); ok || err != failure {
+		t.Errorf("under ContinueOnError handleError gave %v, %v, want false and the error", ok, err)
+	}
+	if out.String() != "bad flags\n" {
+		t.Errorf("under ContinueOnError the error was reported as %q", out.String())
+	}
+	out.Reset()
+	if ok, err := cp.handleError(ErrHelpRequested); ok || err != ErrHelpRequested || out.Len() != 0 {
+		t.Errorf("under ContinueOnError help gave %v, %v, reporting %q, want false, ErrHelpRequested, and nothing", ok, err, out.String())
+	}
+	if ok, err := cp.handleError(nil); !ok || err != nil {
+		t.Errorf("handleError(nil) gave %v, %v, want true, nil", ok, err)
+	}
+
+	cp = NewCmdParserWithHandling(PanicOnError)
+	cp.SetOutput(&out)
+	func() {
+		defer func() {
+			if r := recover(); r != failure {
+				t.Errorf("under PanicOnError handleError panicked with %v, want the error", r)
+			}
+		}()
+		cp.handleError(failure)
+	}()
+}
+
+// TestParseNoExit checks that Parse succeeds on a command line with no arguments when nothing is required,
+// and that under ContinueOnError it returns false, rather than exiting, when a required flag is missing
+func TestParseNoExit(t *testing.T) {
+	saved := os.Args
+	defer func() { os.Args = saved }()
+
+	os.Args = []string{"prog"}
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", false)
+	if !cp.Parse() {
+		t.Errorf("Parse of an empty command line failed with nothing required")
+	}
+
+	var out bytes.Buffer
+	cp = NewCmdParserWithHandling(ContinueOnError)
+	cp.SetOutput(&out)
+	cp.AddFlag(IntFlag, "count", true)
+	if cp.Parse() {
+		t.Errorf("Parse of an empty command line succeeded with -count required")
+	}
+	if !strings.Contains(out.String(), ErrNoArguments.Error()) {
+		t.Errorf("Parse reported %q, want %q", out.String(), ErrNoArguments.Error())
+	}
+
+	// -is need not come first
+	path := filepath.Join(t.TempDir(), "run.cfg")
+	if err := os.WriteFile(path, []byte("-count 4\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	os.Args = []string{"prog", "-name", "x", "-is", path}
+	cp = NewCmdParserWithHandling(ContinueOnError)
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.AddFlag(StringFlag, "name", false)
+	if !cp.Parse() || cp.GetVar("count") != 4 || cp.GetVar("name") != "x" {
+		t.Errorf("Parse with -is after another flag gave -count %v -name %q", cp.GetVar("count"), cp.GetVar("name"))
+	}
+}
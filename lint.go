@@ -0,0 +1,130 @@
+package cmdline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// LintConfigFile checks the structure of a file of flags in the format read by ParseFromFile without
+// knowing what flags a program declares, returning a problem for each line where it finds one, e.g.,
+// "run.cfg:3: unterminated quote".  It looks for quotes that are not closed, values that follow no flag on
+// their line, malformed flags such as a lone '-', comments written in another syntax, e.g., "// note",
+// '#' joined onto the text before it, which begins a comment where a value may have been meant, and a
+// backslash continuing the last line onto nothing.  A flag's value is expected on the flag's line, so a line
+// that begins with a value is reported even when the line before ends in a flag, unless that line ends in
+// a backslash.  Whether the flags are declared or their values suit them is left to a parse
+func LintConfigFile(filename string) []error {
+	inFile, err := os.Open(filename)
+	if err != nil {
+		return []error{fmt.Errorf("Cannot open command line file %s: %w", filename, err)}
+	}
+	defer inFile.Close()
+
+	problems := []error{}
+	report := func(line_no int, format string, args ...any) {
+		at := origin{source: SourceFile, file: filename, line: line_no}
+		problems = append(problems, fmt.Errorf("%s%s", at.prefix(), fmt.Sprintf(format, args...)))
+	}
+
+	line_no := 0
+	continued := false // the line before ended in a backslash
+	scanner := newLineScanner(inFile, DefaultMaxLineLength)
+	for scanner.Scan() {
+		nxt_line := scanner.Text()
+		line_no += 1
+
+		// nothing waits for a value at the start of a line, save one continuing the line before
+		waiting := continued
+		continued = false
+
+		// comments begin with '#', and anything else that looks like one is taken for flags
+		trimmed := strings.TrimSpace(nxt_line)
+		for _, marker := range []string{"//", ";"} {
+			if strings.HasPrefix(trimmed, marker) {
+				report(line_no, "comments begin with '#', not %q", marker)
+				nxt_line = ""
+				break
+			}
+		}
+		if hash := commentAt(nxt_line); hash >= 0 {
+			if hash > 0 && !unicode.IsSpace(rune(nxt_line[hash-1])) {
+				before := strings.Fields(nxt_line[:hash])
+				report(line_no, "'#' joined onto %q begins a comment, ignoring the rest of the line", before[len(before)-1])
+			}
+			nxt_line = nxt_line[:hash]
+		}
+		if strings.HasSuffix(strings.TrimRight(nxt_line, " \t"), "\\") {
+			nxt_line = strings.TrimSuffix(strings.TrimRight(nxt_line, " \t"), "\\")
+			continued = true
+		}
+
+		if unterminatedQuote(nxt_line) {
+			report(line_no, "unterminated quote")
+		}
+		pieces := tokenize(nxt_line, origin{})
+		for _, piece := range pieces {
+			isFlag := strings.HasPrefix(piece.text, "-") && !piece.quoted && !argIsNumber(piece.text)
+			if malformedFlag(piece) {
+				report(line_no, "malformed flag %q", piece.text)
+			}
+			if !isFlag && !waiting {
+				report(line_no, "value %q follows no flag", piece.text)
+			}
+
+			// without the declarations, any flag may take values, and any value may be one of a list
+			waiting = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		problems = append(problems, scanError(filename, line_no, DefaultMaxLineLength, err))
+	}
+	if continued {
+		report(line_no, "the last line ends in a backslash, continuing onto nothing")
+	}
+	return problems
+}
+
+// unterminatedQuote reports whether a piece of a line that tokenize takes as quoted, one beginning
+// with a quote or a flag's value given with '=' that does, has no closing quote
+func unterminatedQuote(line string) bool {
+	runes := []rune(line)
+	idx := 0
+	for idx < len(runes) {
+		for idx < len(runes) && unicode.IsSpace(runes[idx]) {
+			idx += 1
+		}
+		start := idx
+		for idx < len(runes) && !unicode.IsSpace(runes[idx]) && !(runes[idx] == '=' && runes[start] == '-') {
+			idx += 1
+		}
+		if idx < len(runes) && runes[idx] == '=' {
+			idx += 1
+		} else if idx > start {
+			idx = start
+		}
+
+		// from here a quote runs to its match, and anything else to the next white space
+		if idx < len(runes) && isQuote(runes[idx]) {
+			closing := idx + 1
+			for closing < len(runes) && runes[closing] != runes[idx] {
+				closing += 1
+			}
+			if closing == len(runes) {
+				return true
+			}
+			idx = closing + 1
+		}
+		for idx < len(runes) && !unicode.IsSpace(runes[idx]) {
+			idx += 1
+		}
+	}
+	return false
+}
+
+// malformedFlag reports whether a piece is a flag with no name, e.g., a lone '-', "--", or "-=5"
+func malformedFlag(piece token) bool {
+	isFlag := strings.HasPrefix(piece.text, "-") && !piece.quoted && !argIsNumber(piece.text)
+	return isFlag && (strings.Trim(piece.text, "-") == "" || strings.HasPrefix(strings.TrimLeft(piece.text, "-"), "="))
+}
@@ -0,0 +1,42 @@
+package cmdline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLintConfigFile checks that a file holding a dangling value, comments in the wrong syntax, and an
+// unterminated quote gets a diagnostic for each, naming its line, while a clean file gets none
+func TestLintConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.cfg")
+	if err := os.WriteFile(clean, []byte("# settings\n-count 3 -name 'run 1'\n-verbose \\\n  -rate 0.5\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	if problems := LintConfigFile(clean); len(problems) != 0 {
+		t.Errorf("LintConfigFile of a clean file found %v", problems)
+	}
+
+	bad := filepath.Join(dir, "bad.cfg")
+	text := "-count 3\nstray\n// a note\n-name x#1\n-label 'open\n- 5\n"
+	if err := os.WriteFile(bad, []byte(text), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	want := []string{
+		bad + `:2: value "stray" follows no flag`,
+		bad + `:3: comments begin with '#', not "//"`,
+		bad + `:4: '#' joined onto "x" begins a comment, ignoring the rest of the line`,
+		bad + `:5: unterminated quote`,
+		bad + `:6: malformed flag "-"`,
+	}
+	problems := LintConfigFile(bad)
+	if len(problems) != len(want) {
+		t.Fatalf("LintConfigFile found %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+	for idx, problem := range problems {
+		if problem.Error() != want[idx] {
+			t.Errorf("problem %d is %q, want %q", idx+1, problem.Error(), want[idx])
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cmdline
+
+import (
+	"log"
+)
+
+// Logger receives the advisory messages of a CmdParser, e.g., that a deprecated flag was used or a
+// malformed line of a file skipped, in place of the output writer, see SetLogger.  Errors are not logged but
+// returned, or reported as before by methods that do not return them
+type Logger interface {
+	Warnf(format string, args ...any) // something the user should probably fix
+	Infof(format string, args ...any) // a note on what the CmdParser did, e.g., read a value from the environment
+}
+
+// SetLogger has the CmdParser pass its warnings and notes to the logger rather than writing them to the
+// output writer.  NopLogger silences them, and LogLogger adapts a *log.Logger
+func (cp *CmdParser) SetLogger(l Logger) {
+	cp.logger = l
+}
+
+// NopLogger is a Logger that discards everything
+type NopLogger struct{}
+
+// Warnf discards a warning
+func (NopLogger) Warnf(format string, args ...any) {}
+
+// Infof discards a note
+func (NopLogger) Infof(format string, args ...any) {}
+
+// stdLogger adapts a *log.Logger to a Logger
+type stdLogger struct {
+	l *log.Logger
+}
+
+// LogLogger returns a Logger writing to a *log.Logger, e.g., log.Default(), prefixing each message with its level
+func LogLogger(l *log.Logger) Logger {
+	return stdLogger{l: l}
+}
+
+// Warnf logs a warning
+func (sl stdLogger) Warnf(format string, args ...any) {
+	sl.l.Printf("WARN "+format, args...)
+}
+
+// Infof logs a note
+func (sl stdLogger) Infof(format string, args ...any) {
+	sl.l.Printf("INFO "+format, args...)
+}
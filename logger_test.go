@@ -0,0 +1,85 @@
+package cmdline
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+// recordingLogger is a Logger that keeps the messages it is given
+type recordingLogger struct {
+	warnings []string
+	notes    []string
+}
+
+// Warnf keeps a warning
+func (rl *recordingLogger) Warnf(format string, args ...any) {
+	rl.warnings = append(rl.warnings, fmt.Sprintf(format, args...))
+}
+
+// Infof keeps a note
+func (rl *recordingLogger) Infof(format string, args ...any) {
+	rl.notes = append(rl.notes, fmt.Sprintf(format, args...))
+}
+
+// TestSetLogger checks that with a logger set, warnings go to it rather than the output writer, and notes
+// on what the CmdParser did go to it as well, that NopLogger silences them, and that LogLogger prefixes
+// each with its level
+func TestSetLogger(t *testing.T) {
+	t.Setenv("ZZL_COUNT", "3")
+	declare := func(out *bytes.Buffer) *CmdParser {
+		cp := NewCmdParser()
+		cp.SetOutput(out)
+		cp.EnvPrefix = "ZZL"
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(BoolFlag, "old", false)
+		cp.SetDeprecated("old", "use -count")
+		return cp
+	}
+
+	var out bytes.Buffer
+	cp := declare(&out)
+	if err := cp.ParseFromArgs([]string{"-old"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Flag -old is deprecated: use -count") {
+		t.Errorf("without a logger the output is %q, want the deprecation warning", out.String())
+	}
+
+	out.Reset()
+	rl := &recordingLogger{}
+	cp = declare(&out)
+	cp.SetLogger(rl)
+	if err := cp.ParseFromArgs([]string{"-old"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("with a logger the output is %q, want nothing", out.String())
+	}
+	if len(rl.warnings) != 1 || rl.warnings[0] != "Flag -old is deprecated: use -count" {
+		t.Errorf("the logger got warnings %q, want the deprecation warning without a new line", rl.warnings)
+	}
+	if len(rl.notes) != 1 || rl.notes[0] != "flag -count set from $ZZL_COUNT" {
+		t.Errorf("the logger got notes %q, want one saying -count was set from $ZZL_COUNT", rl.notes)
+	}
+
+	out.Reset()
+	cp = declare(&out)
+	cp.SetLogger(NopLogger{})
+	if err := cp.ParseFromArgs([]string{"-old"}); err != nil || out.Len() != 0 {
+		t.Errorf("with NopLogger the parse gave %v, writing %q, want nil and nothing", err, out.String())
+	}
+
+	var logged bytes.Buffer
+	cp = declare(&out)
+	cp.SetLogger(LogLogger(log.New(&logged, "", 0)))
+	if err := cp.ParseFromArgs([]string{"-old"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	want := "WARN Flag -old is deprecated: use -count\nINFO flag -count set from $ZZL_COUNT\n"
+	if logged.String() != want {
+		t.Errorf("LogLogger logged %q, want %q", logged.String(), want)
+	}
+}
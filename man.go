@@ -0,0 +1,146 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ManMeta gives what a man page needs beyond the declarations of the command variables.
+// Fields left empty get a reasonable default, noted with each
+type ManMeta struct {
+	Name        string // program name, by default the name by which the running program was invoked
+	Section     string // manual section, by default "1"
+	Date        string // date shown in the page footer
+	Source      string // package and version, e.g., "mytool 1.4", shown in the footer
+	Manual      string // title of the manual, e.g., "User Commands", shown in the header
+	Summary     string // one line description for the NAME section, by default the text given to SetDescription
+	Description string // text of the DESCRIPTION section, by default the text given to SetDescription
+}
+
+// roffEscape escapes text for roff, so that backslashes and dashes print as themselves and
+// a line cannot be mistaken for a request
+func roffEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[idx] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffQuote quotes an argument of a roff request
+func roffQuote(text string) string {
+	return `"` + strings.ReplaceAll(roffEscape(text), `"`, `\(dq`) + `"`
+}
+
+// manFlag renders a flag and the kind of value it takes in roff, e.g., \fB\-count\fR \fIint\fR
+func manFlag(flag UsageFlag) string {
+	text := `\fB` + roffEscape("-"+flag.Name) + `\fR`
+	if flag.Type != typeName(BoolFlag) {
+		text += ` \fI` + roffEscape(flag.Type) + `\fR`
+	}
+	return text
+}
+
+// GenerateManPage writes a man page for the program in roff, with NAME, SYNOPSIS, DESCRIPTION, and
+// OPTIONS sections, and an EXAMPLES section when there are examples, see AddExample.  The synopsis lists the required flags as they are and the optional ones in brackets,
+// and the options give each flag's aliases, type, usage string, whether it is required, its bounds, and its default,
+// in subsections by group when groups are set, see SetGroup.  Hidden flags are left out, and deprecated
+// flags are left out of the synopsis and marked as deprecated among the options
+func (cp *CmdParser) GenerateManPage(w io.Writer, meta ManMeta) error {
+	if meta.Name == "" {
+		meta.Name = progName()
+	}
+	if meta.Section == "" {
+		meta.Section = "1"
+	}
+	if meta.Summary == "" {
+		meta.Summary = cp.description
+	}
+	if meta.Description == "" {
+		meta.Description = cp.description
+	}
+	data := cp.usageData(false, 0)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s %s %s %s %s\n", roffQuote(strings.ToUpper(meta.Name)), roffQuote(meta.Section),
+		roffQuote(meta.Date), roffQuote(meta.Source), roffQuote(meta.Manual))
+
+	fmt.Fprintf(&b, ".SH NAME\n")
+	if meta.Summary != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(meta.Name), roffEscape(meta.Summary))
+	} else {
+		fmt.Fprintf(&b, "%s\n", roffEscape(meta.Name))
+	}
+
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(meta.Name))
+	for _, flag := range data.Flags {
+		if flag.Deprecated != "" {
+			continue
+		}
+		if flag.Required {
+			fmt.Fprintf(&b, "%s\n", manFlag(flag))
+		} else {
+			fmt.Fprintf(&b, "[%s]\n", manFlag(flag))
+		}
+	}
+
+	if meta.Description != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(meta.Description))
+	}
+
+	if len(data.Flags) > 0 {
+		fmt.Fprintf(&b, ".SH OPTIONS\n")
+	}
+	for _, section := range data.Sections {
+		if len(data.Sections) > 1 {
+			fmt.Fprintf(&b, ".SS %s\n", roffQuote(strings.TrimSuffix(section.Title, ":")))
+		}
+		if section.Description != "" {
+			fmt.Fprintf(&b, "%s\n", roffEscape(section.Description))
+		}
+		for _, flag := range section.Flags {
+			names := []string{manFlag(flag)}
+			for _, alias := range flag.Aliases {
+				names = append(names, manFlag(UsageFlag{Name: alias, Type: flag.Type}))
+			}
+			fmt.Fprintf(&b, ".TP\n%s\n", strings.Join(names, ", "))
+
+			text := []string{}
+			if flag.Usage != "" {
+				text = append(text, roffEscape(flag.Usage))
+			}
+			if flag.Required {
+				text = append(text, "Required.")
+			}
+			if flag.Bounds != "" {
+				text = append(text, "Length: "+roffEscape(flag.Bounds)+".")
+			}
+			if flag.HasDefault {
+				text = append(text, "Default: "+roffEscape(flag.Default)+".")
+			}
+			if flag.Deprecated != "" {
+				text = append(text, `\fBDeprecated:\fR `+roffEscape(flag.Deprecated))
+			}
+			if len(text) > 0 {
+				fmt.Fprintf(&b, "%s\n", strings.Join(text, "\n.br\n"))
+			}
+		}
+	}
+
+	if len(data.Examples) > 0 {
+		fmt.Fprintf(&b, ".SH EXAMPLES\n")
+	}
+	for _, ex := range data.Examples {
+		fmt.Fprintf(&b, ".PP\n%s\n.PP\n.RS\n.nf\n%s %s\n.fi\n.RE\n", roffEscape(ex.Description),
+			roffEscape(meta.Name), roffEscape(ex.Command))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,79 @@
+package cmdline
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestGenerateManPage checks the man page written for a few flags and an example against the roff expected,
+// with dashes escaped, a line starting with '.' guarded, the hidden flag left out, and the deprecated flag
+// left out of the synopsis
+func TestGenerateManPage(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetDescription("run the model")
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs")
+	cp.AddAlias("count", "c")
+	cp.AddFlagWithDefault(StringFlag, "out-file", false, "a-b.txt")
+	cp.SetUsage("out-file", ".where results go")
+	cp.AddFlag(BoolFlag, "dry-run", false)
+	cp.SetDeprecated("dry-run", "use -n")
+	cp.AddFlag(BoolFlag, "secret", false)
+	cp.SetHidden("secret")
+	cp.AddExample("run twice", "-count 2")
+
+	want := `.TH "TOOL" "1" "2024\-01\-02" "tool 1.0" "User Commands"
+.SH NAME
+tool \- run the model
+.SH SYNOPSIS
+.B tool
+\fB\-count\fR \fIint\fR
+[\fB\-out\-file\fR \fIstring\fR]
+.SH DESCRIPTION
+run the model
+.SH OPTIONS
+.TP
+\fB\-count\fR \fIint\fR, \fB\-c\fR \fIint\fR
+number of runs
+.br
+Required.
+.TP
+\fB\-out\-file\fR \fIstring\fR
+\&.where results go
+.br
+Default: "a\-b.txt".
+.TP
+\fB\-dry\-run\fR
+\fBDeprecated:\fR use \-n
+.SH EXAMPLES
+.PP
+run twice
+.PP
+.RS
+.nf
+tool \-count 2
+.fi
+.RE
+`
+	var b strings.Builder
+	if err := cp.GenerateManPage(&b, ManMeta{Name: "tool", Date: "2024-01-02", Source: "tool 1.0", Manual: "User Commands"}); err != nil {
+		t.Fatalf("GenerateManPage failed: %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("GenerateManPage wrote\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// with groups, the options are in a subsection for each
+	cp.SetGroup("count", "Model")
+	b.Reset()
+	if err := cp.GenerateManPage(&b, ManMeta{Name: "tool"}); err != nil {
+		t.Fatalf("GenerateManPage failed: %v", err)
+	}
+	for _, sub := range []string{".SS \"Model options\"\n", ".SS \"Other options\"\n"} {
+		if !strings.Contains(b.String(), sub) {
+			t.Errorf("the man page with groups does not hold %q:\n%s", sub, b.String())
+		}
+	}
+}
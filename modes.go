@@ -0,0 +1,56 @@
+package cmdline
+
+import (
+	"fmt"
+)
+
+// mode is a value of a flag that selects further flags, declared when the flag has the value
+type mode struct {
+	flag      string
+	value     string
+	configure func(*CmdParser)
+	done      bool // configure has been called
+}
+
+// WhenFlag arranges that when a parse leaves the command variable 'flag' with the value, e.g., -mode server,
+// the configure function is called to declare the flags of that mode, e.g., a required -port, which are
+// then taken from what was parsed and checked along with the rest.  A mode is configured at most once,
+// and its configure function may itself call WhenFlag
+func (cp *CmdParser) WhenFlag(flag string, value string, configure func(*CmdParser)) {
+	cp.checkFrozen("WhenFlag", flag)
+	if !cp.IsFlag(flag) {
+		panic(fmt.Sprintf("CmdParser.WhenFlag given unrecognized variable name %s\n", flag))
+	}
+	cp.modes = append(cp.modes, &mode{flag: flag, value: value, configure: configure})
+}
+
+// selectModes configures the modes that the values parsed select, then pairs the flags that were not
+// declared before but are now.  Since a mode may declare flags that select further modes, this repeats
+// until no more are selected
+func (pr *pairer) selectModes() {
+	cp := pr.cp
+	for {
+		selected := false
+		for idx := 0; idx < len(cp.modes); idx++ {
+			m := cp.modes[idx]
+			if m.done || !cp.IsLoaded(m.flag) || formatValue(cp.vars[m.flag].ArgType(), cp.value(m.flag)) != m.value {
+				continue
+			}
+			m.done = true
+			frozen := cp.frozen
+			cp.frozen = false
+			m.configure(cp)
+			cp.frozen = frozen
+			selected = true
+		}
+		if !selected {
+			return
+		}
+
+		unknown := pr.unknown
+		pr.unknown = nil
+		for _, fv := range unknown {
+			pr.pair(token{text: "-" + fv.flag, at: fv.at}, token{text: fv.value, literal: fv.literal}, fv.raw)
+		}
+	}
+}
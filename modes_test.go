@@ -0,0 +1,49 @@
+package cmdline
+
+import (
+	"io"
+	"testing"
+)
+
+// serverParser declares a -mode flag whose value "server" adds a required -port
+func serverParser() *CmdParser {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(StringFlag, "mode", false, "client")
+	cp.WhenFlag("mode", "server", func(cp *CmdParser) {
+		cp.AddFlag(IntFlag, "port", true)
+	})
+	return cp
+}
+
+// TestWhenFlag checks that -mode server declares a required -port, taken from the flags already parsed
+// and validated, while other modes leave -port undeclared
+func TestWhenFlag(t *testing.T) {
+	cp := serverParser()
+	if err := cp.ParseFromArgs([]string{"-port", "8080", "-mode", "server"}); err != nil {
+		t.Fatalf("ParseFromArgs of server mode failed: %v", err)
+	}
+	if got := cp.GetVar("port"); got != 8080 {
+		t.Errorf("-port is %v in server mode, want 8080", got)
+	}
+
+	cp = serverParser()
+	err := cp.ParseFromArgs([]string{"-mode", "server"})
+	missing, ok := err.(*MissingRequiredError)
+	if !ok || len(missing.Names) != 1 || missing.Names[0] != "port" {
+		t.Errorf("server mode without -port gave %v, want -port missing", err)
+	}
+
+	cp = serverParser()
+	if err := cp.ParseFromArgs([]string{"-mode", "server", "-port", "eighty"}); err == nil {
+		t.Errorf("server mode with -port eighty returned no error")
+	}
+
+	cp = serverParser()
+	if err := cp.ParseFromArgs([]string{}); err != nil {
+		t.Fatalf("ParseFromArgs of client mode failed: %v", err)
+	}
+	if cp.IsFlag("port") {
+		t.Errorf("-port is declared in client mode")
+	}
+}
@@ -0,0 +1,55 @@
+package cmdline
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// SetPromptInput gives the reader from which PromptOnMissing reads values, in place of os.Stdin,
+// e.g., for a test.  A reader given here is taken to be interactive
+func (cp *CmdParser) SetPromptInput(r io.Reader) {
+	cp.promptInput = r
+}
+
+// promptReader returns the reader from which to read values for missing flags, or nil if
+// there is nobody to ask because standard input is not a terminal
+func (cp *CmdParser) promptReader() io.Reader {
+	if cp.promptInput != nil {
+		return cp.promptInput
+	}
+	if isInteractive(os.Stdin) {
+		return os.Stdin
+	}
+	return nil
+}
+
+// promptMissing asks for the value of each variable that the parse would report missing, see isMissing, in the
+// order declared, writing a prompt to the output writer and reading a line in reply.  A value the variable cannot
+// hold is asked for again.  The variables are left missing when there is nobody to ask or the input ends
+func (cp *CmdParser) promptMissing() {
+	r := cp.promptReader()
+	if r == nil {
+		return
+	}
+	reader := bufio.NewReader(r)
+	for _, name := range cp.order {
+		for cp.isMissing(name) {
+			prompt := cp.flagSyntax(name)
+			if usage := cp.info[name].usage; usage != "" {
+				prompt += " (" + usage + ")"
+			}
+			io.WriteString(cp.out(), prompt+": ")
+
+			line, err := reader.ReadString('\n')
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" && err != nil {
+				return
+			}
+			if err := cp.setVar(name, line, origin{source: SourceCmdLine}); err != nil {
+				cp.warnf("%v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package cmdline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestPromptOnMissing checks that values for missing required flags are read from the reader given to
+// SetPromptInput, that one the flag cannot hold is asked for again, and that flags given are not asked for
+func TestPromptOnMissing(t *testing.T) {
+	var out bytes.Buffer
+	cp := NewCmdParser()
+	cp.SetOutput(&out)
+	cp.PromptOnMissing = true
+	cp.SetPromptInput(strings.NewReader("many\n8\nrun 1\n"))
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs")
+	cp.AddFlag(StringFlag, "name", true)
+	cp.AddFlag(FloatFlag, "rate", true)
+	if err := cp.ParseFromArgs([]string{"-rate", "0.5"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if cp.GetVar("count") != 8 || cp.GetVar("name") != "run 1" || cp.GetVar("rate") != 0.5 {
+		t.Errorf("prompting gave -count %v -name %q -rate %v", cp.GetVar("count"), cp.GetVar("name"), cp.GetVar("rate"))
+	}
+	if got := strings.Count(out.String(), "-count <int> (number of runs): "); got != 2 {
+		t.Errorf("-count was asked for %d times, want 2 as the first reply is not an int:\n%s", got, out.String())
+	}
+	if strings.Contains(out.String(), "-rate") {
+		t.Errorf("-rate, which was given, was asked for:\n%s", out.String())
+	}
+
+	// when the input ends the flag is left missing
+	cp = NewCmdParser()
+	cp.SetOutput(&out)
+	cp.PromptOnMissing = true
+	cp.SetPromptInput(strings.NewReader(""))
+	cp.AddFlag(IntFlag, "count", true)
+	err := cp.ParseFromArgs([]string{})
+	if _, ok := err.(*MissingRequiredError); !ok {
+		t.Errorf("ParseFromArgs with no input to prompt from gave %v, want a *MissingRequiredError", err)
+	}
+}
@@ -0,0 +1,267 @@
+package cmdline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// savedFlag is the JSON encoding of one command variable's declaration and value.
+// Default is a pointer so that a flag with no default can be told from one whose default is empty
+type savedFlag struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Types      []string `json:"types,omitempty"`
+	Length     []int    `json:"length,omitempty"`
+	URLSafe    bool     `json:"url_safe,omitempty"`
+	LogLevel   bool     `json:"log_level,omitempty"`
+	Required   bool     `json:"required"`
+	Secret     bool     `json:"secret,omitempty"`
+	Default    *string  `json:"default,omitempty"`
+	Usage      string   `json:"usage,omitempty"`
+	Hidden     bool     `json:"hidden,omitempty"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Deprecated string   `json:"deprecated,omitempty"`
+	Loaded     bool     `json:"loaded"`
+	Value      string   `json:"value,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	File       string   `json:"file,omitempty"`
+	Line       int      `json:"line,omitempty"`
+}
+
+// savedState is the JSON encoding of a CmdParser, written by SaveState and read by LoadState
+type savedState struct {
+	AllowReservedNames bool        `json:"allow_reserved_names,omitempty"`
+	Flags              []savedFlag `json:"flags"`
+}
+
+// flagTypeFromString is the inverse of FlagTypeString, reporting false for names of no known type
+func flagTypeFromString(type_name string) (FlagArgType, bool) {
+	for arg_type := IntFlag; arg_type < None; arg_type++ {
+		if FlagTypeString(arg_type) == type_name {
+			return arg_type, true
+		}
+	}
+	return None, false
+}
+
+// sourceFromString is the inverse of Source.String, reporting false for names of no known source
+func sourceFromString(src_name string) (Source, bool) {
+	for _, src := range defaultPrecedence {
+		if src.String() == src_name {
+			return src, true
+		}
+	}
+	return SourceDefault, false
+}
+
+// stateValue returns the string that, given to Set, restores a command variable's value.
+// It reports false for types whose values it does not know how to represent
+func stateValue(v arg) (string, bool) {
+	switch v.ArgType() {
+	case IntFlag, Int64Flag, FloatFlag, StringFlag, BoolFlag, UnionFlag, JSONFlag, IntRangeListFlag, BytesBase64Flag,
+		IntSliceFlag, FloatSliceFlag, FlexDurationFlag:
+		return formatValue(v.ArgType(), v.Get()), true
+	default:
+		return "", false
+	}
+}
+
+// unsaved returns an error naming the first declaration of the CmdParser that SaveState has no saved form for,
+// so that a restored CmdParser would silently lack it, or nil if there is none
+func (cp *CmdParser) unsaved() error {
+	for _, name := range cp.order {
+		v := cp.vars[name]
+		info := cp.info[name]
+		if v.ArgType() == TypedMapFlag {
+			return fmt.Errorf("SaveState cannot save flag -%s, whose type %s has no saved form", name, FlagTypeString(v.ArgType()))
+		}
+		if info.defFunc != nil {
+			return fmt.Errorf("SaveState cannot save flag -%s, whose conditional default has no saved form", name)
+		}
+		if info.env != "" {
+			return fmt.Errorf("SaveState cannot save flag -%s, which is bound to environment variable $%s", name, info.env)
+		}
+		if info.companion != "" {
+			return fmt.Errorf("SaveState cannot save flag -%s, which has companion flag -%s", name, info.companion)
+		}
+	}
+	if cp.EnvPrefix != "" {
+		return fmt.Errorf("SaveState cannot save EnvPrefix %s", cp.EnvPrefix)
+	}
+	if len(cp.implied) > 0 {
+		return fmt.Errorf("SaveState cannot save implied flags")
+	}
+	if len(cp.constraints) > 0 {
+		return fmt.Errorf("SaveState cannot save constraints on groups of flags")
+	}
+	if len(cp.modes) > 0 || len(cp.wildcards) > 0 {
+		return fmt.Errorf("SaveState cannot save modes or wildcard flags, whose functions have no saved form")
+	}
+	return nil
+}
+
+// SaveState writes the complete state of the CmdParser, the declarations of all its command
+// variables along with the values they hold and where those came from, as JSON.  LoadState
+// recreates the CmdParser from that, e.g., to restart a checkpointed run in a fresh process.
+// Secret values are written as they are, since they could not otherwise be restored.  A declaration
+// that has no saved form is an error rather than lost, e.g., a TypedMapFlag or a conditional default, whose
+// functions cannot be saved, or an environment variable, companion flag, implied flag, or constraint
+func (cp *CmdParser) SaveState(w io.Writer) error {
+	if err := cp.unsaved(); err != nil {
+		return err
+	}
+	names := cp.order
+	state := savedState{AllowReservedNames: cp.AllowReservedNames, Flags: make([]savedFlag, 0, len(names))}
+	for _, name := range names {
+		v := cp.vars[name]
+		sf := savedFlag{Name: name, Type: FlagTypeString(v.ArgType()), Required: v.Required(),
+			Secret: cp.info[name].secret, Usage: cp.info[name].usage, Hidden: cp.info[name].hidden,
+			Aliases: cp.info[name].aliases, Group: cp.info[name].group,
+			Deprecated: cp.info[name].deprecated, Loaded: v.Loaded()}
+		if cp.info[name].hasDef {
+			def := formatValue(v.ArgType(), cp.info[name].def)
+			sf.Default = &def
+		}
+		if lv, ok := v.(*lengthStringVar); ok {
+			sf.Length = []int{lv.v_min, lv.v_max}
+		}
+		if bv, ok := v.(*bytesVar); ok {
+			sf.URLSafe = bv.v_url
+		}
+		if _, ok := v.(*logLevelVar); ok {
+			sf.LogLevel = true
+		}
+		if uv, ok := v.(*unionVar); ok {
+			for _, arg_type := range uv.v_types {
+				sf.Types = append(sf.Types, FlagTypeString(arg_type))
+			}
+		}
+		if v.Loaded() {
+			value, ok := stateValue(v)
+			if !ok {
+				return fmt.Errorf("SaveState cannot save flag -%s, whose type %s has no saved form", name, sf.Type)
+			}
+			at := cp.info[name].origin
+			sf.Value = value
+			sf.Source = at.source.String()
+			sf.File = at.file
+			sf.Line = at.line
+		}
+		state.Flags = append(state.Flags, sf)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// LoadState creates a CmdParser from the JSON written by SaveState, declaring each command
+// variable that was saved and restoring its value.  Saved state that the declarations would panic on,
+// e.g., a name that CheckName rejects, a name saved twice, an alias that is another flag's name, or bounds on
+// a length that do not make sense, is an error rather than a panic, as is a value the variable cannot hold.
+// The values of secret variables are redacted from the errors
+func LoadState(r io.Reader) (*CmdParser, error) {
+	var state savedState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("LoadState cannot decode saved state: %w", err)
+	}
+
+	// no name may be declared twice, whether as a flag or an alias
+	cp := NewCmdParser()
+	cp.AllowReservedNames = state.AllowReservedNames
+	names := make(map[string]bool)
+	for _, sf := range state.Flags {
+		if names[sf.Name] {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, which is saved more than once", sf.Name)
+		}
+		names[sf.Name] = true
+	}
+	for _, sf := range state.Flags {
+		if err := cp.CheckName(sf.Name); err != nil {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s: %w", sf.Name, err)
+		}
+		for _, alias := range sf.Aliases {
+			if err := cp.CheckName(alias); err != nil {
+				return nil, fmt.Errorf("LoadState cannot restore alias -%s of flag -%s: %w", alias, sf.Name, err)
+			}
+			if names[alias] {
+				return nil, fmt.Errorf("LoadState cannot restore alias -%s of flag -%s, which is the name of a flag", alias, sf.Name)
+			}
+			names[alias] = true
+		}
+		if len(sf.Length) > 0 && (len(sf.Length) != 2 || sf.Length[0] < 0 || sf.Length[1] < sf.Length[0]) {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, whose saved bounds on its length %v are not a minimum and maximum", sf.Name, sf.Length)
+		}
+		arg_type, ok := flagTypeFromString(sf.Type)
+		if !ok {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, whose type %s is unknown", sf.Name, sf.Type)
+		}
+		switch arg_type {
+		case UnionFlag:
+			types := []FlagArgType{}
+			for _, type_name := range sf.Types {
+				member, ok := flagTypeFromString(type_name)
+				if !ok || member < IntFlag || member > BoolFlag {
+					return nil, fmt.Errorf("LoadState cannot restore union flag -%s, whose member type %s is unknown", sf.Name, type_name)
+				}
+				types = append(types, member)
+			}
+			if len(types) == 0 {
+				return nil, fmt.Errorf("LoadState cannot restore union flag -%s, which lists no member types", sf.Name)
+			}
+			cp.AddUnionFlag(sf.Name, sf.Required, types...)
+		case StringFlag:
+			if len(sf.Length) == 2 {
+				cp.AddStringFlagWithLength(sf.Name, sf.Required, sf.Length[0], sf.Length[1])
+			} else if sf.LogLevel {
+				cp.AddLogLevelFlag(sf.Name, sf.Required)
+			} else {
+				cp.AddFlag(arg_type, sf.Name, sf.Required)
+			}
+		default:
+			cp.AddFlag(arg_type, sf.Name, sf.Required)
+		}
+		if !cp.IsFlag(sf.Name) {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, whose type %s has no saved form", sf.Name, sf.Type)
+		}
+		if sf.URLSafe && arg_type != BytesBase64Flag {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, which is saved as URL-safe but is not a BytesBase64Flag", sf.Name)
+		}
+		if sf.URLSafe {
+			cp.SetURLSafe(sf.Name)
+		}
+		cp.info[sf.Name].secret = sf.Secret
+		if sf.Default != nil {
+			def, err := convertValue(arg_type, *sf.Default)
+			if err != nil {
+				return nil, fmt.Errorf("LoadState cannot restore the default of flag -%s: %w", sf.Name,
+					cp.valueError(sf.Name, *sf.Default, err, origin{source: SourceDefault}))
+			}
+			cp.info[sf.Name].def = def
+			cp.info[sf.Name].hasDef = true
+		}
+		cp.info[sf.Name].usage = sf.Usage
+		cp.info[sf.Name].hidden = sf.Hidden
+		for _, alias := range sf.Aliases {
+			cp.AddAlias(sf.Name, alias)
+		}
+		if sf.Group != "" {
+			cp.SetGroup(sf.Name, sf.Group)
+		}
+		cp.info[sf.Name].deprecated = sf.Deprecated
+		if !sf.Loaded {
+			continue
+		}
+
+		source, ok := sourceFromString(sf.Source)
+		if !ok {
+			return nil, fmt.Errorf("LoadState cannot restore flag -%s, whose source %q is unknown", sf.Name, sf.Source)
+		}
+		if err := cp.setVar(sf.Name, sf.Value, origin{source: source, file: sf.File, line: sf.Line}); err != nil {
+			return nil, fmt.Errorf("LoadState cannot restore the value of flag -%s: %w", sf.Name, err)
+		}
+	}
+	return cp, nil
+}
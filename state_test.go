@@ -0,0 +1,123 @@
+package cmdline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSaveStateRoundTrip checks that LoadState restores from what SaveState writes the declarations, values,
+// and sources of the values, so that the restored CmdParser saves the same state again
+func TestSaveStateRoundTrip(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlagWithDefault(IntFlag, "count", false, 4)
+	cp.AddFlag(StringFlag, "token", true)
+	cp.SetSecret("token")
+	cp.AddUnionFlag("limit", false, IntFlag, StringFlag)
+	cp.AddStringFlagWithLength("user", false, 3, 8)
+	cp.AddLogLevelFlag("level", false)
+	cp.AddFlag(BytesBase64Flag, "key", false)
+	cp.SetURLSafe("key")
+	cp.AddFlag(IntSliceFlag, "ids", false)
+	cp.AddFlag(FloatFlag, "rate", false)
+	cp.AddAlias("rate", "r")
+	cp.SetUsage("rate", "arrival rate")
+	cp.SetGroup("rate", "Model")
+	cp.SetHidden("user")
+	cp.SetDeprecated("limit", "use -count")
+	if err := cp.ParseFromReader(strings.NewReader("-token hunter2\n-ids 1,2\n"), "run.cfg"); err != nil {
+		t.Fatalf("ParseFromReader failed: %v", err)
+	}
+	if err := cp.ParseFromArgs([]string{"-limit", "none", "-user", "alice", "-level", "warn", "-key", "aGk_", "-r", "0.5"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+
+	var saved bytes.Buffer
+	if err := cp.SaveState(&saved); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	restored, err := LoadState(bytes.NewReader(saved.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadState failed: %v\n%s", err, saved.String())
+	}
+	for _, name := range []string{"count", "token", "limit", "user", "level", "key", "ids", "rate"} {
+		if !reflect.DeepEqual(restored.GetVar(name), cp.GetVar(name)) || restored.IsLoaded(name) != cp.IsLoaded(name) {
+			t.Errorf("-%s restored as %v, loaded %v, want %v, loaded %v", name, restored.GetVar(name), restored.IsLoaded(name),
+				cp.GetVar(name), cp.IsLoaded(name))
+		}
+	}
+	if !restored.IsSecret("token") || restored.MatchedType("limit") != StringFlag {
+		t.Errorf("restored -token secret %v and -limit matched %s, want true and StringFlag",
+			restored.IsSecret("token"), FlagTypeString(restored.MatchedType("limit")))
+	}
+	var again bytes.Buffer
+	if err := restored.SaveState(&again); err != nil {
+		t.Fatalf("SaveState of the restored CmdParser failed: %v", err)
+	}
+	if again.String() != saved.String() {
+		t.Errorf("the restored CmdParser saved\n%s\nwant\n%s", again.String(), saved.String())
+	}
+}
+
+// TestLoadStateErrors checks that saved state the declarations would panic on, or holding a value its
+// variable cannot, is an error, and that the value of a secret variable is redacted from it
+func TestLoadStateErrors(t *testing.T) {
+	cases := []struct {
+		saved string
+		want  string
+	}{
+		{`{"flags":[{"name":"user","type":"StringFlag","length":[5,1],"loaded":false}]}`, "bounds on its length"},
+		{`{"flags":[{"name":"user","type":"StringFlag","length":[5],"loaded":false}]}`, "bounds on its length"},
+		{`{"flags":[{"name":"n","type":"IntFlag","loaded":false},{"name":"n","type":"StringFlag","loaded":false}]}`, "more than once"},
+		{`{"flags":[{"name":"n","type":"IntFlag","aliases":["m"],"loaded":false},{"name":"m","type":"IntFlag","loaded":false}]}`, "name of a flag"},
+		{`{"flags":[{"name":"n","type":"IntFlag","url_safe":true,"loaded":false}]}`, "not a BytesBase64Flag"},
+		{`{"flags":[{"name":"a","type":"IntFlag","secret":true,"loaded":true,"value":"hunter2","source":"command line"}]}`, "flag -a"},
+		{`{"flags":[{"name":"a","type":"IntFlag","secret":true,"default":"hunter2","loaded":false}]}`, "default of flag -a"},
+	}
+	for _, c := range cases {
+		_, err := LoadState(strings.NewReader(c.saved))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("LoadState(%s) gave %v, want an error saying %q", c.saved, err, c.want)
+			continue
+		}
+		if strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("LoadState(%s) gave %q, which shows the secret value", c.saved, err)
+		}
+		var bad *BadValueError
+		if strings.Contains(c.saved, "hunter2") && !errors.As(err, &bad) {
+			t.Errorf("LoadState(%s) gave %v, which does not wrap a *BadValueError", c.saved, err)
+		}
+	}
+}
+
+// TestSaveStateUnsaved checks that SaveState returns an error, rather than leave it out, for a declaration
+// that has no saved form
+func TestSaveStateUnsaved(t *testing.T) {
+	cases := map[string]func(cp *CmdParser){
+		"conditional default": func(cp *CmdParser) {
+			cp.AddFlagWithConditionalDefault(IntFlag, "workers", false, func(cp *CmdParser) any { return 2 })
+		},
+		"environment variable": func(cp *CmdParser) { cp.SetEnv("count", "APP_COUNT") },
+		"companion flag":       func(cp *CmdParser) { cp.EnableFileCompanion("name") },
+		"implied flag":         func(cp *CmdParser) { cp.AddImpliedFlag("fast", "count", "9") },
+		"constraint":           func(cp *CmdParser) { cp.SetMutuallyExclusive("count", "name") },
+	}
+	for what, declare := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		cp.AddFlag(StringFlag, "name", false)
+		declare(cp)
+		var saved bytes.Buffer
+		if err := cp.SaveState(&saved); err == nil || !strings.HasPrefix(err.Error(), "SaveState cannot save") {
+			t.Errorf("SaveState of a %s gave %v, want an error saying it cannot save it", what, err)
+		}
+		if saved.Len() != 0 {
+			t.Errorf("SaveState of a %s wrote %q, want nothing", what, saved.String())
+		}
+	}
+}
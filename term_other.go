@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package cmdline
+
+import (
+	"os"
+)
+
+// terminalWidth returns the width in columns of the terminal the file is attached to, or 0 if unknown,
+// which it always is on this system
+func terminalWidth(f *os.File) int {
+	return 0
+}
+
+// isInteractive reports whether the file is attached to a terminal, as nearly as can be told on this system
+func isInteractive(f *os.File) bool {
+	return isTerminal(f)
+}
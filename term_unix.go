@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+package cmdline
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// windowSize is the terminal size reported by the TIOCGWINSZ ioctl
+type windowSize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+// getWindowSize asks the terminal the file is attached to for its size, reporting false if it is not a terminal
+func getWindowSize(f *os.File) (windowSize, bool) {
+	var size windowSize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	return size, errno == 0
+}
+
+// terminalWidth returns the width in columns of the terminal the file is attached to, or 0 if unknown
+func terminalWidth(f *os.File) int {
+	size, ok := getWindowSize(f)
+	if !ok {
+		return 0
+	}
+	return int(size.cols)
+}
+
+// isInteractive reports whether the file is attached to a terminal, as opposed to, e.g., /dev/null
+func isInteractive(f *os.File) bool {
+	_, ok := getWindowSize(f)
+	return ok
+}
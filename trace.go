@@ -0,0 +1,57 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SetTrace writes to w a line for each step of a parse, to show how each command variable came by its
+// value: each token taken from a source, the variable each flag matches, and each value given a
+// variable, raw and converted, with where it came from and whether it replaced an earlier value or
+// was ignored.  Values of secret variables are redacted.  Tracing does not change what a parse does,
+// and a nil w turns it off
+func (cp *CmdParser) SetTrace(w io.Writer) {
+	cp.trace = w
+}
+
+// tracing reports whether the steps of a parse are traced, to a writer or the logger
+func (cp *CmdParser) tracing() bool {
+	return cp.trace != nil || (cp.Trace && cp.logger != nil)
+}
+
+// tracef writes a line of the trace, if tracing is on, and passes it to the logger if Trace is set
+func (cp *CmdParser) tracef(format string, args ...any) {
+	if cp.trace != nil {
+		fmt.Fprintf(cp.trace, "trace: "+format, args...)
+	}
+	if cp.Trace {
+		cp.infof("trace: "+format, args...)
+	}
+}
+
+// traceToken traces a piece taken from a source, redacted when it is, or carries, the value of a secret variable
+func (pr *pairer) traceToken(piece token) {
+	cp := pr.cp
+	if !cp.tracing() {
+		return
+	}
+	text := strconv.Quote(piece.text)
+	if pr.pending != nil && cp.IsSecret(cp.resolve(strings.Replace(pr.pending.text, "-", "", 1))) &&
+		(!strings.HasPrefix(piece.text, "-") || argIsNumber(piece.text) || piece.quoted) {
+		text = redacted
+	} else if eq := strings.Index(piece.text, "="); eq > 0 && strings.HasPrefix(piece.text, "-") && !piece.quoted &&
+		cp.IsSecret(cp.resolve(strings.Replace(piece.text[:eq], "-", "", 1))) {
+		text = strconv.Quote(piece.text[:eq+1] + redacted)
+	}
+	cp.tracef("token %s (%s)\n", text, piece.at)
+}
+
+// traceValue renders a raw value given a command variable for the trace
+func (cp *CmdParser) traceValue(name string, value string) string {
+	if cp.IsSecret(name) {
+		return redacted
+	}
+	return strconv.Quote(value)
+}
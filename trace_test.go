@@ -0,0 +1,55 @@
+package cmdline
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestTrace checks that the trace of a small command line shows each token, the variable each flag
+// matched, each conversion, and each flag not declared, in order
+func TestTrace(t *testing.T) {
+	var trace bytes.Buffer
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetTrace(&trace)
+	cp.AddFlag(IntFlag, "count", false)
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.ParseFromArgs([]string{"-count", "3", "-v", "-bogus"})
+
+	want := []string{
+		`trace: token "-count" (command line)`,
+		`trace: token "3" (command line)`,
+		`trace: flag -count matched variable -count`,
+		`trace: -count value "3" (command line) converted to 3`,
+		`trace: flag -v matched variable -v`,
+		`trace: -v value "true" (command line) converted to true`,
+		`trace: flag -bogus not declared`,
+	}
+	text := trace.String()
+	last := -1
+	for _, line := range want {
+		at := strings.Index(text, line+"\n")
+		if at < 0 {
+			t.Errorf("trace does not hold %q:\n%s", line, text)
+			continue
+		}
+		if at < last {
+			t.Errorf("trace holds %q out of order:\n%s", line, text)
+		}
+		last = at
+	}
+
+	// without a trace writer nothing is traced
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetTrace(&trace)
+	cp.SetTrace(nil)
+	trace.Reset()
+	cp.AddFlag(IntFlag, "count", false)
+	cp.ParseFromArgs([]string{"-count", "3"})
+	if trace.Len() != 0 {
+		t.Errorf("trace after SetTrace(nil) holds:\n%s", trace.String())
+	}
+}
@@ -0,0 +1,841 @@
+package cmdline
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonVar represents a command variable whose value is JSON text, held as the structure it decodes to,
+// e.g., a map[string]any for an object or a []any for an array
+type jsonVar struct {
+	v_name   string
+	v_value  any
+	v_req    bool
+	v_loaded bool
+}
+
+// createJSONVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createJSONVar(name string, req bool) *jsonVar {
+	vs := &jsonVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type JSONFlag
+func (vs *jsonVar) ArgType() FlagArgType {
+	return JSONFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *jsonVar) Name() string {
+	return vs.v_name
+}
+
+// Set decodes the JSON text extracted from the command line and saves the resulting structure
+func (vs *jsonVar) Set(value string) error {
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = v
+	vs.v_loaded = true
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *jsonVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line
+func (vs *jsonVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *jsonVar) Required() bool {
+	return vs.v_req
+
+}
+
+// GetJSON returns the structure decoded from the JSON text given to the JSONFlag command variable with
+// the input argument 'name', e.g., a map[string]any for "-opts {"a":1,"b":true}"
+func (cp *CmdParser) GetJSON(name string) any {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != JSONFlag {
+		panic(fmt.Sprintf("CmdParser.GetJSON given variable %s, which is not a JSONFlag\n", name))
+	}
+	return value
+}
+
+// intRangeListVar represents a command variable whose value is a list of integers given as
+// comma-separated numbers and ranges, e.g., "1-3,5,7-9", held expanded, sorted, and without duplicates
+type intRangeListVar struct {
+	v_name   string
+	v_value  []int
+	v_req    bool
+	v_loaded bool
+}
+
+// createIntRangeListVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createIntRangeListVar(name string, req bool) *intRangeListVar {
+	vs := &intRangeListVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type IntRangeListFlag
+func (vs *intRangeListVar) ArgType() FlagArgType {
+	return IntRangeListFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *intRangeListVar) Name() string {
+	return vs.v_name
+}
+
+// Set expands the list of numbers and ranges extracted from the command line and saves the result
+func (vs *intRangeListVar) Set(value string) error {
+	list, err := parseIntRanges(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = list
+	vs.v_loaded = true
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *intRangeListVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line
+func (vs *intRangeListVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *intRangeListVar) Required() bool {
+	return vs.v_req
+}
+
+// MaxIntRangeList is the most integers the ranges of an IntRangeListFlag may cover, so that a value
+// such as "1-9999999999" is an error rather than a list that exhausts memory
+const MaxIntRangeList = 1 << 20
+
+// parseIntRanges expands comma-separated numbers and ranges, e.g., "1-3,5,7-9", into the sorted
+// list of the integers they cover, each once, e.g., [1 2 3 5 7 8 9].  A range runs from its
+// first number up to its second, so one whose second number is the smaller, e.g., "5-3", is an error,
+// as are ranges covering more than MaxIntRangeList integers
+func parseIntRanges(value string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+
+		// a '-' after the first character separates the ends of a range, while one at the start is a sign
+		low_text, high_text := part, part
+		if len(part) > 1 {
+			if dash := strings.Index(part[1:], "-"); dash >= 0 {
+				low_text, high_text = part[:dash+1], part[dash+2:]
+			}
+		}
+		low, err := strconv.Atoi(low_text)
+		if err != nil {
+			return nil, fmt.Errorf("malformed int range %q in %q", part, value)
+		}
+		high, err := strconv.Atoi(high_text)
+		if err != nil {
+			return nil, fmt.Errorf("malformed int range %q in %q", part, value)
+		}
+		if high < low {
+			return nil, fmt.Errorf("descending int range %q in %q", part, value)
+		}
+
+		// the difference of the ends is taken unsigned, as it may be too big for an int
+		if uint64(high-low) >= uint64(MaxIntRangeList-len(seen)) {
+			return nil, fmt.Errorf("int ranges %q cover more than %d integers", value, MaxIntRangeList)
+		}
+		for n := low; n <= high; n++ {
+			seen[n] = true
+		}
+	}
+
+	list := make([]int, 0, len(seen))
+	for n := range seen {
+		list = append(list, n)
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+// formatIntRanges is the inverse of parseIntRanges, collapsing runs of consecutive integers
+// in a sorted list into ranges, e.g., "1-3,5,7-9" for [1 2 3 5 7 8 9]
+func formatIntRanges(list []int) string {
+	parts := []string{}
+	for idx := 0; idx < len(list); {
+		end := idx
+		for end+1 < len(list) && list[end+1] == list[end]+1 {
+			end += 1
+		}
+		if end == idx {
+			parts = append(parts, strconv.Itoa(list[idx]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", list[idx], list[end]))
+		}
+		idx = end + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// GetIntRangeList returns the expanded list of integers given to the IntRangeListFlag command variable
+// with the input argument 'name', e.g., [1 2 3 5 7 8 9] for "-pages 1-3,5,7-9"
+func (cp *CmdParser) GetIntRangeList(name string) []int {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != IntRangeListFlag {
+		panic(fmt.Sprintf("CmdParser.GetIntRangeList given variable %s, which is not an IntRangeListFlag\n", name))
+	}
+	list, _ := value.([]int)
+	return list
+}
+
+// lengthStringVar represents a string command variable whose value must have a length, counted in
+// characters rather than bytes, within bounds
+type lengthStringVar struct {
+	stringVar
+	v_min int
+	v_max int
+}
+
+// createLengthStringVar is a constructor whose arguments give the argument a name, indicate whether it is required,
+// and give the least and greatest lengths of its value
+func createLengthStringVar(name string, req bool, min int, max int) *lengthStringVar {
+	vs := &lengthStringVar{stringVar: stringVar{v_name: name,
+		v_req:    req,
+		v_loaded: false},
+		v_min: min,
+		v_max: max}
+	return vs
+}
+
+// checkLength reports an error if the value's length is out of bounds
+func (vs *lengthStringVar) checkLength(value string) error {
+	length := utf8.RuneCountInString(value)
+	if length < vs.v_min || length > vs.v_max {
+		return fmt.Errorf("value %q of flag -%s has %d characters, needs %d to %d", value, vs.v_name, length, vs.v_min, vs.v_max)
+	}
+	return nil
+}
+
+// Set saves the string extracted from the command line, if its length is within bounds
+func (vs *lengthStringVar) Set(value string) error {
+	if err := vs.checkLength(value); err != nil {
+		return err
+	}
+	return vs.stringVar.Set(value)
+}
+
+// AddStringFlagWithLength includes a new string command flag to the parser, as AddFlag does, whose value
+// must be from min to max characters long, e.g., 3 to 32 for a user name.  Characters are counted as runes,
+// so "héllo" has 5.  Values of other lengths are rejected
+func (cp *CmdParser) AddStringFlagWithLength(arg_name string, arg_req bool, min int, max int) {
+	cp.checkFrozen("AddStringFlagWithLength", arg_name)
+	cp.checkName("AddStringFlagWithLength", arg_name)
+	if min < 0 || max < min {
+		panic(fmt.Sprintf("CmdParser.AddStringFlagWithLength given bounds %d to %d for variable %s\n", min, max, arg_name))
+	}
+	cp.vars[arg_name] = createLengthStringVar(arg_name, arg_req, min, max)
+	cp.declared(arg_name)
+}
+
+// LogLevel is the severity named by the value of a log-level flag, from LevelDebug, the least severe, to LevelFatal
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+// logLevelNames are the names of the log levels, in order of severity
+var logLevelNames = []string{"debug", "info", "warn", "error", "fatal"}
+
+// String returns the name of the log level, e.g., "warn"
+func (level LogLevel) String() string {
+	if level < LevelDebug || level > LevelFatal {
+		return fmt.Sprintf("LogLevel(%d)", int(level))
+	}
+	return logLevelNames[level]
+}
+
+// parseLogLevel returns the log level a name stands for, ignoring case, so that "WARN" is LevelWarn
+func parseLogLevel(value string) (LogLevel, error) {
+	for idx, level_name := range logLevelNames {
+		if strings.EqualFold(value, level_name) {
+			return LogLevel(idx), nil
+		}
+	}
+	return LevelDebug, fmt.Errorf("%q is not a log level, expected one of %s", value, strings.Join(logLevelNames, ", "))
+}
+
+// logLevelVar represents a string command variable whose value must name a log level, held in lower case
+type logLevelVar struct {
+	stringVar
+}
+
+// createLogLevelVar is a constructor whose arguments give the argument a name and indicate whether it is required
+func createLogLevelVar(name string, req bool) *logLevelVar {
+	vs := &logLevelVar{stringVar: stringVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}}
+	return vs
+}
+
+// Set saves the name of the log level extracted from the command line, in lower case, if it names one
+func (vs *logLevelVar) Set(value string) error {
+	level, err := parseLogLevel(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	return vs.stringVar.Set(level.String())
+}
+
+// AddLogLevelFlag includes a new string command flag to the parser, as AddFlag does, whose value must
+// be one of the log levels debug, info, warn, error, or fatal, in any case, e.g., "-log-level WARN"
+func (cp *CmdParser) AddLogLevelFlag(arg_name string, arg_req bool) {
+	cp.checkFrozen("AddLogLevelFlag", arg_name)
+	cp.checkName("AddLogLevelFlag", arg_name)
+	cp.vars[arg_name] = createLogLevelVar(arg_name, arg_req)
+	cp.declared(arg_name)
+}
+
+// GetLogLevel returns the log level named by the value of the command variable with the input argument 'name',
+// which must have been declared by AddLogLevelFlag, or by its default.  Without either it returns LevelInfo
+func (cp *CmdParser) GetLogLevel(name string) LogLevel {
+	value := cp.GetVar(name)
+	if _, ok := cp.vars[name].(*logLevelVar); !ok {
+		panic(fmt.Sprintf("CmdParser.GetLogLevel given variable %s, which is not a log level flag\n", name))
+	}
+	level_name, _ := value.(string)
+	level, err := parseLogLevel(level_name)
+	if err != nil {
+		return LevelInfo
+	}
+	return level
+}
+
+// bytesVar represents a command variable whose value is binary data given in base64, held decoded
+type bytesVar struct {
+	v_name   string
+	v_value  []byte
+	v_req    bool
+	v_loaded bool
+	v_url    bool // the URL-safe alphabet is accepted, see SetURLSafe
+}
+
+// createBytesVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createBytesVar(name string, req bool) *bytesVar {
+	vs := &bytesVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type BytesBase64Flag
+func (vs *bytesVar) ArgType() FlagArgType {
+	return BytesBase64Flag
+}
+
+// Name returns the name of the command line variable
+func (vs *bytesVar) Name() string {
+	return vs.v_name
+}
+
+// Set decodes the base64 text extracted from the command line and saves the resulting bytes
+func (vs *bytesVar) Set(value string) error {
+	data, err := decodeBase64(value, vs.v_url)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = data
+	vs.v_loaded = true
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *bytesVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line
+func (vs *bytesVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *bytesVar) Required() bool {
+	return vs.v_req
+}
+
+// decodeBase64 decodes standard base64, with or without padding.  When url is true the URL-safe
+// alphabet, with '-' and '_' in place of '+' and '/', is accepted as well
+func decodeBase64(value string, url bool) ([]byte, error) {
+	if url {
+		value = strings.NewReplacer("-", "+", "_", "/").Replace(value)
+	}
+	if strings.HasSuffix(value, "=") {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// SetURLSafe has the BytesBase64Flag command variable with the input argument 'name' accept values encoded
+// with the URL-safe base64 alphabet, as well as the standard one
+func (cp *CmdParser) SetURLSafe(name string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetURLSafe given unrecognized variable name %s\n", name))
+	}
+	bv, ok := cp.vars[name].(*bytesVar)
+	if !ok {
+		panic(fmt.Sprintf("CmdParser.SetURLSafe given variable %s, which is not a BytesBase64Flag\n", name))
+	}
+	bv.v_url = true
+}
+
+// GetBytesValue returns the bytes decoded from the base64 given to the BytesBase64Flag command variable
+// with the input argument 'name', e.g., []byte("hi") for "-data aGk="
+func (cp *CmdParser) GetBytesValue(name string) []byte {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != BytesBase64Flag {
+		panic(fmt.Sprintf("CmdParser.GetBytesValue given variable %s, which is not a BytesBase64Flag\n", name))
+	}
+	data, _ := value.([]byte)
+	return data
+}
+
+// intSliceVar represents a command variable whose value is a list of integers given as comma-separated
+// elements, e.g., "1,2,3".  Each time the flag is given its elements are appended to the list, save that
+// a value from a source of higher precedence than the list's replaces it, see SetPrecedence
+type intSliceVar struct {
+	v_name   string
+	v_value  []int
+	v_req    bool
+	v_loaded bool
+}
+
+// createIntSliceVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createIntSliceVar(name string, req bool) *intSliceVar {
+	vs := &intSliceVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type IntSliceFlag
+func (vs *intSliceVar) ArgType() FlagArgType {
+	return IntSliceFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *intSliceVar) Name() string {
+	return vs.v_name
+}
+
+// Set appends the elements of the list extracted from the command line to the list saved.  An empty
+// value has no elements, so the variable is loaded only once some value has had one
+func (vs *intSliceVar) Set(value string) error {
+	list, err := parseIntSlice(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = append(vs.v_value, list...)
+	vs.v_loaded = len(vs.v_value) > 0
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *intSliceVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line with one or more elements,
+// so that a required slice is satisfied by an element and not by an empty value
+func (vs *intSliceVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *intSliceVar) Required() bool {
+	return vs.v_req
+}
+
+// floatSliceVar represents a command variable whose value is a list of floats given as comma-separated
+// elements, e.g., "0.5,1.5".  Each time the flag is given its elements are appended to the list, save that
+// a value from a source of higher precedence than the list's replaces it, see SetPrecedence
+type floatSliceVar struct {
+	v_name   string
+	v_value  []float64
+	v_req    bool
+	v_loaded bool
+}
+
+// createFloatSliceVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createFloatSliceVar(name string, req bool) *floatSliceVar {
+	vs := &floatSliceVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type FloatSliceFlag
+func (vs *floatSliceVar) ArgType() FlagArgType {
+	return FloatSliceFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *floatSliceVar) Name() string {
+	return vs.v_name
+}
+
+// Set appends the elements of the list extracted from the command line to the list saved.  An empty
+// value has no elements, so the variable is loaded only once some value has had one
+func (vs *floatSliceVar) Set(value string) error {
+	list, err := parseFloatSlice(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = append(vs.v_value, list...)
+	vs.v_loaded = len(vs.v_value) > 0
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *floatSliceVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line with one or more elements,
+// so that a required slice is satisfied by an element and not by an empty value
+func (vs *floatSliceVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *floatSliceVar) Required() bool {
+	return vs.v_req
+}
+
+// parseIntSlice converts comma-separated elements, e.g., "1,2,3", to a list of integers, naming the
+// first element that is not an integer in the error.  An empty value, or one of only white space, has no elements
+func parseIntSlice(value string) ([]int, error) {
+	list := []int{}
+	if strings.TrimSpace(value) == "" {
+		return list, nil
+	}
+	for _, elem := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(elem))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse element %q of %q as int", elem, value)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseFloatSlice converts comma-separated elements, e.g., "0.5,1.5", to a list of floats, naming the
+// first element that is not a float in the error.  An empty value, or one of only white space, has no elements
+func parseFloatSlice(value string) ([]float64, error) {
+	list := []float64{}
+	if strings.TrimSpace(value) == "" {
+		return list, nil
+	}
+	for _, elem := range strings.Split(value, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(elem), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse element %q of %q as float", elem, value)
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// formatSlice renders a list of integers or floats as the comma-separated elements parsed to it
+func formatSlice(value any) string {
+	parts := []string{}
+	switch list := value.(type) {
+	case []int:
+		for _, n := range list {
+			parts = append(parts, strconv.Itoa(n))
+		}
+		break
+	case []float64:
+		for _, f := range list {
+			parts = append(parts, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		break
+	}
+	return strings.Join(parts, ",")
+}
+
+// isList reports whether the name is that of a command variable holding a list that each value
+// given is appended to, i.e., an IntSliceFlag or FloatSliceFlag
+func (cp *CmdParser) isList(name string) bool {
+	if !cp.IsFlag(name) {
+		return false
+	}
+	arg_type := cp.vars[name].ArgType()
+	return arg_type == IntSliceFlag || arg_type == FloatSliceFlag
+}
+
+// accumulates reports whether a value given to a command variable adds to what it holds, as for a list or map,
+// rather than replacing it
+func accumulates(v arg) bool {
+	arg_type := v.ArgType()
+	return arg_type == IntSliceFlag || arg_type == FloatSliceFlag || arg_type == TypedMapFlag
+}
+
+// GetIntSlice returns the list of integers given to the IntSliceFlag command variable with the input
+// argument 'name', e.g., [1 2 3] for "-nums 1,2,3"
+func (cp *CmdParser) GetIntSlice(name string) []int {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != IntSliceFlag {
+		panic(fmt.Sprintf("CmdParser.GetIntSlice given variable %s, which is not an IntSliceFlag\n", name))
+	}
+	list, _ := value.([]int)
+	return list
+}
+
+// GetFloatSlice returns the list of floats given to the FloatSliceFlag command variable with the input
+// argument 'name', e.g., [0.5 1.5] for "-weights 0.5,1.5"
+func (cp *CmdParser) GetFloatSlice(name string) []float64 {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != FloatSliceFlag {
+		panic(fmt.Sprintf("CmdParser.GetFloatSlice given variable %s, which is not a FloatSliceFlag\n", name))
+	}
+	list, _ := value.([]float64)
+	return list
+}
+
+// flexDurationVar represents a command variable whose value is a time.Duration, given either with a unit,
+// e.g., "30s" or "500ms", or as a bare number of seconds, e.g., "30" or "1.5"
+type flexDurationVar struct {
+	v_name   string
+	v_value  time.Duration
+	v_req    bool
+	v_loaded bool
+}
+
+// createFlexDurationVar is a constructor whose arguments give the argument a name and indicate whether it is required.
+func createFlexDurationVar(name string, req bool) *flexDurationVar {
+	vs := &flexDurationVar{v_name: name,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type FlexDurationFlag
+func (vs *flexDurationVar) ArgType() FlagArgType {
+	return FlexDurationFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *flexDurationVar) Name() string {
+	return vs.v_name
+}
+
+// Set saves the duration extracted from the command line
+func (vs *flexDurationVar) Set(value string) error {
+	d, err := parseFlexDuration(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	vs.v_value = d
+	vs.v_loaded = true
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *flexDurationVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line
+func (vs *flexDurationVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *flexDurationVar) Required() bool {
+	return vs.v_req
+}
+
+// parseFlexDuration converts a duration to a time.Duration.  A value that is a number and nothing else,
+// e.g., "30" or "1.5", is a number of seconds, so "30" is the same as "30s".  Anything else is parsed by
+// time.ParseDuration, and so needs a unit, e.g., "500ms" or "1h30m".  Thus "0.5" is half a second, never
+// half of some other unit, and a number with a unit always means what the unit says
+func parseFlexDuration(value string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(trimmed, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return 0, fmt.Errorf("%q seconds is out of range for a duration", value)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q as a duration, e.g., \"30s\", or a number of seconds", value)
+	}
+	return d, nil
+}
+
+// GetFlexDuration returns the duration given to the FlexDurationFlag command variable with the input
+// argument 'name', e.g., 30*time.Second for either "-timeout 30" or "-timeout 30s"
+func (cp *CmdParser) GetFlexDuration(name string) time.Duration {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != FlexDurationFlag {
+		panic(fmt.Sprintf("CmdParser.GetFlexDuration given variable %s, which is not a FlexDurationFlag\n", name))
+	}
+	d, _ := value.(time.Duration)
+	return d
+}
+
+// typedMapVar represents a command variable whose value is a map from keys to values given as comma-separated
+// key=value entries, e.g., "cpu=2,mem=512", each value converted by a parser supplied for the flag.  Each time
+// the flag is given its entries are added to the map, replacing the values of keys already in it, save that
+// a value from a source of higher precedence than the map's replaces the whole map, see SetPrecedence
+type typedMapVar struct {
+	v_name   string
+	v_value  map[string]any
+	v_parse  func(value string) (any, error)
+	v_req    bool
+	v_loaded bool
+}
+
+// createTypedMapVar is a constructor whose arguments give the argument a name, indicate whether it is required,
+// and give the parser that converts the value of each entry
+func createTypedMapVar(name string, req bool, parse func(value string) (any, error)) *typedMapVar {
+	vs := &typedMapVar{v_name: name,
+		v_value:  make(map[string]any),
+		v_parse:  parse,
+		v_req:    req,
+		v_loaded: false}
+	return vs
+}
+
+// ArgType returns the enumerated type TypedMapFlag
+func (vs *typedMapVar) ArgType() FlagArgType {
+	return TypedMapFlag
+}
+
+// Name returns the name of the command line variable
+func (vs *typedMapVar) Name() string {
+	return vs.v_name
+}
+
+// parseEntries converts comma-separated key=value entries, e.g., "cpu=2,mem=512", to a map, converting each
+// value with the variable's parser and naming the key of the first value it rejects in the error.  An empty
+// value, or one of only white space, has no entries
+func (vs *typedMapVar) parseEntries(value string) (map[string]any, error) {
+	entries := make(map[string]any)
+	if strings.TrimSpace(value) == "" {
+		return entries, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		eq := strings.Index(entry, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("entry %q of %q is not key=value", entry, value)
+		}
+		key := strings.TrimSpace(entry[:eq])
+		if _, present := entries[key]; present {
+			return nil, fmt.Errorf("key %q is given more than once in %q", key, value)
+		}
+		v, err := vs.v_parse(strings.TrimSpace(entry[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("value for key %q: %w", key, err)
+		}
+		entries[key] = v
+	}
+	return entries, nil
+}
+
+// Set adds the entries extracted from the command line to the map saved.  An empty value has no entries,
+// so the variable is loaded only once some value has had one
+func (vs *typedMapVar) Set(value string) error {
+	entries, err := vs.parseEntries(value)
+	if err != nil {
+		return setError(vs.v_name, value, err)
+	}
+	for key, v := range entries {
+		vs.v_value[key] = v
+	}
+	vs.v_loaded = len(vs.v_value) > 0
+	return nil
+}
+
+// Get returns the command variable's value with unspecified type
+func (vs *typedMapVar) Get() any {
+	return vs.v_value
+}
+
+// Loaded indicates whether this command variable was extracted from the command line with one or more entries
+func (vs *typedMapVar) Loaded() bool {
+	return vs.v_loaded
+}
+
+// Required indicates whether this command variable must appear on the command line
+func (vs *typedMapVar) Required() bool {
+	return vs.v_req
+}
+
+// formatTypedMap renders a map as the comma-separated key=value entries parsed to it, sorted by key
+func formatTypedMap(entries map[string]any) string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := []string{}
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, entries[key]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// AddTypedMapFlag includes a new command flag to the parser, as AddFlag does, whose value is a map from keys to
+// values given as comma-separated key=value entries, e.g., "-limits cpu=2,mem=512".  The parser converts the
+// value of each entry, e.g., strconv.Atoi wrapped to return an int for a map of ints, and a value it rejects
+// is an error naming the key.  Given more than once, the flag adds its entries to the map
+func (cp *CmdParser) AddTypedMapFlag(arg_name string, arg_req bool, parse func(value string) (any, error)) {
+	cp.checkFrozen("AddTypedMapFlag", arg_name)
+	cp.checkName("AddTypedMapFlag", arg_name)
+	if parse == nil {
+		panic(fmt.Sprintf("CmdParser.AddTypedMapFlag given no parser for variable %s\n", arg_name))
+	}
+	cp.vars[arg_name] = createTypedMapVar(arg_name, arg_req, parse)
+	cp.declared(arg_name)
+}
+
+// GetTypedMap returns the map of converted values given to the TypedMapFlag command variable with the input
+// argument 'name', e.g., map[cpu:2 mem:512] for "-limits cpu=2,mem=512" with a parser of ints, or nil if none was given
+func (cp *CmdParser) GetTypedMap(name string) map[string]any {
+	value := cp.GetVar(name)
+	if cp.vars[name].ArgType() != TypedMapFlag {
+		panic(fmt.Sprintf("CmdParser.GetTypedMap given variable %s, which is not a TypedMapFlag\n", name))
+	}
+	entries, _ := value.(map[string]any)
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries
+}
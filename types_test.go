@@ -0,0 +1,404 @@
+package cmdline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJSONFlag checks that a JSONFlag decodes an object and an array, and rejects malformed JSON
+func TestJSONFlag(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(JSONFlag, "opts", false)
+	cp.AddFlag(JSONFlag, "hosts", false)
+	if err := cp.ParseFromArgs([]string{"-opts", `{"a":1,"b":true}`, "-hosts", `["x","y"]`}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.GetJSON("opts"), map[string]any{"a": 1.0, "b": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-opts is %#v, want %#v", got, want)
+	}
+	if got, want := cp.GetJSON("hosts"), []any{"x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-hosts is %#v, want %#v", got, want)
+	}
+
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(JSONFlag, "opts", false)
+	err := cp.ParseFromArgs([]string{"-opts", `{"a":1,`})
+	var bad *BadValueError
+	if !errors.As(err, &bad) || bad.Flag != "opts" {
+		t.Fatalf("malformed JSON gave error %v, want a *BadValueError for -opts", err)
+	}
+	var syntax_err *json.SyntaxError
+	if !errors.As(err, &syntax_err) {
+		t.Errorf("malformed JSON error %v does not unwrap to a *json.SyntaxError", err)
+	}
+	if cp.IsLoaded("opts") {
+		t.Errorf("-opts is loaded after malformed JSON")
+	}
+}
+
+// TestIntRangeList checks that a mixed list of numbers and ranges and a single range expand to the integers
+// they cover, and that a descending range and ranges covering too many integers are rejected
+func TestIntRangeList(t *testing.T) {
+	for value, want := range map[string][]int{
+		"1-3,5,7-9": {1, 2, 3, 5, 7, 8, 9},
+		"9,2-4,3":   {2, 3, 4, 9},
+		"10-12":     {10, 11, 12},
+		"-2--1,0":   {-2, -1, 0},
+	} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntRangeListFlag, "pages", false)
+		if err := cp.ParseFromArgs([]string{"-pages=" + value}); err != nil {
+			t.Errorf("ParseFromArgs of %q failed: %v", value, err)
+			continue
+		}
+		if got := cp.GetIntRangeList("pages"); !reflect.DeepEqual(got, want) {
+			t.Errorf("-pages %s is %v, want %v", value, got, want)
+		}
+	}
+
+	for value, want := range map[string]string{
+		"5-3":                     `descending int range "5-3"`,
+		"1-2,x":                   `malformed int range "x"`,
+		"1-9999999999":            "cover more than",
+		"1-600000,700000-1300000": "cover more than",
+	} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntRangeListFlag, "pages", false)
+		err := cp.ParseFromArgs([]string{"-pages", value})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("-pages %s gave error %v, want one saying %q", value, err, want)
+		}
+	}
+}
+
+// TestStringLength checks that a string flag with length bounds rejects values too short and too long,
+// accepts one in range, and counts the characters of a multibyte string rather than its bytes
+func TestStringLength(t *testing.T) {
+	for value, ok := range map[string]bool{"ab": false, "abcdefghi": false, "abcd": true, "héllo": true, "日本語日本語": true, "日本語日本語日本": false} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddStringFlagWithLength("user", false, 3, 6)
+		err := cp.ParseFromArgs([]string{"-user", value})
+		if ok && err != nil {
+			t.Errorf("-user %q, of %d bytes, was rejected: %v", value, len(value), err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "needs 3 to 6")) {
+			t.Errorf("-user %q gave error %v, want one about its length", value, err)
+		}
+		if ok && cp.GetVar("user") != value {
+			t.Errorf("-user is %q, want %q", cp.GetVar("user"), value)
+		}
+	}
+}
+
+// TestBytesBase64 checks that standard base64 is decoded with or without padding, that the URL-safe
+// alphabet is accepted only after SetURLSafe, and that text that is not base64 is rejected
+func TestBytesBase64(t *testing.T) {
+	cases := []struct {
+		value   string
+		urlSafe bool
+		want    []byte // nil for a value that is rejected
+	}{
+		{"aGk=", false, []byte("hi")},
+		{"aGk", false, []byte("hi")},
+		{"+/8=", false, []byte{0xfb, 0xff}},
+		{"-_8=", true, []byte{0xfb, 0xff}},
+		{"+/8", true, []byte{0xfb, 0xff}},
+		{"-_8=", false, nil},
+		{"a!k=", false, nil},
+		{"a", true, nil},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(BytesBase64Flag, "data", false)
+		if c.urlSafe {
+			cp.SetURLSafe("data")
+		}
+		err := cp.ParseFromArgs([]string{"-data=" + c.value})
+		if c.want == nil {
+			var bad *BadValueError
+			if !errors.As(err, &bad) {
+				t.Errorf("-data %q (URL-safe %v) gave error %v, want a *BadValueError", c.value, c.urlSafe, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("-data %q (URL-safe %v) failed: %v", c.value, c.urlSafe, err)
+			continue
+		}
+		if got := cp.GetBytesValue("data"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("-data %q (URL-safe %v) is %v, want %v", c.value, c.urlSafe, got, c.want)
+		}
+	}
+}
+
+// TestSliceElements checks that the elements of int and float lists are converted to their type, and
+// that a list with one bad element is rejected as a whole, naming the element
+func TestSliceElements(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntSliceFlag, "ports", false)
+	cp.AddFlag(FloatSliceFlag, "weights", false)
+	if err := cp.ParseFromArgs([]string{"-ports", "80, 443,8080", "-weights", "0.5,1.5"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.GetIntSlice("ports"), []int{80, 443, 8080}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-ports is %v, want %v", got, want)
+	}
+	if got, want := cp.GetFloatSlice("weights"), []float64{0.5, 1.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-weights is %v, want %v", got, want)
+	}
+
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntSliceFlag, "ports", false)
+	err := cp.ParseFromArgs([]string{"-ports", "80,http,8080"})
+	if err == nil || !strings.Contains(err.Error(), `cannot parse element "http" of "80,http,8080" as int`) {
+		t.Errorf("-ports 80,http,8080 gave error %v, want one naming the element http", err)
+	}
+	if cp.IsLoaded("ports") {
+		t.Errorf("-ports holds %v after a bad element", cp.GetIntSlice("ports"))
+	}
+}
+
+// TestSlicePrecedence checks that a list adds the values given it by one source, but that a value from a
+// source of higher precedence replaces the list rather than adding to it, and one from a lower source is ignored
+func TestSlicePrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.cfg")
+	if err := os.WriteFile(path, []byte("-ids 1,2\n-weights 0.5\n"), 0o644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	cases := []struct {
+		what    string
+		parse   func(cp *CmdParser) error
+		ids     []int
+		weights []float64
+	}{
+		{"one source", func(cp *CmdParser) error {
+			return cp.ParseFromArgs([]string{"-ids", "1", "-ids", "2,3", "-weights", "0.5"})
+		}, []int{1, 2, 3}, []float64{0.5}},
+		{"a file, then arguments", func(cp *CmdParser) error {
+			return cp.ParseFromArgs([]string{"-is", path, "-ids", "7"})
+		}, []int{7}, []float64{0.5}},
+		{"ApplyFile, then ApplyArgs", func(cp *CmdParser) error {
+			if err := cp.ApplyFile(path); err != nil {
+				return err
+			}
+			return cp.ApplyArgs([]string{"-ids", "7", "-weights", "1.5"})
+		}, []int{7}, []float64{1.5}},
+		{"ApplyArgs, then ApplyFile", func(cp *CmdParser) error {
+			if err := cp.ApplyArgs([]string{"-ids", "7"}); err != nil {
+				return err
+			}
+			return cp.ApplyFile(path)
+		}, []int{7}, []float64{0.5}},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntSliceFlag, "ids", false)
+		cp.AddFlag(FloatSliceFlag, "weights", false)
+		if err := c.parse(cp); err != nil {
+			t.Errorf("%s failed: %v", c.what, err)
+			continue
+		}
+		if !reflect.DeepEqual(cp.GetIntSlice("ids"), c.ids) || !reflect.DeepEqual(cp.GetFloatSlice("weights"), c.weights) {
+			t.Errorf("%s gave -ids %v and -weights %v, want %v and %v", c.what, cp.GetIntSlice("ids"),
+				cp.GetFloatSlice("weights"), c.ids, c.weights)
+		}
+	}
+}
+
+// TestLogLevel checks that each level name, in any case, gives its level, and that an invalid name is rejected
+func TestLogLevel(t *testing.T) {
+	for value, want := range map[string]LogLevel{"debug": LevelDebug, "info": LevelInfo, "WARN": LevelWarn, "Error": LevelError, "fatal": LevelFatal} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddLogLevelFlag("log-level", false)
+		if err := cp.ParseFromArgs([]string{"-log-level", value}); err != nil {
+			t.Errorf("-log-level %s failed: %v", value, err)
+			continue
+		}
+		if got := cp.GetLogLevel("log-level"); got != want {
+			t.Errorf("-log-level %s is %v, want %v", value, got, want)
+		}
+		if got := cp.GetVar("log-level"); got != want.String() {
+			t.Errorf("-log-level %s holds %q, want %q", value, got, want.String())
+		}
+	}
+
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddLogLevelFlag("log-level", false)
+	err := cp.ParseFromArgs([]string{"-log-level", "verbose"})
+	if err == nil || !strings.Contains(err.Error(), `"verbose" is not a log level, expected one of debug, info, warn, error, fatal`) {
+		t.Errorf("-log-level verbose gave error %v, want one listing the levels", err)
+	}
+	if got := cp.GetLogLevel("log-level"); got != LevelInfo {
+		t.Errorf("-log-level is %v after an invalid name, want %v", got, LevelInfo)
+	}
+}
+
+// TestFlexDuration checks that a bare number is a number of seconds, that a number with a unit means what
+// the unit says, and that a value that is neither is rejected
+func TestFlexDuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{"30": 30 * time.Second, "30s": 30 * time.Second,
+		"500ms": 500 * time.Millisecond, "1.5": 1500 * time.Millisecond, "1h30m": 90 * time.Minute} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(FlexDurationFlag, "timeout", false)
+		if err := cp.ParseFromArgs([]string{"-timeout", value}); err != nil {
+			t.Errorf("-timeout %s failed: %v", value, err)
+			continue
+		}
+		if got := cp.GetFlexDuration("timeout"); got != want {
+			t.Errorf("-timeout %s is %v, want %v", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"30 sec", "soon", "1e300"} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(FlexDurationFlag, "timeout", false)
+		if err := cp.ParseFromArgs([]string{"-timeout", value}); err == nil {
+			t.Errorf("-timeout %q returned no error, giving %v", value, cp.GetFlexDuration("timeout"))
+		}
+	}
+}
+
+// TestRequiredSlice checks that a required list is satisfied by a value with elements, and not by an
+// explicitly empty value, which leaves it unloaded
+func TestRequiredSlice(t *testing.T) {
+	cases := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-ports", "80,443"}, true},
+		{[]string{"-ports", "80"}, true},
+		{[]string{"-ports="}, false},
+		{[]string{"-ports", " "}, false},
+		{[]string{}, false},
+	}
+	for _, c := range cases {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntSliceFlag, "ports", true)
+		err := cp.ParseFromArgs(c.args)
+		var missing *MissingRequiredError
+		if c.ok && err != nil {
+			t.Errorf("required -ports given %q failed: %v", c.args, err)
+		}
+		if !c.ok && !errors.As(err, &missing) {
+			t.Errorf("required -ports given %q gave %v, want -ports missing", c.args, err)
+		}
+		if cp.IsLoaded("ports") != c.ok {
+			t.Errorf("-ports given %q is loaded %v, want %v", c.args, cp.IsLoaded("ports"), c.ok)
+		}
+	}
+}
+
+// TestIntBoundaries checks the largest and smallest values that fit in 32- and 64-bit ints, and those one
+// past them, for whichever size an int is where the test runs, and that an IntFlag holds what fits an int
+func TestIntBoundaries(t *testing.T) {
+	cases := []struct {
+		bits  int
+		value string
+		ok    bool
+	}{
+		{32, "2147483647", true},
+		{32, "-2147483648", true},
+		{32, "2147483648", false},
+		{32, "-2147483649", false},
+		{32, "3000000000", false},
+		{64, "9223372036854775807", true},
+		{64, "-9223372036854775808", true},
+		{64, "9223372036854775808", false},
+		{64, "-9223372036854775809", false},
+	}
+	for _, c := range cases {
+		_, err := parseIntSized(c.value, c.bits)
+		var range_err *intRangeError
+		if c.ok && err != nil {
+			t.Errorf("%s does not fit a %d-bit int: %v", c.value, c.bits, err)
+		}
+		if !c.ok && (!errors.As(err, &range_err) || range_err.bits != c.bits || !errors.Is(err, strconv.ErrRange)) {
+			t.Errorf("%s for a %d-bit int gave %v, want an *intRangeError wrapping strconv.ErrRange", c.value, c.bits, err)
+		}
+	}
+
+	// an IntFlag holds values that fit the int of the platform, and names its width for those that do not
+	for value, ok := range map[string]bool{strconv.Itoa(math.MaxInt): true, strconv.Itoa(math.MinInt): true,
+		strconv.FormatUint(uint64(math.MaxInt)+1, 10): false} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddFlag(IntFlag, "count", false)
+		err := cp.ParseFromArgs([]string{"-count=" + value})
+		if ok && (err != nil || strconv.Itoa(cp.GetVar("count").(int)) != value) {
+			t.Errorf("-count %s gave %v, %v", value, cp.GetVar("count"), err)
+		}
+		want := fmt.Sprintf("out of range for a %d-bit int", strconv.IntSize)
+		if !ok && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("-count %s gave error %v, want one saying it is %s", value, err, want)
+		}
+	}
+}
+
+// intEntry is the parser of the values of a map of ints
+func intEntry(value string) (any, error) {
+	return strconv.Atoi(value)
+}
+
+// TestTypedMap checks that a map of ints holds its converted entries, merged across repeats of the flag,
+// that an invalid value for one key is an error naming the key, and that Unset clears the map and SaveState
+// refuses to save it
+func TestTypedMap(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddTypedMapFlag("limits", false, intEntry)
+	if err := cp.ParseFromArgs([]string{"-limits", "cpu=2, mem=512", "-limits", "mem=1024,disk=10"}); err != nil {
+		t.Fatalf("ParseFromArgs failed: %v", err)
+	}
+	if got, want := cp.GetTypedMap("limits"), map[string]any{"cpu": 2, "mem": 1024, "disk": 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-limits is %v, want %v", got, want)
+	}
+
+	var saved strings.Builder
+	if err := cp.SaveState(&saved); err == nil || !strings.Contains(err.Error(), "SaveState cannot save flag -limits") {
+		t.Errorf("SaveState of a typed map gave %v, want an error naming -limits", err)
+	}
+	if !cp.Unset("limits") || cp.IsLoaded("limits") || cp.GetTypedMap("limits") != nil {
+		t.Errorf("-limits is %v after Unset", cp.GetTypedMap("limits"))
+	}
+
+	for value, want := range map[string]string{
+		"cpu=2,mem=lots": `value for key "mem"`,
+		"cpu=2,mem":      `entry "mem" of "cpu=2,mem" is not key=value`,
+		"cpu=2,cpu=3":    `key "cpu" is given more than once`,
+	} {
+		cp := NewCmdParser()
+		cp.SetOutput(io.Discard)
+		cp.AddTypedMapFlag("limits", false, intEntry)
+		err := cp.ParseFromArgs([]string{"-limits", value})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("-limits %s gave error %v, want one saying %q", value, err, want)
+		}
+		if cp.IsLoaded("limits") {
+			t.Errorf("-limits holds %v after the bad value %q", cp.GetTypedMap("limits"), value)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ANSI escape sequences used to color usage output written to a terminal
+const (
+	colorFlag     = "\x1b[36m"
+	colorRequired = "\x1b[31m"
+	colorReset    = "\x1b[0m"
+)
+
+// isTerminal reports whether the writer is a file attached to a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// typeName gives the short, lower case name of a type used in usage output, e.g., "int" for IntFlag
+func typeName(arg_type FlagArgType) string {
+	return strings.ToLower(strings.TrimSuffix(FlagTypeString(arg_type), "Flag"))
+}
+
+// valueName gives the placeholder shown after a flag in usage output for the kind of value it takes,
+// e.g., "<float>", or "<int|string>" for a union.  Boolean flags need no value and have none
+func valueName(v arg) string {
+	if _, ok := v.(*logLevelVar); ok {
+		return "<" + strings.Join(logLevelNames, "|") + ">"
+	}
+	switch v.ArgType() {
+	case BoolFlag:
+		return ""
+	case FlexDurationFlag:
+		return "<duration>"
+	case TypedMapFlag:
+		return "<key=value,...>"
+	case UnionFlag:
+		names := []string{}
+		for _, arg_type := range v.(*unionVar).v_types {
+			names = append(names, typeName(arg_type))
+		}
+		return "<" + strings.Join(names, "|") + ">"
+	default:
+		return "<" + typeName(v.ArgType()) + ">"
+	}
+}
+
+// lengthBounds describes the lengths a string may have, e.g., "3 to 32 characters" or "exactly 8 characters"
+func lengthBounds(min int, max int) string {
+	if min == max {
+		return fmt.Sprintf("exactly %d characters", min)
+	}
+	return fmt.Sprintf("%d to %d characters", min, max)
+}
+
+// flagSyntax gives how a command variable's flag is written in usage output, e.g., "-count (-c) <int>"
+func (cp *CmdParser) flagSyntax(name string) string {
+	syntax := "-" + name
+	for _, alias := range cp.info[name].aliases {
+		syntax += " (-" + alias + ")"
+	}
+	if value_name := valueName(cp.vars[name]); value_name != "" {
+		syntax += " " + value_name
+	}
+	return syntax
+}
+
+// defaultText describes the default of a command variable for usage output, e.g., `(default "out.txt")`,
+// or returns "" when it has none.  String defaults are quoted so that empty or blank ones show up
+func (cp *CmdParser) defaultText(name string) string {
+	info := cp.info[name]
+	if !info.hasDef {
+		return ""
+	}
+	text := cp.display(name, info.def)
+	if cp.vars[name].ArgType() == StringFlag && !info.secret {
+		text = strconv.Quote(text)
+	}
+	return "(default " + text + ")"
+}
+
+// FlagOrder is the type basis for an enumerated type of orders in which help lists flags
+type FlagOrder int
+
+// Declaration and Alphabetical are the enumerated orders in which help lists flags, see UsageOrder:
+// the order in which the command variables were declared, or alphabetical order of their names
+const (
+	Declaration FlagOrder = iota
+	Alphabetical
+)
+
+// listOrder returns the names of the command variables in the order help lists them, see UsageOrder
+func (cp *CmdParser) listOrder() []string {
+	if cp.UsageOrder == Alphabetical {
+		return cp.sortedNames()
+	}
+	return cp.order
+}
+
+// otherGroup is the title of the section of help listing the variables in no group
+const otherGroup = "Other"
+
+// SetGroup puts the command variable with the input argument 'name' in a group, under whose header,
+// e.g., "Input options:", Usage lists it.  Sections appear in the order their groups were first
+// mentioned, unless SetGroupOrder says otherwise, followed by the variables in no group
+func (cp *CmdParser) SetGroup(name string, group string) {
+	if !cp.IsFlag(name) {
+		panic(fmt.Sprintf("CmdParser.SetGroup given unrecognized variable name %s\n", name))
+	}
+	cp.info[name].group = group
+	cp.addGroup(group)
+}
+
+// addGroup appends a group to the display order, if it is not there already
+func (cp *CmdParser) addGroup(group string) {
+	for _, known := range cp.groups {
+		if known == group {
+			return
+		}
+	}
+	cp.groups = append(cp.groups, group)
+}
+
+// SetGroupOrder gives the order in which Usage shows the sections of groups.  Groups it leaves out
+// follow those it names, in the order they were first mentioned
+func (cp *CmdParser) SetGroupOrder(order []string) {
+	rest := cp.groups
+	cp.groups = nil
+	for _, group := range order {
+		cp.addGroup(group)
+	}
+	for _, group := range rest {
+		cp.addGroup(group)
+	}
+}
+
+// SetGroupDescription gives text that Usage shows under the header of a group
+func (cp *CmdParser) SetGroupDescription(group string, description string) {
+	cp.groupDescs[group] = description
+	cp.addGroup(group)
+}
+
+// usageSection is a titled part of help and the variables listed in it
+type usageSection struct {
+	title       string
+	description string
+	names       []string
+}
+
+// usageSections divides the variables that are not hidden into the sections of help, each in the order
+// given by UsageOrder.  Without groups there is a single section, "Flags:".  Sections with nothing to list are dropped
+func (cp *CmdParser) usageSections() []usageSection {
+	members := make(map[string][]string)
+	for _, name := range cp.listOrder() {
+		if !cp.info[name].hidden {
+			members[cp.info[name].group] = append(members[cp.info[name].group], name)
+		}
+	}
+
+	sections := []usageSection{}
+	for _, group := range cp.groups {
+		if len(members[group]) > 0 {
+			sections = append(sections, usageSection{title: group + " options:",
+				description: cp.groupDescs[group], names: members[group]})
+		}
+	}
+	if len(members[""]) > 0 {
+		title := "Flags:"
+		if len(sections) > 0 {
+			title = otherGroup + " options:"
+		}
+		sections = append(sections, usageSection{title: title, names: members[""]})
+	}
+	return sections
+}
+
+// DefaultUsageTemplate is the text/template with which Usage writes help unless SetUsageTemplate
+// gives another.  It is executed with a UsageData
+const DefaultUsageTemplate = `Usage: {{.Program}} [flags]{{if .Commands}} <command> [command flags]{{end}}
+{{- if .Description}}
+
+{{.Description}}
+{{- end}}
+{{- range .Sections}}
+
+{{.Title}}
+{{- if .Description}}
+  {{.Description}}
+{{- end}}
+{{- range .Flags}}
+  {{.Syntax}}{{if .Details}}{{.Padding}}{{.Details}}{{end}}
+{{- end}}
+{{- end}}
+{{- if .Commands}}
+
+Commands:
+{{- range .Commands}}
+  {{.Name}}{{if .Description}}{{.Padding}}{{.Description}}{{end}}
+{{- end}}
+{{- end}}
+{{- if .Examples}}
+
+Examples:
+{{- range .Examples}}
+  {{.Description}}
+    {{$.Program}} {{.Command}}
+{{- end}}
+{{- end}}
+`
+
+// UsageData is what a usage template is executed with
+type UsageData struct {
+	Program     string         // name by which the program was invoked, followed by the commands selected to reach this CmdParser
+	Description string         // summary given to SetDescription
+	Sections    []UsageSection // the flags by group, see SetGroup, or a single "Flags:" section without groups, and for a command the "Global flags:"
+	Flags       []UsageFlag    // all the flags, in the order given by UsageOrder
+	Examples    []UsageExample // examples given to AddExample, in the order added
+	Commands    []UsageCommand // commands given to AddCommand, in the order added
+}
+
+// UsageCommand is a command of the program for a usage template
+type UsageCommand struct {
+	Name        string // name by which the command is selected
+	Description string // one line description given to AddCommand
+	Padding     string // spaces following Name that line up the Descriptions of all commands
+}
+
+// UsageExample is an example of using the program for a usage template
+type UsageExample struct {
+	Description string // what the example does
+	Command     string // the arguments, which follow the program name
+}
+
+// UsageSection is a titled part of help, e.g., "Input options:", and the flags listed in it
+type UsageSection struct {
+	Title       string      // header of the section
+	Description string      // text given to SetGroupDescription
+	Flags       []UsageFlag // flags in the section, in the order given by UsageOrder
+}
+
+// UsageFlag describes one flag that is not hidden for a usage template.  Besides the facts of its declaration,
+// it has the pieces of the line the built-in template shows for it, e.g., Syntax "-count (-c) <int>",
+// Details "(required) how many", and the Padding between them that lines up the Details of all flags
+type UsageFlag struct {
+	Name       string   // flag name, without the leading '-'
+	Aliases    []string // other names for the flag, see AddAlias
+	Type       string   // kind of value taken, e.g., "int", or "int|string" for a union
+	Required   bool     // the flag must be given, or for one made RequiredOrDefault, have a default
+	Bounds     string   // limits on the value beyond its type, e.g., "3 to 32 characters", if any
+	Usage      string   // usage string given to SetUsage
+	Default    string   // the default as help shows it, e.g., "out.txt" quoted, if HasDefault
+	HasDefault bool     // a default was declared
+	Deprecated string   // message given to SetDeprecated, if deprecated
+	Syntax     string   // how the flag is written, colored when coloring
+	Padding    string   // spaces following Syntax, or a new line and indentation when Syntax is too wide
+	Details    string   // required marker, usage string, bounds, and default, as the built-in template shows them, wrapped
+}
+
+// SetUsageTemplate replaces the template with which Usage writes help, see DefaultUsageTemplate
+// and UsageData.  An error is returned if the template does not parse, leaving the template unchanged
+func (cp *CmdParser) SetUsageTemplate(tmpl string) error {
+	parsed, err := template.New("usage").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	cp.usageTemplate = parsed
+	return nil
+}
+
+// defaultWrapWidth is the width to which help is wrapped when the width of a terminal is not known
+const defaultWrapWidth = 80
+
+// nameColumnWidth is the most the column of flag syntax may be widened to line up the descriptions.
+// The description of a flag whose syntax is wider starts on the next line
+const nameColumnWidth = 32
+
+// minWrapWidth is the least width to which descriptions are wrapped, however narrow the terminal
+const minWrapWidth = 20
+
+// SetWrapWidth gives the width in columns to which Usage wraps the descriptions of flags, overriding the
+// width of the terminal.  A width of 0 restores the default
+func (cp *CmdParser) SetWrapWidth(width int) {
+	cp.wrapWidth = width
+}
+
+// usageWidth returns the width to which help written to the writer is wrapped: that given to SetWrapWidth,
+// else that of the terminal the writer is, given by $COLUMNS or asked of the terminal, else defaultWrapWidth
+func (cp *CmdParser) usageWidth(w io.Writer) int {
+	if cp.wrapWidth > 0 {
+		return cp.wrapWidth
+	}
+	if !isTerminal(w) {
+		return defaultWrapWidth
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if columns := terminalWidth(w.(*os.File)); columns > 0 {
+		return columns
+	}
+	return defaultWrapWidth
+}
+
+// visibleLen returns the number of characters of text that show, less any ANSI color escape sequences
+func visibleLen(text string) int {
+	length := 0
+	escaped := false
+	for _, r := range text {
+		switch {
+		case r == '\x1b':
+			escaped = true
+		case escaped:
+			escaped = r != 'm'
+		default:
+			length += 1
+		}
+	}
+	return length
+}
+
+// wrapText breaks text into lines of at most width characters at spaces, joining them with the
+// separator, e.g., a newline and the indentation of a column.  A word wider than the width gets a line of its own
+func wrapText(text string, width int, separator string) string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && visibleLen(line)+1+visibleLen(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, separator)
+}
+
+// syntaxWidth returns the width of the column of flag syntax in help, that of the widest syntax, e.g.,
+// "-count (-c) <int>", among the flags shown, including those of the CmdParsers above a command's,
+// so that all descriptions line up in one column.  When wrap is not 0 it is at most nameColumnWidth
+func (cp *CmdParser) syntaxWidth(wrap int) int {
+	width := 0
+	for shown := cp; shown != nil; shown = shown.parent {
+		for _, section := range shown.usageSections() {
+			for _, name := range section.names {
+				if syntax := shown.flagSyntax(name); len(syntax) > width {
+					width = len(syntax)
+				}
+			}
+		}
+	}
+	if wrap > 0 && width > nameColumnWidth {
+		width = nameColumnWidth
+	}
+	return width
+}
+
+// usageData gathers what the usage template is executed with.  When wrap is not 0, the descriptions
+// of flags are wrapped to fit lines that wide
+func (cp *CmdParser) usageData(color bool, wrap int) UsageData {
+	return cp.usageDataAt(color, wrap, cp.syntaxWidth(wrap))
+}
+
+// usageDataAt gathers what the usage template is executed with, as usageData does, with the flag
+// syntax padded to the width given
+func (cp *CmdParser) usageDataAt(color bool, wrap int, width int) UsageData {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + colorReset
+	}
+
+	sections := cp.usageSections()
+
+	// descriptions start in a column after the indentation, the syntax, and a gap
+	column := 2 + width + 2
+	indent := "\n" + strings.Repeat(" ", column)
+	desc_width := wrap - column
+	if desc_width < minWrapWidth {
+		desc_width = minWrapWidth
+	}
+
+	data := UsageData{Program: cp.program(), Description: cp.description}
+	name_width := 0
+	for _, name := range cp.commandOrder {
+		if len(name) > name_width {
+			name_width = len(name)
+		}
+	}
+	for _, name := range cp.commandOrder {
+		data.Commands = append(data.Commands, UsageCommand{Name: name, Description: cp.commands[name].summary,
+			Padding: strings.Repeat(" ", name_width-len(name)+2)})
+	}
+	for _, ex := range cp.examples {
+		data.Examples = append(data.Examples, UsageExample{Description: ex.description, Command: ex.command})
+	}
+	flags := make(map[string]UsageFlag)
+	for _, section := range sections {
+		us := UsageSection{Title: section.title, Description: section.description}
+		for _, name := range section.names {
+			v := cp.vars[name]
+			info := cp.info[name]
+			uf := UsageFlag{Name: name, Aliases: append([]string{}, info.aliases...),
+				Type: strings.Trim(valueName(v), "<>"), Required: v.Required() || info.orDefault, Usage: info.usage,
+				HasDefault: info.hasDef, Deprecated: info.deprecated}
+			if v.ArgType() == BoolFlag {
+				uf.Type = typeName(BoolFlag)
+			}
+			if lv, ok := v.(*lengthStringVar); ok {
+				uf.Bounds = lengthBounds(lv.v_min, lv.v_max)
+			}
+			def := cp.defaultText(name)
+			if info.hasDef {
+				uf.Default = strings.TrimSuffix(strings.TrimPrefix(def, "(default "), ")")
+			}
+
+			parts := []string{}
+			if uf.Required {
+				parts = append(parts, paint(colorRequired, "(required)"))
+			}
+			if uf.Usage != "" {
+				parts = append(parts, uf.Usage)
+			}
+			if uf.Bounds != "" {
+				parts = append(parts, "("+uf.Bounds+")")
+			}
+			if def != "" {
+				parts = append(parts, def)
+			}
+			if uf.Deprecated != "" {
+				parts = append(parts, "(deprecated: "+uf.Deprecated+")")
+			}
+
+			// pad by the width of the uncolored text so escape sequences do not upset the column,
+			// and start the description of a flag too wide for the column on the next line
+			syntax := cp.flagSyntax(name)
+			uf.Syntax = paint(colorFlag, "-"+name) + syntax[len(name)+1:]
+			if len(syntax) > width {
+				uf.Padding = indent
+			} else {
+				uf.Padding = strings.Repeat(" ", width-len(syntax)+2)
+			}
+			uf.Details = strings.Join(parts, " ")
+			if wrap > 0 {
+				uf.Details = wrapText(uf.Details, desc_width, indent)
+			}
+			us.Flags = append(us.Flags, uf)
+			flags[name] = uf
+		}
+		data.Sections = append(data.Sections, us)
+	}
+
+	// the flags of the CmdParsers above a command's, given before its name, hold for it too
+	global := UsageSection{Title: "Global flags:"}
+	for above := cp.parent; above != nil; above = above.parent {
+		global.Flags = append(global.Flags, above.usageDataAt(color, wrap, width).Flags...)
+	}
+	if len(global.Flags) > 0 {
+		data.Sections = append(data.Sections, global)
+	}
+
+	for _, name := range cp.listOrder() {
+		if uf, present := flags[name]; present {
+			data.Flags = append(data.Flags, uf)
+		}
+	}
+	return data
+}
+
+// Usage writes help for the program: a usage line with the program name, the summary given to
+// SetDescription if any, and then one line per command variable in the order they were declared,
+// or alphabetical order if UsageOrder says so, in sections by group when groups are set, see SetGroup,
+// and then any examples, see AddExample.
+// Each line gives the flag and its aliases, the kind of value it takes, whether it is required, its
+// usage string, its default, and any deprecation, with the descriptions lined up in a column and wrapped
+// to the width of the terminal, see SetWrapWidth.  Hidden variables are left out.
+// When ColorUsage is set and the writer is a terminal, flag names and required markers are colored.
+// The layout is that of DefaultUsageTemplate, or of the template given to SetUsageTemplate, whose
+// execution errors are returned
+func (cp *CmdParser) Usage(w io.Writer) error {
+	tmpl := cp.usageTemplate
+	if tmpl == nil {
+		tmpl = template.Must(template.New("usage").Parse(DefaultUsageTemplate))
+	}
+	return tmpl.Execute(w, cp.usageData(cp.ColorUsage && isTerminal(w), cp.usageWidth(w)))
+}
@@ -0,0 +1,272 @@
+package cmdline
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// TestColorUsage checks that usage is colored only when ColorUsage is set and the output is a terminal
+func TestColorUsage(t *testing.T) {
+	cp := NewCmdParser()
+	cp.AddFlag(FloatFlag, "rate", true)
+	cp.AddFlag(IntFlag, "count", false)
+
+	// a strings.Builder is not a terminal, so the usage written to it is plain either way
+	for _, color := range []bool{false, true} {
+		cp.ColorUsage = color
+		var b strings.Builder
+		if err := cp.Usage(&b); err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		if strings.Contains(b.String(), "\x1b[") {
+			t.Errorf("Usage with ColorUsage %v written to a non-terminal holds color codes:\n%q", color, b.String())
+		}
+	}
+
+	// rendered as for a terminal, flag names and the required marker are colored
+	tmpl := template.Must(template.New("usage").Parse(DefaultUsageTemplate))
+	var colored, plain strings.Builder
+	if err := tmpl.Execute(&colored, cp.usageData(true, 80)); err != nil {
+		t.Fatalf("cannot render colored usage: %v", err)
+	}
+	if err := tmpl.Execute(&plain, cp.usageData(false, 80)); err != nil {
+		t.Fatalf("cannot render plain usage: %v", err)
+	}
+	for _, want := range []string{colorFlag + "-rate" + colorReset, colorFlag + "-count" + colorReset, colorRequired + "(required)" + colorReset} {
+		if !strings.Contains(colored.String(), want) {
+			t.Errorf("colored usage does not hold %q:\n%q", want, colored.String())
+		}
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("plain usage holds color codes:\n%q", plain.String())
+	}
+}
+
+// TestUsageGolden checks the usage written for a few flags against the text expected, and that writing
+// it again gives the same text
+func TestUsageGolden(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetWrapWidth(80)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs")
+	cp.AddFlagWithDefault(FloatFlag, "rate", false, 0.5)
+	cp.SetUsage("rate", "arrival rate")
+	cp.AddFlag(BoolFlag, "verbose", false)
+	cp.AddAlias("verbose", "v")
+	cp.AddFlag(StringFlag, "name", false)
+	cp.SetUsage("name", "name of the run")
+
+	want := "Usage: " + progName() + ` [flags]
+
+Flags:
+  -count <int>    (required) number of runs
+  -rate <float>   arrival rate (default 0.5)
+  -verbose (-v)
+  -name <string>  name of the run
+`
+	for pass := 1; pass <= 3; pass++ {
+		var b strings.Builder
+		if err := cp.Usage(&b); err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		if b.String() != want {
+			t.Fatalf("Usage, written %d times, is\n%s\nwant\n%s", pass, b.String(), want)
+		}
+	}
+}
+
+// TestUsageOrder checks that usage lists flags in the order they were declared by default, and in
+// alphabetical order when UsageOrder says so
+func TestUsageOrder(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(StringFlag, "zone", false)
+	cp.AddFlag(IntFlag, "alpha", false)
+	cp.AddFlag(BoolFlag, "middle", false)
+
+	for order, want := range map[FlagOrder][]string{Declaration: {"-zone", "-alpha", "-middle"}, Alphabetical: {"-alpha", "-middle", "-zone"}} {
+		cp.UsageOrder = order
+		var b strings.Builder
+		if err := cp.Usage(&b); err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		last := -1
+		for _, flag := range want {
+			at := strings.Index(b.String(), "\n  "+flag)
+			if at < 0 || at < last {
+				t.Errorf("usage in order %d does not list %v in that order:\n%s", order, want, b.String())
+				break
+			}
+			last = at
+		}
+	}
+}
+
+// TestUsageWrap checks that usage wraps descriptions to the width given to SetWrapWidth, whatever the
+// terminal's width, lining continuation lines up under the start of the description
+func TestUsageWrap(t *testing.T) {
+	t.Setenv("COLUMNS", "200")
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetWrapWidth(50)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs to make before the results are gathered and summarized in the report")
+	cp.AddFlag(StringFlag, "name", false)
+	cp.SetUsage("name", "name of the run")
+
+	want := "Usage: " + progName() + ` [flags]
+
+Flags:
+  -count <int>    (required) number of runs to
+                  make before the results are
+                  gathered and summarized in the
+                  report
+  -name <string>  name of the run
+`
+	var b strings.Builder
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("Usage wrapped to 50 columns is\n%s\nwant\n%s", b.String(), want)
+	}
+	for _, line := range strings.Split(b.String(), "\n") {
+		if len(line) > 50 {
+			t.Errorf("line %q is longer than 50 columns", line)
+		}
+	}
+}
+
+// TestUsageColumns checks that the descriptions of flags whose names differ in length start in one column,
+// two spaces past the longest flag syntax, and that a flag too long for the widest column allowed has its
+// description start in that column on the next line
+func TestUsageColumns(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetWrapWidth(200)
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.SetUsage("v", "verbose")
+	cp.AddFlag(IntFlag, "count", false)
+	cp.SetUsage("count", "number of runs")
+	cp.AddFlag(StringFlag, "output-dir", false)
+	cp.SetUsage("output-dir", "where results go")
+	checkColumn(t, cp, len("  -output-dir <string>  "), "verbose", "number of runs", "where results go")
+
+	// the column stops widening at nameColumnWidth
+	cp.AddFlag(StringFlag, "a-rather-long-flag-name-indeed", false)
+	cp.SetUsage("a-rather-long-flag-name-indeed", "long one")
+	checkColumn(t, cp, 2+nameColumnWidth+2, "verbose", "number of runs", "where results go", "long one")
+}
+
+// checkColumn checks that each description in the usage of a CmdParser ends a line, starting in the column
+func checkColumn(t *testing.T, cp *CmdParser, column int, descs ...string) {
+	t.Helper()
+	var b strings.Builder
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	for _, desc := range descs {
+		found := false
+		for _, line := range strings.Split(b.String(), "\n") {
+			if strings.HasSuffix(line, desc) {
+				found = true
+				if at := strings.Index(line, desc); at != column {
+					t.Errorf("%q starts in column %d, want %d:\n%s", desc, at, column, b.String())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("usage does not hold %q:\n%s", desc, b.String())
+		}
+	}
+}
+
+// TestUsageGroups checks that usage shows a section for each group, in the order given by SetGroupOrder
+// and then that of first mention, with the description given by SetGroupDescription under its header, and
+// the flags in no group under "Other options:"
+func TestUsageGroups(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.SetWrapWidth(80)
+	cp.AddFlag(StringFlag, "in", false)
+	cp.SetGroup("in", "Input")
+	cp.AddFlag(StringFlag, "out", false)
+	cp.SetGroup("out", "Output")
+	cp.AddFlag(IntFlag, "seed", false)
+	cp.SetGroup("seed", "Model")
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.SetGroupDescription("Output", "where results are written")
+	cp.SetGroupOrder([]string{"Output", "Input"})
+
+	want := "Usage: " + progName() + ` [flags]
+
+Output options:
+  where results are written
+  -out <string>
+
+Input options:
+  -in <string>
+
+Model options:
+  -seed <int>
+
+Other options:
+  -v
+`
+	var b strings.Builder
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("Usage is\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// a group with nothing to show has no section, and without groups the one section is "Flags:"
+	cp = NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(BoolFlag, "v", false)
+	cp.AddFlag(BoolFlag, "debug", false)
+	cp.SetGroup("debug", "Debugging")
+	cp.SetHidden("debug")
+	b.Reset()
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if strings.Contains(b.String(), "Debugging") || !strings.Contains(b.String(), "\nFlags:\n  -v\n") {
+		t.Errorf("usage with only a hidden flag grouped is\n%s\nwant a single Flags: section", b.String())
+	}
+}
+
+// TestSetUsageTemplate checks that Usage writes help with the template given to SetUsageTemplate, and that
+// a template that does not parse is an error that leaves the template in place
+func TestSetUsageTemplate(t *testing.T) {
+	cp := NewCmdParser()
+	cp.SetOutput(io.Discard)
+	cp.AddFlag(IntFlag, "count", true)
+	cp.SetUsage("count", "number of runs")
+	cp.AddFlagWithDefault(StringFlag, "name", false, "run")
+	custom := `{{range .Flags}}{{.Name}} {{.Type}}{{if .Required}} required{{end}}{{if .HasDefault}} = {{.Default}}{{end}}: {{.Usage}}
+{{end}}`
+	if err := cp.SetUsageTemplate(custom); err != nil {
+		t.Fatalf("SetUsageTemplate failed: %v", err)
+	}
+	want := "count int required: number of runs\nname string = \"run\": \n"
+	var b strings.Builder
+	if err := cp.Usage(&b); err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("Usage with a custom template is %q, want %q", b.String(), want)
+	}
+
+	if err := cp.SetUsageTemplate("{{range .Flags}"); err == nil {
+		t.Errorf("SetUsageTemplate of a template that does not parse returned no error")
+	}
+	b.Reset()
+	if err := cp.Usage(&b); err != nil || b.String() != want {
+		t.Errorf("after a bad template Usage gave %v and %q, want the custom template's %q", err, b.String(), want)
+	}
+}